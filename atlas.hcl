@@ -18,4 +18,49 @@ env "gorm" {
       diff = "{{ sql . \"  \" }}"
     }
   }
-}
\ No newline at end of file
+}
+data "external_schema" "gorm_sqlite" {
+  program = [
+    "go",
+    "run",
+    "-mod=mod",
+    "./loader",
+    "sqlite",
+  ]
+}
+
+env "sqlite" {
+  src = data.external_schema.gorm_sqlite.url
+  dev = "sqlite://dev?mode=memory"
+  migration {
+    dir = "file://migrations/sqlite"
+  }
+  format {
+    migrate {
+      diff = "{{ sql . \"  \" }}"
+    }
+  }
+}
+
+data "external_schema" "gorm_mysql" {
+  program = [
+    "go",
+    "run",
+    "-mod=mod",
+    "./loader",
+    "mysql",
+  ]
+}
+
+env "mysql" {
+  src = data.external_schema.gorm_mysql.url
+  dev = "docker://mysql/8/dev"
+  migration {
+    dir = "file://migrations/mysql"
+  }
+  format {
+    migrate {
+      diff = "{{ sql . \"  \" }}"
+    }
+  }
+}
@@ -8,8 +8,16 @@ import (
 )
 
 type BaseModel struct {
-	ID        uuid.UUID       `json:"id" gorm:"type:uuid;not null;primaryKey;default:uuid_generate_v4()"`
+	ID        uuid.UUID       `json:"id" gorm:"type:uuid;not null;primaryKey"`
 	CreatedAt *time.Time      `json:"created_at,omitempty" gorm:"not null;"`
 	UpdatedAt *time.Time      `json:"updated_at,omitempty" gorm:"not null;"`
 	DeletedAt *gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
+
+// BeforeCreate generates the id in the application, not every database can generate uuids
+func (model *BaseModel) BeforeCreate(tx *gorm.DB) error {
+	if model.ID == uuid.Nil {
+		model.ID = uuid.New()
+	}
+	return nil
+}
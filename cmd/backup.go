@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/backup"
+	"private-pub-repo/modules/backup/backupdto"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/setting"
+	"private-pub-repo/modules/storage"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/fx"
+)
+
+func CommandBackupExport() *cli.Command {
+	return &cli.Command{
+		Name:  "backup:export",
+		Usage: "export packages, versions, users, tokens and archives into one archive",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Required: true, Usage: "backup file path, `-` for stdout"},
+			&cli.TimestampFlag{Name: "since", Layout: time.RFC3339, Usage: "only export changes since this time (RFC3339), for incremental backup"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			runBackup(func(ctx context.Context, service backup.BackupService) (*backupdto.BackupResultDTO, error) {
+				return exportBackup(ctx, service, cCtx.String("output"), cCtx.Timestamp("since"))
+			})
+			return nil
+		},
+	}
+}
+
+func CommandBackupRestore() *cli.Command {
+	return &cli.Command{
+		Name:  "backup:restore",
+		Usage: "restore a backup archive, full backup needs an empty deployment",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "input", Aliases: []string{"i"}, Required: true, Usage: "backup file path, `-` for stdin"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			runBackup(func(ctx context.Context, service backup.BackupService) (*backupdto.BackupResultDTO, error) {
+				return restoreBackup(ctx, service, cCtx.String("input"))
+			})
+			return nil
+		},
+	}
+}
+
+func exportBackup(ctx context.Context, service backup.BackupService, output string, since *time.Time) (*backupdto.BackupResultDTO, error) {
+	var writer io.Writer = os.Stdout
+
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	return service.Export(ctx, writer, since)
+}
+
+func restoreBackup(ctx context.Context, service backup.BackupService, input string) (*backupdto.BackupResultDTO, error) {
+	var reader io.Reader = os.Stdin
+
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	return service.Restore(ctx, reader)
+}
+
+func runBackup(action func(ctx context.Context, service backup.BackupService) (*backupdto.BackupResultDTO, error)) {
+	fxApp := fx.New(
+		config.FxModule,
+		storage.FxModule,
+		app.FxModule,
+		monitor.FxModule,
+		db.FxModule,
+		// storage reads its presign lifetime from the settings, backups never presign so the defaults are enough
+		fx.Provide(setting.NewSettingService),
+		backup.FxModule,
+		fx.Invoke(func(lifeCycle fx.Lifecycle, backupModule *backup.BackupModule) {
+			lifeCycle.Append(fx.Hook{
+				OnStart: func(ctx context.Context) error {
+					applyBackup(backupModule, action)
+					return nil
+				},
+			})
+		}),
+		fx.NopLogger,
+	)
+
+	fxApp.Run()
+}
+
+func applyBackup(backupModule *backup.BackupModule, action func(ctx context.Context, service backup.BackupService) (*backupdto.BackupResultDTO, error)) {
+	// fx start context has a timeout, backups easily take longer than that
+	result, err := action(context.Background(), backupModule.Service)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// report goes to stderr, stdout may be the backup itself
+	encoder := json.NewEncoder(os.Stderr)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+	os.Exit(0)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"private-pub-repo/modules/pub"
+	"private-pub-repo/modules/pub/pubdto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func CommandBundleExport() *cli.Command {
+	return &cli.Command{
+		Name:  "bundle:export",
+		Usage: "export packages and their dependencies into a self-contained bundle for offline instances",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Required: true, Usage: "bundle file path, `-` for stdout"},
+			&cli.StringSliceFlag{Name: "package", Aliases: []string{"p"}, Usage: "root package as `name` or `name:constraint`, repeatable"},
+			&cli.StringFlag{Name: "lock", Usage: "pubspec.lock whose hosted packages are exported"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			options := &pubdto.BundleExportDTO{Packages: cCtx.StringSlice("package")}
+
+			if lockPath := cCtx.String("lock"); lockPath != "" {
+				lockfile, err := os.ReadFile(lockPath)
+				if err != nil {
+					return err
+				}
+				options.Lockfile = lockfile
+			}
+
+			if len(options.Packages) == 0 && options.Lockfile == nil {
+				return fmt.Errorf("--package or --lock is required")
+			}
+
+			runWithPubModule(func(ctx context.Context, pubModule *pub.PubModule) {
+				applyBundleExport(ctx, pubModule, cCtx.String("output"), options)
+			})
+			return nil
+		},
+	}
+}
+
+func CommandBundleImport() *cli.Command {
+	return &cli.Command{
+		Name:  "bundle:import",
+		Usage: "import a bundle created by bundle:export",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "input", Aliases: []string{"i"}, Required: true, Usage: "bundle file path, `-` for stdin"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "only report what would be imported"},
+			&cli.BoolFlag{Name: "json", Usage: "print the report as json"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			runWithPubModule(func(ctx context.Context, pubModule *pub.PubModule) {
+				applyBundleImport(ctx, pubModule, cCtx.String("input"), cCtx.Bool("dry-run"), cCtx.Bool("json"))
+			})
+			return nil
+		},
+	}
+}
+
+func applyBundleExport(ctx context.Context, pubModule *pub.PubModule, output string, options *pubdto.BundleExportDTO) {
+	var writer io.Writer = os.Stdout
+
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bundle export failed: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	manifest, err := pubModule.Service.ExportBundle(ctx, writer, options)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bundle export failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// manifest goes to stderr, stdout may be the bundle itself
+	encoder := json.NewEncoder(os.Stderr)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(manifest)
+
+	if len(manifest.Unresolved) > 0 {
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+func applyBundleImport(ctx context.Context, pubModule *pub.PubModule, input string, dryRun bool, printJson bool) {
+	var reader io.Reader = os.Stdin
+
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bundle import failed: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	report, err := pubModule.Service.ImportBundle(ctx, reader, dryRun, nil)
+	printImportReport(report, err, printJson)
+}
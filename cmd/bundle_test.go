@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"private-pub-repo/modules/pub/pubdto"
+	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/modules/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// bundleEntry = an archive of the bundle, listed in its manifest with the checksum of listedContent, or its own
+type bundleEntry struct {
+	path          string
+	content       []byte
+	listedContent []byte
+	unlisted      bool
+}
+
+func bundleContent(t *testing.T, entries []bundleEntry) []byte {
+	t.Helper()
+
+	bundle := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&bundle)
+	tarWriter := tar.NewWriter(gzipWriter)
+	manifest := pubdto.BundleManifestDTO{FormatVersion: pubdto.BundleFormatVersion, CreatedAt: time.Now(), Packages: []pubdto.BundlePackageDTO{}}
+
+	write := func(name string, content []byte) {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tarWriter.Write(content)
+	}
+
+	for _, entry := range entries {
+		write(entry.path, entry.content)
+		if !entry.unlisted {
+			listedContent := entry.content
+			if entry.listedContent != nil {
+				listedContent = entry.listedContent
+			}
+			checksum := sha256.Sum256(listedContent)
+			manifest.Packages = append(manifest.Packages, pubdto.BundlePackageDTO{
+				Source:        pubdto.BundleSourcePrivate,
+				Path:          entry.path,
+				ArchiveSha256: hex.EncodeToString(checksum[:]),
+			})
+		}
+	}
+
+	manifestJson, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(pubdto.BundleManifestPath, manifestJson)
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	return bundle.Bytes()
+}
+
+// TestImportBundle = only the archives listed in the manifest, with their checksum, are imported
+func TestImportBundle(t *testing.T) {
+	bucket := newMemoryStorage()
+	modules := startDatabaseModules(t, fx.Decorate(func(storage.StorageService) storage.StorageService { return bucket }))
+	pubService := modules.PubModule.Service
+	database := modules.DbService.Default()
+	ctx := context.Background()
+
+	suffix := testSuffix()
+	packageName := "bundled_" + suffix
+	smuggledPackage := "smuggled_" + suffix
+	listed := bundleEntry{path: fmt.Sprintf(pubdto.BundleArchivePathFormat, packageName, "1.0.0"), content: archiveContent(t, packageName, "1.0.0")}
+
+	expectNoPackage := func(t *testing.T, packageName string) {
+		t.Helper()
+
+		err := database.Unscoped().Where("name = ?", packageName).First(&pubmodel.PubPackageModel{}).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("expected no package %s, got %v", packageName, err)
+		}
+	}
+
+	refused := []struct {
+		name    string
+		entries []bundleEntry
+		message string
+	}{
+		{"archive missing from the manifest", []bundleEntry{
+			listed,
+			{path: fmt.Sprintf(pubdto.BundleArchivePathFormat, smuggledPackage, "1.0.0"), content: archiveContent(t, smuggledPackage, "1.0.0"), unlisted: true},
+		}, "is not in the manifest"},
+		{"archive outside of the archives directory", []bundleEntry{
+			listed,
+			{path: pubdto.BundleArchivesDirectory + "../" + smuggledPackage + ".tar.gz", content: archiveContent(t, smuggledPackage, "1.0.0"), unlisted: true},
+		}, "invalid bundle archive path"},
+		{"archive of another checksum", []bundleEntry{
+			{path: listed.path, content: archiveContent(t, smuggledPackage, "1.0.0"), listedContent: listed.content},
+		}, "archive sha256 mismatch"},
+	}
+
+	for _, testCase := range refused {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := pubService.ImportBundle(ctx, bytes.NewReader(bundleContent(t, testCase.entries)), false, nil)
+			if err == nil || !strings.Contains(err.Error(), testCase.message) {
+				t.Fatalf("expected the bundle to be refused, got %v", err)
+			}
+			expectNoPackage(t, packageName)
+			expectNoPackage(t, smuggledPackage)
+		})
+	}
+
+	t.Run("import", func(t *testing.T) {
+		report, err := pubService.ImportBundle(ctx, bytes.NewReader(bundleContent(t, []bundleEntry{listed})), false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Imported != 1 {
+			t.Fatalf("expected 1 imported version, got %+v", report)
+		}
+		if _, err := pubService.VersionDetail(ctx, packageName, "1.0.0", "", false); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
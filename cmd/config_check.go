@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"private-pub-repo/modules/config"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+func CommandConfigCheck() *cli.Command {
+	return &cli.Command{
+		Name:  "config:check",
+		Usage: "validate the configuration and print the effective values, secrets redacted",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "file", Usage: "config `file` to check instead of CONFIG_FILE / ./config.{yaml,yml,toml}"},
+			&cli.BoolFlag{Name: "json", Usage: "print the configuration as json"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			runConfigCheck(cCtx.String("file"), cCtx.Bool("json"))
+			return nil
+		},
+	}
+}
+
+func runConfigCheck(path string, printJson bool) {
+	config.LoadDotEnv()
+
+	if path == "" {
+		path = config.FilePath()
+	}
+
+	values, err := config.Load(path)
+	if err == nil {
+		err = values.Validate()
+	}
+
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "config file: none, envs only")
+	} else {
+		fmt.Fprintf(os.Stderr, "config file: %s\n", path)
+	}
+
+	// parse problems leave nothing to print
+	if values != nil {
+		if printJson {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(values.Redacted())
+		} else {
+			yaml.NewEncoder(os.Stdout).Encode(values.Redacted())
+		}
+	}
+
+	var validationError *config.ValidationError
+	if errors.As(err, &validationError) {
+		fmt.Fprintln(os.Stderr, "invalid configuration:")
+		for _, problem := range validationError.Problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", problem)
+		}
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintln(os.Stderr, "configuration is valid")
+}
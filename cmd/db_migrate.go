@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+
+	"github.com/urfave/cli/v2"
+)
+
+func CommandDbMigrate() *cli.Command {
+	return &cli.Command{
+		Name:  "db:migrate",
+		Usage: "apply the pending migrations embedded in the binary",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "to", Usage: "stop after this `version`"},
+			&cli.StringFlag{Name: "baseline", Usage: "mark migrations up to this `version` as applied without running them, for databases migrated by atlas"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "only print the migrations that would be applied"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			migrator := setupMigrator()
+
+			if version := cCtx.String("baseline"); version != "" {
+				marked, err := migrator.Baseline(context.Background(), version)
+				printMigrations("baselined", marked, err)
+				return nil
+			}
+
+			applied, err := migrator.Migrate(context.Background(), cCtx.String("to"), cCtx.Bool("dry-run"))
+			printMigrations(dryRunLabel("applied", cCtx.Bool("dry-run")), applied, err)
+			return nil
+		},
+	}
+}
+
+func CommandDbStatus() *cli.Command {
+	return &cli.Command{
+		Name:  "db:status",
+		Usage: "show applied and pending migrations",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "json", Usage: "print the status as json"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			statuses, err := setupMigrator().Status(context.Background())
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "migration status failed: %v\n", err)
+				os.Exit(1)
+			}
+
+			if cCtx.Bool("json") {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				encoder.Encode(statuses)
+				return nil
+			}
+
+			for _, status := range statuses {
+				appliedAt := ""
+				if status.AppliedAt != nil {
+					appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Printf("%-16s %-9s %-19s %s\n", status.Version, status.State, appliedAt, status.Description)
+			}
+			return nil
+		},
+	}
+}
+
+func CommandDbRollback() *cli.Command {
+	return &cli.Command{
+		Name:  "db:rollback",
+		Usage: "revert applied migrations with their down migration",
+		Flags: []cli.Flag{
+			&cli.IntFlag{Name: "steps", Value: 1, Usage: "number of migrations to revert"},
+			&cli.StringFlag{Name: "to", Usage: "revert every migration after this `version`, overrides --steps"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "only print the migrations that would be reverted"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.Int("steps") < 1 && cCtx.String("to") == "" {
+				return fmt.Errorf("--steps must be at least 1")
+			}
+
+			reverted, err := setupMigrator().Rollback(context.Background(), cCtx.Int("steps"), cCtx.String("to"), cCtx.Bool("dry-run"))
+			printMigrations(dryRunLabel("reverted", cCtx.Bool("dry-run")), reverted, err)
+			return nil
+		},
+	}
+}
+
+// setupMigrator connects without starting the db module, which could run migrations on its own (DB_MIGRATE_ON_START)
+func setupMigrator() *db.Migrator {
+	configModule := config.SetupModule()
+	appModule := app.SetupModule(configModule)
+	dbModule := db.SetupModule(configModule, appModule, monitor.SetupModule(appModule, configModule))
+	dbModule.Connect()
+
+	migrator, err := db.NewMigrator(dbModule.Default())
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	return migrator
+}
+
+func dryRunLabel(label string, dryRun bool) string {
+	if dryRun {
+		return "would be " + label
+	}
+	return label
+}
+
+func printMigrations(label string, list []db.Migration, err error) {
+	for _, migration := range list {
+		fmt.Printf("%s %s\n", label, migration.Version)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(list) == 0 {
+		fmt.Println("nothing to do")
+	}
+	os.Exit(0)
+}
@@ -2,16 +2,22 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"private-pub-repo/modules/app"
 	"private-pub-repo/modules/config"
 	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/health"
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/mail"
 	"private-pub-repo/modules/monitor"
 	"private-pub-repo/modules/pub"
 	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/serviceaccount"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/storage"
+	"private-pub-repo/modules/trustedpublisher"
 	"private-pub-repo/modules/user"
 
 	"github.com/gofiber/fiber/v2"
@@ -39,9 +45,14 @@ func runFx() {
 		monitor.FxModule,
 		db.FxModule,
 		jwt.FxModule,
+		setting.FxModule,
+		ratelimit.FxModule,
 		user.FxModule,
 		pubtoken.FxModule,
+		serviceaccount.FxModule,
+		trustedpublisher.FxModule,
 		pub.FxModule,
+		health.FxModule,
 		fx.Invoke(registerWebServer),
 	)
 
@@ -52,16 +63,17 @@ func registerWebServer(
 	lifeCycle fx.Lifecycle,
 	app *fiber.App,
 	config config.ConfigService,
+	monitorModule *monitor.MonitorModule,
 ) {
 	lifeCycle.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
-			go func() {
-				app.Get("/", func(c *fiber.Ctx) error {
-					return fiber.NewError(400, "Error")
-				})
+			if err := monitorModule.ServeMetrics(); err != nil {
+				return err
+			}
 
+			go func() {
 				if err := app.Listen(
-					config.Getenv("APP_HOST", "") + ":" + config.Getenv("PORT", "3000"),
+					fmt.Sprintf("%s:%d", config.Config().App.Host, config.Config().App.Port),
 				); err != nil {
 					log.Fatalf("start server error : %v\n", err)
 				}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/jwt"
+	"private-pub-repo/modules/mail"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/pub"
+	"private-pub-repo/modules/pub/pubdto"
+	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/setting"
+	"private-pub-repo/modules/storage"
+	"private-pub-repo/modules/user"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/fx"
+)
+
+func CommandPubImport() *cli.Command {
+	return &cli.Command{
+		Name:  "pub:import",
+		Usage: "import packages from another pub repository or a local directory",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "url", Usage: "base url of a hosted pub repository, e.g. https://pub.dev"},
+			&cli.StringFlag{Name: "token", EnvVars: []string{"PUB_IMPORT_TOKEN"}, Usage: "bearer token for the hosted repository"},
+			&cli.StringSliceFlag{Name: "package", Aliases: []string{"p"}, Usage: "package to import from the hosted repository, repeatable"},
+			&cli.StringFlag{Name: "dir", Usage: "directory of .tar.gz archives or a .pub-cache/hosted tree"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "only report what would be imported"},
+			&cli.BoolFlag{Name: "json", Usage: "print the report as json"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			options := &pubdto.ImportDTO{
+				Url:      cCtx.String("url"),
+				Token:    cCtx.String("token"),
+				Packages: cCtx.StringSlice("package"),
+				Path:     cCtx.String("dir"),
+				DryRun:   cCtx.Bool("dry-run"),
+			}
+
+			switch {
+			case options.Url != "" && options.Path != "":
+				return fmt.Errorf("use either --url or --dir")
+			case options.Url != "":
+				options.Source = pubdto.ImportSourceHosted
+			case options.Path != "":
+				options.Source = pubdto.ImportSourceDirectory
+			default:
+				return fmt.Errorf("--url or --dir is required")
+			}
+
+			runPubImport(options, cCtx.Bool("json"))
+			return nil
+		},
+	}
+}
+
+func runPubImport(options *pubdto.ImportDTO, printJson bool) {
+	runWithPubModule(func(ctx context.Context, pubModule *pub.PubModule) {
+		applyPubImport(ctx, pubModule, options, printJson)
+	})
+}
+
+func runWithPubModule(action func(ctx context.Context, pubModule *pub.PubModule)) {
+	fxApp := fx.New(
+		config.FxModule,
+		storage.FxModule,
+		mail.FxModule,
+		app.FxModule,
+		monitor.FxModule,
+		db.FxModule,
+		jwt.FxModule,
+		setting.FxModule,
+		ratelimit.FxModule,
+		user.FxModule,
+		pubtoken.FxModule,
+		pub.FxModule,
+		fx.Invoke(func(lifeCycle fx.Lifecycle, pubModule *pub.PubModule) {
+			lifeCycle.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					// imports and exports download and upload every archive, far beyond the fx start timeout
+					action(context.Background(), pubModule)
+					return nil
+				},
+			})
+		}),
+		fx.NopLogger,
+	)
+
+	fxApp.Run()
+}
+
+func applyPubImport(ctx context.Context, pubModule *pub.PubModule, options *pubdto.ImportDTO, printJson bool) {
+	report, err := pubModule.Service.Import(ctx, options, nil)
+	printImportReport(report, err, printJson)
+}
+
+func printImportReport(report *pubdto.ImportReportDTO, err error, printJson bool) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+	}
+
+	if report != nil {
+		if printJson {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(report)
+		} else {
+			fmt.Printf("imported: %d, skipped: %d, failed: %d\n", report.Imported, report.Skipped, report.Failed)
+			for _, result := range report.Results {
+				if result.Status == pubdto.ImportStatusFailed {
+					fmt.Printf("  %s %s: %s\n", result.PackageName, result.Version, result.Error)
+				}
+			}
+		}
+	}
+
+	if err != nil || report.Failed > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
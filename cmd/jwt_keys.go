@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/jwt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func CommandJwtKeygen() *cli.Command {
+	return &cli.Command{
+		Name:  "jwt:keygen",
+		Usage: "add a signing key to the jwt keys directory, published in the jwks right away and signing from its activation",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "dir", Usage: "keys `directory` instead of JWT_KEYS_DIR"},
+			&cli.StringFlag{Name: "algorithm", Usage: "RS256 or EdDSA, JWT_ALGORITHM by default (RS256 when it is HS256)"},
+			&cli.DurationFlag{Name: "activate-in", Usage: "start signing after this `duration`, give the jwks caches of the verifiers time to see the key, e.g. 24h"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			values := loadJwtConfig()
+
+			dir := cCtx.String("dir")
+			if dir == "" {
+				dir = values.Jwt.KeysDir
+			}
+			if dir == "" {
+				fmt.Fprintln(os.Stderr, "no keys directory, set JWT_KEYS_DIR or --dir")
+				os.Exit(1)
+			}
+
+			algorithm := cCtx.String("algorithm")
+			if algorithm == "" {
+				algorithm = values.Jwt.Algorithm
+			}
+			if algorithm == jwt.AlgorithmHS256 {
+				algorithm = jwt.AlgorithmRS256
+			}
+
+			content, err := jwt.GenerateKey(algorithm)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "key generation failed: %v\n", err)
+				os.Exit(1)
+			}
+
+			activeFrom := time.Now().Add(cCtx.Duration("activate-in"))
+			path, err := jwt.WriteKey(dir, jwt.NewKeyId(activeFrom), content)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "key write failed: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("%s key %s, signing from %s\n", algorithm, path, activeFrom.UTC().Format(time.RFC3339))
+			return nil
+		},
+	}
+}
+
+func CommandJwtKeys() *cli.Command {
+	return &cli.Command{
+		Name:  "jwt:keys",
+		Usage: "list the keys of the jwt keys directory and what they are used for",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "dir", Usage: "keys `directory` instead of JWT_KEYS_DIR"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			values := loadJwtConfig()
+
+			dir := cCtx.String("dir")
+			if dir == "" {
+				dir = values.Jwt.KeysDir
+			}
+
+			keys, err := jwt.LoadKeys(dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "keys unusable: %v\n", err)
+				os.Exit(1)
+			}
+
+			now := time.Now()
+			// the last active key of each algorithm signs
+			signing := map[string]string{}
+			for _, key := range keys {
+				if !key.ActiveFrom.After(now) {
+					signing[key.Algorithm] = key.Id
+				}
+			}
+
+			for _, key := range keys {
+				status := "verifying only, remove it once the tokens it signed expired"
+				switch {
+				case key.ActiveFrom.After(now):
+					status = "pending, published in the jwks"
+				case signing[key.Algorithm] == key.Id && key.Algorithm == values.Jwt.Algorithm:
+					status = "signing"
+				case signing[key.Algorithm] == key.Id:
+					status = fmt.Sprintf("verifying only, would sign with JWT_ALGORITHM=%s", key.Algorithm)
+				}
+				fmt.Printf("%s  %-5s  %s  %s\n", key.Id, key.Algorithm, key.ActiveFrom.Format(time.RFC3339), status)
+			}
+
+			if values.Jwt.Secret != "" {
+				fmt.Println("tokens without key id are accepted, signed by JWT_SECRET")
+			}
+
+			return nil
+		},
+	}
+}
+
+// loadJwtConfig = configuration without validation, keys are made before the configuration is complete
+func loadJwtConfig() *config.Config {
+	config.LoadDotEnv()
+
+	values, err := config.Load(config.FilePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	return values
+}
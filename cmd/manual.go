@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -8,16 +9,20 @@ import (
 	"private-pub-repo/modules/app"
 	"private-pub-repo/modules/config"
 	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/health"
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/mail"
 	"private-pub-repo/modules/monitor"
 	"private-pub-repo/modules/pub"
 	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/serviceaccount"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/storage"
+	"private-pub-repo/modules/trustedpublisher"
 	"private-pub-repo/modules/user"
 	"syscall"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/urfave/cli/v2"
 )
 
@@ -34,15 +39,20 @@ func CommandManual() *cli.Command {
 
 func runManual() {
 	configModule := config.SetupModule()
-	storageModule := storage.SetupModule(configModule)
 	mailModule := mail.SetupModule(configModule)
 	appModule := app.SetupModule(configModule)
 	monitorModule := monitor.SetupModule(appModule, configModule)
-	dbModule := db.SetupModule(configModule)
-	jwtModule := jwt.SetupModule(appModule, configModule)
-	userModule := user.SetupModule(appModule, dbModule, jwtModule, monitorModule, configModule, mailModule)
-	pubTokenModule := pubtoken.SetupModule(appModule, dbModule, userModule, jwtModule, monitorModule)
-	pubModule := pub.SetupModule(appModule, dbModule, jwtModule, pubTokenModule, userModule, monitorModule, configModule, storageModule)
+	dbModule := db.SetupModule(configModule, appModule, monitorModule)
+	jwtModule := jwt.SetupModule(appModule, configModule, monitorModule)
+	settingModule := setting.SetupModule(appModule, dbModule, jwtModule, monitorModule, configModule, user.NewUserJwtMiddleware(jwtModule, monitorModule.Service))
+	storageModule := storage.SetupModule(configModule, monitorModule, settingModule)
+	rateLimitModule := ratelimit.SetupModule(dbModule, monitorModule, configModule)
+	userModule := user.SetupModule(appModule, dbModule, jwtModule, monitorModule, mailModule, settingModule, rateLimitModule, configModule)
+	pubTokenModule := pubtoken.SetupModule(appModule, dbModule, userModule, jwtModule, monitorModule, settingModule, configModule)
+	serviceAccountModule := serviceaccount.SetupModule(appModule, dbModule, userModule, pubTokenModule, jwtModule, monitorModule, settingModule)
+	trustedPublisherModule := trustedpublisher.SetupModule(appModule, dbModule, userModule, pubTokenModule, jwtModule, monitorModule, configModule, rateLimitModule)
+	pubModule := pub.SetupModule(appModule, dbModule, jwtModule, pubTokenModule, userModule, monitorModule, configModule, storageModule, settingModule, rateLimitModule)
+	healthModule := health.SetupModule(appModule, dbModule, storageModule, mailModule, jwtModule, userModule, configModule)
 
 	modules := []base.BaseModule{
 		configModule,
@@ -50,19 +60,20 @@ func runManual() {
 		monitorModule,
 		dbModule,
 		jwtModule,
+		settingModule,
+		rateLimitModule,
 		userModule,
 		pubTokenModule,
+		serviceAccountModule,
+		trustedPublisherModule,
 		pubModule,
+		healthModule,
 	}
 
 	for i := range modules {
 		modules[i].OnStart()
 	}
 
-	appModule.App.Get("/", func(c *fiber.Ctx) error {
-		return fiber.NewError(400, "Error")
-	})
-
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	go func() error {
@@ -80,7 +91,11 @@ func runManual() {
 
 	// ...
 
-	if err := appModule.App.Listen(configModule.Getenv("APP_HOST", "") + ":" + configModule.Getenv("PORT", "3000")); err != nil {
+	if err := monitorModule.ServeMetrics(); err != nil {
+		log.Panic(err)
+	}
+
+	if err := appModule.App.Listen(fmt.Sprintf("%s:%d", configModule.Config().App.Host, configModule.Config().App.Port)); err != nil {
 		log.Panic(err)
 	}
 }
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"private-pub-repo/modules/pub"
+	"private-pub-repo/modules/pub/pubdto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func CommandMirrorGenerate() *cli.Command {
+	return &cli.Command{
+		Name:  "mirror:generate",
+		Usage: "render the repository as a static file tree, servable as a read-only replica",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Required: true, Usage: "directory to write the tree to"},
+			&cli.StringFlag{Name: "base-url", Required: true, Usage: "url the tree will be served from, e.g. https://cdn.example.com"},
+			&cli.BoolFlag{Name: "include-private", Usage: "include private packages"},
+			&cli.BoolFlag{Name: "prune", Usage: "remove files of packages and versions no longer in the repository"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			options := &pubdto.MirrorDTO{
+				OutputPath:     cCtx.String("output"),
+				BaseUrl:        cCtx.String("base-url"),
+				IncludePrivate: cCtx.Bool("include-private"),
+				Prune:          cCtx.Bool("prune"),
+			}
+
+			runWithPubModule(func(ctx context.Context, pubModule *pub.PubModule) {
+				applyMirrorGenerate(ctx, pubModule, options)
+			})
+			return nil
+		},
+	}
+}
+
+func applyMirrorGenerate(ctx context.Context, pubModule *pub.PubModule, options *pubdto.MirrorDTO) {
+	report, err := pubModule.Service.GenerateMirror(ctx, options)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mirror generation failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	os.Exit(0)
+}
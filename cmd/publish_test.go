@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"private-pub-repo/modules/pub"
+	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/modules/storage"
+	"private-pub-repo/modules/storage/storagemodel"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+var errCopyFailed = errors.New("copy failed")
+
+// memoryStorage = the bucket in memory, onCopy runs before each copy & fails it when it returns an error
+type memoryStorage struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+	onCopy  func() error
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{objects: map[string][]byte{}}
+}
+
+func (memory *memoryStorage) Upload(context context.Context, key string, reader io.Reader) error {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	memory.objects[key] = content
+	return nil
+}
+
+func (memory *memoryStorage) Download(context context.Context, key string) (io.ReadCloser, error) {
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	content, ok := memory.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (memory *memoryStorage) Stat(context context.Context, key string) (*storagemodel.StorageObject, error) {
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	content, ok := memory.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &storagemodel.StorageObject{Key: key, Size: int64(len(content))}, nil
+}
+
+func (memory *memoryStorage) Delete(context context.Context, key string) error {
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	delete(memory.objects, key)
+	return nil
+}
+
+func (memory *memoryStorage) Copy(context context.Context, sourceKey string, destinationKey string) error {
+	if memory.onCopy != nil {
+		if err := memory.onCopy(); err != nil {
+			return err
+		}
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	content, ok := memory.objects[sourceKey]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	memory.objects[destinationKey] = content
+	return nil
+}
+
+func (memory *memoryStorage) List(context context.Context, prefix string) ([]storagemodel.StorageObject, error) {
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	now := time.Now()
+	objects := []storagemodel.StorageObject{}
+	for key, content := range memory.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, storagemodel.StorageObject{Key: key, Size: int64(len(content)), LastModified: &now})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (memory *memoryStorage) GetUrl(context context.Context, key string) string {
+	return "https://storage.example.invalid/" + key
+}
+
+func (memory *memoryStorage) Ping(context context.Context) error {
+	return nil
+}
+
+func (memory *memoryStorage) keys(prefix string) []string {
+	objects, _ := memory.List(context.Background(), prefix)
+	keys := []string{}
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+// archiveContent = package archive of packageName with only its pubspec.yaml
+func archiveContent(t *testing.T, packageName string, version string) []byte {
+	t.Helper()
+
+	archive := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	pubspec := []byte("name: " + packageName + "\nversion: " + version + "\n")
+	if err := tarWriter.WriteHeader(&tar.Header{Name: "pubspec.yaml", Mode: 0644, Size: int64(len(pubspec)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tarWriter.Write(pubspec)
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	return archive.Bytes()
+}
+
+// archiveFile = uploaded package archive of packageName, as received by the upload handler
+func archiveFile(t *testing.T, packageName string, version string) *multipart.FileHeader {
+	t.Helper()
+
+	body := bytes.Buffer{}
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "package.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(archiveContent(t, packageName, version))
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+
+	return form.File["file"][0]
+}
+
+// TestPublishCopyFailure = a version whose archive can't be promoted is taken back, with the package it created
+func TestPublishCopyFailure(t *testing.T) {
+	bucket := newMemoryStorage()
+	modules := startDatabaseModules(t, fx.Decorate(func(storage.StorageService) storage.StorageService { return bucket }))
+	pubService := modules.PubModule.Service
+	database := modules.DbService.Default()
+	ctx := context.Background()
+
+	suffix := testSuffix()
+	packageName := "publish_" + suffix
+
+	expectNoVersion := func(t *testing.T, version string) {
+		t.Helper()
+
+		err := database.Unscoped().Where("package_name = ?", packageName).Where("version = ?", version).First(&pubmodel.PubVersionModel{}).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("expected no version %s, got %v", version, err)
+		}
+		if staged := bucket.keys("pub/staging/"); len(staged) > 0 {
+			t.Fatalf("expected no staged archive, got %v", staged)
+		}
+	}
+
+	t.Run("new package", func(t *testing.T) {
+		bucket.onCopy = func() error {
+			// committed, but not visible before its archive is promoted
+			if _, err := pubService.VersionDetail(ctx, packageName, "1.0.0", "", false); err == nil {
+				t.Error("expected the version to be hidden before its archive is promoted")
+			}
+			return errCopyFailed
+		}
+
+		err := pubService.UploadVersion(ctx, archiveFile(t, packageName, "1.0.0"), nil, nil)
+		if !errors.Is(err, errCopyFailed) {
+			t.Fatalf("expected the copy failure, got %v", err)
+		}
+
+		expectNoVersion(t, "1.0.0")
+		err = database.Unscoped().Where("name = ?", packageName).First(&pubmodel.PubPackageModel{}).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("expected no package row, got %v", err)
+		}
+		if archives := bucket.keys("pub/packages/"); len(archives) > 0 {
+			t.Fatalf("expected no archive, got %v", archives)
+		}
+	})
+
+	t.Run("published", func(t *testing.T) {
+		bucket.onCopy = nil
+
+		if err := pubService.UploadVersion(ctx, archiveFile(t, packageName, "1.0.0"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pubService.VersionDetail(ctx, packageName, "1.0.0", "", false); err != nil {
+			t.Fatalf("expected the version to be visible, got %v", err)
+		}
+		if _, err := bucket.Stat(ctx, "pub/packages/"+packageName+"/versions/1.0.0.tar.gz"); err != nil {
+			t.Fatal(err)
+		}
+		if staged := bucket.keys("pub/staging/"); len(staged) > 0 {
+			t.Fatalf("expected no staged archive, got %v", staged)
+		}
+	})
+
+	t.Run("new version of a package", func(t *testing.T) {
+		bucket.onCopy = func() error { return errCopyFailed }
+
+		err := pubService.UploadVersion(ctx, archiveFile(t, packageName, "1.1.0"), nil, nil)
+		if !errors.Is(err, errCopyFailed) {
+			t.Fatalf("expected the copy failure, got %v", err)
+		}
+
+		expectNoVersion(t, "1.1.0")
+		if _, err := pubService.VersionDetail(ctx, packageName, "1.0.0", "", false); err != nil {
+			t.Fatalf("expected the package & its other version to stay, got %v", err)
+		}
+	})
+}
+
+// TestDeletedPackageNotResolvedUpstream = the pub api doesn't let the upstream answer for a package deleted here
+func TestDeletedPackageNotResolvedUpstream(t *testing.T) {
+	bucket := newMemoryStorage()
+	modules := startDatabaseModules(t, fx.Decorate(func(storage.StorageService) storage.StorageService { return bucket }))
+	pubService := modules.PubModule.Service
+	ctx := context.Background()
+
+	suffix := testSuffix()
+	packageName := "deleted_" + suffix
+
+	if err := pubService.UploadVersion(ctx, archiveFile(t, packageName, "1.0.0"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pubService.UploadVersion(ctx, archiveFile(t, packageName, "1.1.0"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pubService.VersionList(ctx, "unknown_"+suffix, "", false); err != fiber.ErrNotFound {
+		t.Fatalf("expected an unknown package to be resolved from the upstream, got %v", err)
+	}
+
+	if err := pubService.DeleteVersion(ctx, packageName, "1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pubService.VersionDetail(ctx, packageName, "1.1.0", "", false); err != pub.ErrPackageUnavailable {
+		t.Fatalf("expected a deleted version not to be resolved from the upstream, got %v", err)
+	}
+	if _, err := pubService.VersionDetail(ctx, packageName, "2.0.0", "", false); err != pub.ErrPackageUnavailable {
+		t.Fatalf("expected an unknown version of the package not to be resolved from the upstream, got %v", err)
+	}
+
+	if err := pubService.DeletePackage(ctx, packageName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pubService.VersionList(ctx, packageName, "", false); err != pub.ErrPackageUnavailable {
+		t.Fatalf("expected a deleted package not to be resolved from the upstream, got %v", err)
+	}
+	if _, err := pubService.GetDownloadUrl(ctx, packageName, "1.0.0", "", false); err != pub.ErrPackageUnavailable {
+		t.Fatalf("expected the archive of a deleted package not to be resolved from the upstream, got %v", err)
+	}
+
+	if err := pubService.PurgePackage(ctx, packageName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pubService.VersionList(ctx, packageName, "", false); err != fiber.ErrNotFound {
+		t.Fatalf("expected a purged package to be resolved from the upstream, got %v", err)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/mail"
 	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/storage"
 	"private-pub-repo/modules/user"
 
@@ -36,6 +38,8 @@ func runSeeder() {
 		monitor.FxModule,
 		db.FxModule,
 		jwt.FxModule,
+		setting.FxModule,
+		ratelimit.FxModule,
 		user.FxModule,
 		fx.Invoke(applySeeders),
 		fx.NopLogger,
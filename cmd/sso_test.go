@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"private-pub-repo/modules/oidc"
+	"private-pub-repo/modules/user/userdto"
+	"private-pub-repo/modules/user/usermodel"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	testSsoClientId     = "pubserver"
+	testSsoClientSecret = "client-secret"
+	testSsoRedirectUrl  = "https://pub.example.invalid/sso/callback"
+)
+
+// testSsoLogin = a login the stand-in provider signed in, what its token endpoint checks & the claims of the id token
+type testSsoLogin struct {
+	challenge   string
+	redirectUri string
+	claims      jwtlib.MapClaims
+}
+
+// signIn = the provider signing in email for the login started at authorizeUrl, changes replace the claims of the id
+// token or remove them when nil. returns the code & the state the browser is redirected to the callback with
+func (issuer *testIssuer) signIn(t *testing.T, authorizeUrl string, email string, changes map[string]interface{}) (string, string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authorizeUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("client_id") != testSsoClientId {
+		t.Fatalf("unexpected authorization request %s", authorizeUrl)
+	}
+
+	now := time.Now()
+	claims := jwtlib.MapClaims{
+		"iss":            issuer.server.URL,
+		"aud":            testSsoClientId,
+		"sub":            "user:" + email,
+		"nonce":          query.Get("nonce"),
+		"email":          email,
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+
+	code := uuid.NewString()
+	issuer.mutex.Lock()
+	issuer.codes[code] = &testSsoLogin{challenge: query.Get("code_challenge"), redirectUri: query.Get("redirect_uri"), claims: claims}
+	issuer.mutex.Unlock()
+
+	return code, query.Get("state")
+}
+
+// tokenEndpoint = the id token of the login of a code, once, for the client & the pkce verifier of the login
+func (issuer *testIssuer) tokenEndpoint(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok || clientId != testSsoClientId || clientSecret != testSsoClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	issuer.mutex.Lock()
+	login := issuer.codes[code]
+	delete(issuer.codes, code)
+	issuer.mutex.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if login == nil || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != login.redirectUri ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != login.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := issuer.sign(login.claims)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(oidc.TokenResponse{AccessToken: uuid.NewString(), IdToken: idToken, TokenType: "Bearer"})
+}
+
+// startSsoModules = the modules with the stand-in issuer as sso provider, env overrides its options
+func startSsoModules(t *testing.T, issuer *testIssuer, env map[string]string) *databaseModules {
+	t.Helper()
+
+	t.Setenv("SSO_ISSUER", issuer.server.URL)
+	t.Setenv("SSO_ALLOW_HTTP", "true")
+	t.Setenv("SSO_CLIENT_ID", testSsoClientId)
+	t.Setenv("SSO_CLIENT_SECRET", testSsoClientSecret)
+	t.Setenv("SSO_REDIRECT_URL", testSsoRedirectUrl)
+	t.Setenv("SSO_ADMIN_GROUPS", "pub-admins")
+	t.Setenv("SSO_WRITE_GROUPS", "pub-writers,pub-maintainers")
+	t.Setenv("SSO_JIT_PROVISIONING", "false")
+	t.Setenv("SSO_ALLOW_UNVERIFIED_EMAIL", "false")
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+
+	return startDatabaseModules(t)
+}
+
+// ssoCallback = starts a login, signs email in at the provider & returns the callback of the browser that started it
+func ssoCallback(t *testing.T, modules *databaseModules, issuer *testIssuer, email string, changes map[string]interface{}) *userdto.SsoCallbackDTO {
+	t.Helper()
+
+	authorizeUrl, cookie, err := modules.UserModule.Service.SsoAuthorize(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := issuer.signIn(t, authorizeUrl, email, changes)
+	return &userdto.SsoCallbackDTO{Code: code, State: state, StateCookie: cookie.Value}
+}
+
+func expectSsoStatus(t *testing.T, err error, status int) {
+	t.Helper()
+
+	var fiberError *fiber.Error
+	if !errors.As(err, &fiberError) || fiberError.Code != status {
+		t.Fatalf("expected the login to be refused with %d, got %v", status, err)
+	}
+}
+
+func findSsoUser(t *testing.T, modules *databaseModules, email string) *usermodel.UserModel {
+	t.Helper()
+
+	var user usermodel.UserModel
+	if err := modules.DbService.Default().Where("email = ?", email).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// TestSsoLogin signs in at a stand-in provider, the callback is refused unless the browser, the login & the id token match
+func TestSsoLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	modules := startSsoModules(t, issuer, map[string]string{"SSO_JIT_PROVISIONING": "true"})
+	service := modules.UserModule.Service
+	ctx := context.Background()
+
+	existing := insertTestUser(t, modules, testSuffix())
+	expired := time.Now().Add(-10 * time.Minute)
+
+	refused := []struct {
+		name     string
+		callback func(t *testing.T) *userdto.SsoCallbackDTO
+	}{
+		{"callback in another browser", func(t *testing.T) *userdto.SsoCallbackDTO {
+			callback := ssoCallback(t, modules, issuer, existing.Email, nil)
+			callback.StateCookie = ssoCallback(t, modules, issuer, existing.Email, nil).StateCookie
+			return callback
+		}},
+		{"callback without the cookie", func(t *testing.T) *userdto.SsoCallbackDTO {
+			callback := ssoCallback(t, modules, issuer, existing.Email, nil)
+			callback.StateCookie = ""
+			return callback
+		}},
+		{"state of no login", func(t *testing.T) *userdto.SsoCallbackDTO {
+			callback := ssoCallback(t, modules, issuer, existing.Email, nil)
+			state := sha256.Sum256([]byte("forged"))
+			callback.State = "forged"
+			callback.StateCookie = base64.RawURLEncoding.EncodeToString(state[:])
+			return callback
+		}},
+		{"code of another login", func(t *testing.T) *userdto.SsoCallbackDTO {
+			callback := ssoCallback(t, modules, issuer, existing.Email, nil)
+			callback.Code = ssoCallback(t, modules, issuer, existing.Email, nil).Code
+			return callback
+		}},
+		{"nonce of another login", func(t *testing.T) *userdto.SsoCallbackDTO {
+			return ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"nonce": "other"})
+		}},
+		{"issued to another client", func(t *testing.T) *userdto.SsoCallbackDTO {
+			return ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"aud": "other-client"})
+		}},
+		{"authorized party is another client", func(t *testing.T) *userdto.SsoCallbackDTO {
+			return ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"aud": []string{testSsoClientId, "other-client"}, "azp": "other-client"})
+		}},
+		{"expired id token", func(t *testing.T) *userdto.SsoCallbackDTO {
+			return ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"iat": expired.Add(-5 * time.Minute).Unix(), "exp": expired.Unix()})
+		}},
+		{"unverified email", func(t *testing.T) *userdto.SsoCallbackDTO {
+			return ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"email_verified": false})
+		}},
+		{"no email_verified claim", func(t *testing.T) *userdto.SsoCallbackDTO {
+			return ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"email_verified": nil})
+		}},
+		{"no email claim", func(t *testing.T) *userdto.SsoCallbackDTO {
+			return ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"email": nil})
+		}},
+	}
+
+	for _, testCase := range refused {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := service.SsoLogin(ctx, testCase.callback(t))
+			expectSsoStatus(t, err, fiber.StatusUnauthorized)
+		})
+	}
+
+	t.Run("replayed callback", func(t *testing.T) {
+		callback := ssoCallback(t, modules, issuer, existing.Email, nil)
+		if _, err := service.SsoLogin(ctx, callback); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := service.SsoLogin(ctx, callback)
+		expectSsoStatus(t, err, fiber.StatusUnauthorized)
+	})
+
+	t.Run("jit provisioning", func(t *testing.T) {
+		email := "sso-" + testSuffix() + "@example.invalid"
+		response, err := service.SsoLogin(ctx, ssoCallback(t, modules, issuer, email, map[string]interface{}{
+			"name":   "Sso User",
+			"groups": []string{"staff", "pub-maintainers"},
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.AccessToken == "" {
+			t.Fatal("expected the tokens of the user")
+		}
+
+		user := findSsoUser(t, modules, email)
+		if user.Name != "Sso User" || user.IsAdmin || !user.CanWrite {
+			t.Fatalf("unexpected provisioned user %+v", user)
+		}
+	})
+
+	t.Run("groups of an existing user", func(t *testing.T) {
+		// matched regardless of the case of the email, the groups replace the flags the admins set
+		email := strings.ToUpper(existing.Email)
+		steps := []struct {
+			groups   interface{}
+			isAdmin  bool
+			canWrite bool
+		}{
+			{[]string{"pub-admins"}, true, false},
+			{"pub-writers", false, true},
+			{[]string{}, false, false},
+		}
+
+		for _, step := range steps {
+			if _, err := service.SsoLogin(ctx, ssoCallback(t, modules, issuer, email, map[string]interface{}{"groups": step.groups})); err != nil {
+				t.Fatal(err)
+			}
+
+			user := findSsoUser(t, modules, existing.Email)
+			if user.ID != existing.ID || user.IsAdmin != step.isAdmin || user.CanWrite != step.canWrite {
+				t.Fatalf("groups %v: expected admin %t & write %t, got %+v", step.groups, step.isAdmin, step.canWrite, user)
+			}
+		}
+	})
+}
+
+// TestSsoLoginOptions = the callback without jit provisioning, and with SSO_ALLOW_UNVERIFIED_EMAIL
+func TestSsoLoginOptions(t *testing.T) {
+	issuer := newTestIssuer(t)
+	modules := startSsoModules(t, issuer, map[string]string{"SSO_ALLOW_UNVERIFIED_EMAIL": "true"})
+	service := modules.UserModule.Service
+	ctx := context.Background()
+
+	existing := insertTestUser(t, modules, testSuffix())
+
+	t.Run("no user without jit provisioning", func(t *testing.T) {
+		email := "sso-" + testSuffix() + "@example.invalid"
+		_, err := service.SsoLogin(ctx, ssoCallback(t, modules, issuer, email, nil))
+		expectSsoStatus(t, err, fiber.StatusForbidden)
+
+		if err := modules.DbService.Default().Where("email = ?", email).First(&usermodel.UserModel{}).Error; err == nil {
+			t.Fatal("expected no user to be created")
+		}
+	})
+
+	t.Run("no email_verified claim", func(t *testing.T) {
+		if _, err := service.SsoLogin(ctx, ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"email_verified": nil})); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		_, err := service.SsoLogin(ctx, ssoCallback(t, modules, issuer, existing.Email, map[string]interface{}{"email_verified": false}))
+		expectSsoStatus(t, err, fiber.StatusUnauthorized)
+	})
+}
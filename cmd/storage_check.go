@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/jwt"
+	"private-pub-repo/modules/mail"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/pub"
+	"private-pub-repo/modules/pub/pubdto"
+	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/setting"
+	"private-pub-repo/modules/storage"
+	"private-pub-repo/modules/user"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/fx"
+)
+
+func CommandStorageCheck() *cli.Command {
+	return &cli.Command{
+		Name:  "storage:check",
+		Usage: "cross-check pub_versions against stored archives",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "delete-orphans", Usage: "delete archives that have no pub_versions row"},
+			&cli.BoolFlag{Name: "mark-broken", Usage: "mark versions whose archive is missing as broken, and unmark repaired ones"},
+			&cli.DurationFlag{Name: "orphan-min-age", Value: time.Hour, Usage: "only delete orphans older than this"},
+			&cli.BoolFlag{Name: "json", Usage: "print the report as json"},
+		},
+		Action: func(cCtx *cli.Context) error {
+			runStorageCheck(&pubdto.ConsistencyCheckDTO{
+				DeleteOrphans: cCtx.Bool("delete-orphans"),
+				MarkBroken:    cCtx.Bool("mark-broken"),
+				OrphanMinAge:  cCtx.Duration("orphan-min-age"),
+			}, cCtx.Bool("json"))
+			return nil
+		},
+	}
+}
+
+func runStorageCheck(options *pubdto.ConsistencyCheckDTO, printJson bool) {
+	fxApp := fx.New(
+		config.FxModule,
+		storage.FxModule,
+		mail.FxModule,
+		app.FxModule,
+		monitor.FxModule,
+		db.FxModule,
+		jwt.FxModule,
+		setting.FxModule,
+		ratelimit.FxModule,
+		user.FxModule,
+		pubtoken.FxModule,
+		pub.FxModule,
+		fx.Invoke(func(lifeCycle fx.Lifecycle, pubModule *pub.PubModule) {
+			lifeCycle.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					// fx start context has a timeout, listing a big bucket easily takes longer than that
+					applyStorageCheck(context.Background(), pubModule, options, printJson)
+					return nil
+				},
+			})
+		}),
+		fx.NopLogger,
+	)
+
+	fxApp.Run()
+}
+
+func applyStorageCheck(ctx context.Context, pubModule *pub.PubModule, options *pubdto.ConsistencyCheckDTO, printJson bool) {
+	report, err := pubModule.Service.CheckConsistency(ctx, options)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "consistency check failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if printJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printConsistencyReport(report)
+	}
+
+	if !report.IsConsistent() {
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+func printConsistencyReport(report *pubdto.ConsistencyReportDTO) {
+	fmt.Printf("checked %d versions against %d stored objects\n", report.VersionCount, report.ObjectCount)
+
+	fmt.Printf("missing archives: %d\n", len(report.MissingArchives))
+	for _, item := range report.MissingArchives {
+		fmt.Printf("  %s %s (%s)\n", item.PackageName, item.Version, item.Key)
+	}
+
+	fmt.Printf("orphan archives: %d\n", len(report.OrphanArchives))
+	for _, item := range report.OrphanArchives {
+		fmt.Printf("  %s (%d bytes)\n", item.Key, item.Size)
+	}
+
+	fmt.Printf("orphans deleted: %d\n", report.DeletedOrphans)
+	fmt.Printf("versions marked broken: %d\n", report.MarkedBroken)
+	fmt.Printf("versions restored: %d\n", report.RestoredVersions)
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"private-pub-repo/modules/oidc"
+	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/modules/trustedpublisher/trustedpublisherdto"
+	"private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const testAudience = "https://pub.example.invalid"
+
+// testIssuer = stand-in ci or sso provider serving its openid configuration, the public key of its signing key &
+// the token endpoint of the sso logins it signed in
+type testIssuer struct {
+	server *httptest.Server
+	key    ed25519.PrivateKey
+	mutex  sync.Mutex
+	// codes = the sso logins by authorization code, until the token endpoint redeems it
+	codes map[string]*testSsoLogin
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuer{key: key, codes: map[string]*testSsoLogin{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Discovery{
+			Issuer:                issuer.server.URL,
+			JwksUri:               issuer.server.URL + "/jwks",
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", issuer.tokenEndpoint)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		x := base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{"kty": "OKP", "crv": "Ed25519", "kid": "key1", "use": "sig", "x": x}},
+		})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+// token = id token of a tag build of acme/app, its own jti, changes replace its claims or remove them when nil
+func (issuer *testIssuer) token(t *testing.T, changes map[string]interface{}) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := jwtlib.MapClaims{
+		"iss": issuer.server.URL,
+		"aud": testAudience,
+		"sub": "repo:acme/app:ref:refs/tags/v1.0.0",
+		"ref": "refs/tags/v1.0.0",
+		"jti": uuid.NewString(),
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+
+	signed, err := issuer.sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (issuer *testIssuer) sign(claims jwtlib.MapClaims) (string, error) {
+	token := jwtlib.NewWithClaims(jwtlib.SigningMethodEdDSA, claims)
+	token.Header["kid"] = "key1"
+	return token.SignedString(issuer.key)
+}
+
+func expectUnauthenticated(t *testing.T, err error) {
+	t.Helper()
+
+	var fiberError *fiber.Error
+	if !errors.As(err, &fiberError) || fiberError.Code != fiber.StatusUnauthorized {
+		t.Fatalf("expected the exchange to be refused, got %v", err)
+	}
+}
+
+// TestTrustedPublishing exchanges the oidc tokens of a stand-in issuer, the pub token can only publish its package
+func TestTrustedPublishing(t *testing.T) {
+	issuer := newTestIssuer(t)
+	t.Setenv("TRUSTED_PUBLISHING_ALLOW_HTTP", "true")
+	modules := startDatabaseModules(t)
+	service := modules.TrustedPublisherModule.Service
+	ctx := context.Background()
+
+	suffix := testSuffix()
+	packageName := "trusted_" + suffix
+	otherPackage := "other_" + suffix
+
+	if _, err := service.Insert(ctx, &trustedpublishermodel.TrustedPublisherModel{
+		Package:  packageName,
+		Issuer:   issuer.server.URL,
+		Audience: testAudience,
+		Subject:  "repo:acme/app:*",
+		Claims:   map[string]string{"ref": "refs/tags/v*"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	refused := []struct {
+		name    string
+		request trustedpublisherdto.ExchangeDTO
+	}{
+		{"malformed token", trustedpublisherdto.ExchangeDTO{Token: "not.a.token", Package: packageName}},
+		{"wrong issuer", trustedpublisherdto.ExchangeDTO{Token: newTestIssuer(t).token(t, nil), Package: packageName}},
+		{"signed by another issuer", trustedpublisherdto.ExchangeDTO{Token: newTestIssuer(t).token(t, map[string]interface{}{"iss": issuer.server.URL}), Package: packageName}},
+		{"issuer claim of another issuer", trustedpublisherdto.ExchangeDTO{Token: issuer.token(t, map[string]interface{}{"iss": "https://token.actions.githubusercontent.com"}), Package: packageName}},
+		{"wrong audience", trustedpublisherdto.ExchangeDTO{Token: issuer.token(t, map[string]interface{}{"aud": "https://other.example.invalid"}), Package: packageName}},
+		{"expired token", trustedpublisherdto.ExchangeDTO{Token: issuer.token(t, map[string]interface{}{"iat": time.Now().Add(-time.Hour).Unix(), "exp": time.Now().Add(-10 * time.Minute).Unix()}), Package: packageName}},
+		{"subject of another repository", trustedpublisherdto.ExchangeDTO{Token: issuer.token(t, map[string]interface{}{"sub": "repo:acme/app-fork:ref:refs/tags/v1.0.0"}), Package: packageName}},
+		{"claim of a branch build", trustedpublisherdto.ExchangeDTO{Token: issuer.token(t, map[string]interface{}{"ref": "refs/heads/main"}), Package: packageName}},
+		{"package without trusted publisher", trustedpublisherdto.ExchangeDTO{Token: issuer.token(t, nil), Package: otherPackage}},
+	}
+
+	for _, testCase := range refused {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := service.Exchange(ctx, &testCase.request)
+			expectUnauthenticated(t, err)
+		})
+	}
+
+	var token string
+	t.Run("exchange", func(t *testing.T) {
+		response, err := service.Exchange(ctx, &trustedpublisherdto.ExchangeDTO{Token: issuer.token(t, nil), Package: packageName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Package != packageName || response.ExpiredAt.Before(time.Now()) {
+			t.Fatalf("unexpected response %+v", response)
+		}
+		token = response.Token
+	})
+	if token == "" {
+		t.FailNow()
+	}
+
+	t.Run("replayed token", func(t *testing.T) {
+		oidcToken := issuer.token(t, nil)
+		if _, err := service.Exchange(ctx, &trustedpublisherdto.ExchangeDTO{Token: oidcToken, Package: packageName}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := service.Exchange(ctx, &trustedpublisherdto.ExchangeDTO{Token: oidcToken, Package: packageName})
+		expectUnauthenticated(t, err)
+
+		// the same jti in another token is the same token
+		claims := jwtlib.MapClaims{}
+		if _, _, err := jwtlib.NewParser().ParseUnverified(oidcToken, claims); err != nil {
+			t.Fatal(err)
+		}
+		replayed := issuer.token(t, map[string]interface{}{"jti": claims["jti"]})
+		_, err = service.Exchange(ctx, &trustedpublisherdto.ExchangeDTO{Token: replayed, Package: packageName})
+		expectUnauthenticated(t, err)
+	})
+
+	pubToken, err := modules.PubTokenModule.Service.FindByToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubToken.Package == nil || *pubToken.Package != packageName || pubToken.Write == nil || !*pubToken.Write {
+		t.Fatal("expected a write token limited to the package")
+	}
+
+	t.Run("publish another package", func(t *testing.T) {
+		err := modules.PubModule.Service.UploadVersion(ctx, archiveFile(t, otherPackage, "1.0.0"), nil, pubToken.Package)
+		if err == nil || !strings.Contains(err.Error(), "can only publish package "+packageName) {
+			t.Fatalf("expected the archive of another package to be refused, got %v", err)
+		}
+
+		err = modules.DbService.Default().Where("name = ?", otherPackage).First(&pubmodel.PubPackageModel{}).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("expected no package %s, got %v", otherPackage, err)
+		}
+	})
+}
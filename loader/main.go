@@ -6,18 +6,35 @@ import (
 	"os"
 	"private-pub-repo/modules/pub/pubmodel"
 	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
 	"private-pub-repo/modules/user/usermodel"
+	"regexp"
 
 	"ariga.io/atlas-provider-gorm/gormschema"
 )
 
+var (
+	mysqlUuidType    = regexp.MustCompile("(`[a-z_]+`) uuid")
+	mysqlJsonDefault = regexp.MustCompile(`(JSON NOT NULL) DEFAULT ('[^']*')`)
+)
+
 func main() {
-	stmts, err := gormschema.New("postgres").Load(
+	// dialect as the first argument, see the envs of atlas.hcl
+	dialect := "postgres"
+	if len(os.Args) > 1 {
+		dialect = os.Args[1]
+	}
+
+	stmts, err := gormschema.New(dialect).Load(
 		// user module
 		&usermodel.UserModel{},
 		&usermodel.UserOtpModel{},
+		&usermodel.UserSsoStateModel{},
 		// pubtoken module
 		&pubtokenmodel.PubTokenModel{},
+		// trustedpublisher module
+		&trustedpublishermodel.TrustedPublisherModel{},
+		&trustedpublishermodel.TrustedPublisherExchangeModel{},
 		// pub module
 		&pubmodel.PubPackageModel{},
 		&pubmodel.PubVersionModel{},
@@ -26,6 +43,13 @@ func main() {
 		fmt.Fprintf(os.Stderr, "failed to load gorm schema: %v\n", err)
 		os.Exit(1)
 	}
-	io.WriteString(os.Stdout, `CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
+	if dialect == "mysql" {
+		// same adaptations as the mysql dialector of the db module
+		stmts = mysqlUuidType.ReplaceAllString(stmts, "$1 char(36)")
+		stmts = mysqlJsonDefault.ReplaceAllString(stmts, "$1 DEFAULT ($2)")
+	}
+	if dialect == "postgres" {
+		io.WriteString(os.Stdout, `CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
+	}
 	io.WriteString(os.Stdout, stmts)
 }
@@ -17,12 +17,35 @@ func main() {
 		cmd.CommandManual(),
 		cmd.CommandFx(),
 		cmd.CommandDbSeed(),
+		cmd.CommandDbMigrate(),
+		cmd.CommandDbStatus(),
+		cmd.CommandDbRollback(),
+		cmd.CommandStorageCheck(),
+		cmd.CommandBackupExport(),
+		cmd.CommandBackupRestore(),
+		cmd.CommandPubImport(),
+		cmd.CommandBundleExport(),
+		cmd.CommandBundleImport(),
+		cmd.CommandMirrorGenerate(),
+		cmd.CommandConfigCheck(),
+		cmd.CommandJwtKeygen(),
+		cmd.CommandJwtKeys(),
 	}
 
 	app := &cli.App{
 		Commands: commands,
 		Name:     "apiserver",
-		Usage:    "manual, fx, db:seed",
+		Usage:    "manual, fx, db:seed, db:migrate, db:status, db:rollback, storage:check, backup:export, backup:restore, pub:import, bundle:export, bundle:import, mirror:generate, config:check, jwt:keygen, jwt:keys",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "config", Usage: "config `file` (yaml or toml), envs still override its values", EnvVars: []string{"CONFIG_FILE"}},
+		},
+		Before: func(cli *cli.Context) error {
+			// read by every module through `config.FilePath`
+			if path := cli.String("config"); path != "" {
+				return os.Setenv("CONFIG_FILE", path)
+			}
+			return nil
+		},
 		Action: func(cli *cli.Context) error {
 			fmt.Printf("%s version:%s\n", cli.App.Name, "3.0")
 			return nil
@@ -0,0 +1,2 @@
+-- Modify "pub_versions" table
+ALTER TABLE "pub_versions" ADD COLUMN "broken" boolean NOT NULL DEFAULT false;
@@ -0,0 +1,9 @@
+-- Create "rate_limits" table
+CREATE TABLE "rate_limits" (
+  "key" character varying(191) NOT NULL,
+  "hits" bigint NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("key")
+);
+-- Create index "idx_rate_limits_expires_at" to table: "rate_limits"
+CREATE INDEX "idx_rate_limits_expires_at" ON "rate_limits" ("expires_at");
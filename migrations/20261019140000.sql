@@ -0,0 +1,4 @@
+-- Modify "pub_tokens" table
+ALTER TABLE "pub_tokens" ADD COLUMN "token_hash" character varying(64) NULL, ADD COLUMN "token_hint" character varying(16) NULL;
+-- Create index "idx_pub_tokens_token_hash" to table: "pub_tokens"
+CREATE UNIQUE INDEX "idx_pub_tokens_token_hash" ON "pub_tokens" ("token_hash");
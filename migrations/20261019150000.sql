@@ -0,0 +1,18 @@
+-- Modify "pub_tokens" table
+ALTER TABLE "pub_tokens" ADD COLUMN "last_used_at" timestamptz NULL, ADD COLUMN "last_used_ip" character varying(64) NULL, ADD COLUMN "last_used_user_agent" character varying(255) NULL, ADD COLUMN "last_used_operation" character varying(16) NULL;
+-- Create index "idx_pub_tokens_last_used_at" to table: "pub_tokens"
+CREATE INDEX "idx_pub_tokens_last_used_at" ON "pub_tokens" ("last_used_at");
+-- Create "pub_token_usages" table
+CREATE TABLE "pub_token_usages" (
+  "token_id" uuid NOT NULL,
+  "day" character varying(10) NOT NULL,
+  "operation" character varying(16) NOT NULL,
+  "ip" character varying(64) NOT NULL,
+  "user_agent" character varying(255) NOT NULL,
+  "count" bigint NOT NULL,
+  "last_used_at" timestamptz NOT NULL,
+  PRIMARY KEY ("token_id", "day", "operation", "ip"),
+  CONSTRAINT "fk_pub_token_usages_token" FOREIGN KEY ("token_id") REFERENCES "pub_tokens" ("id") ON UPDATE CASCADE ON DELETE CASCADE
+);
+-- Create index "idx_pub_token_usages_day" to table: "pub_token_usages"
+CREATE INDEX "idx_pub_token_usages_day" ON "pub_token_usages" ("day");
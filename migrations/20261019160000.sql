@@ -0,0 +1,4 @@
+-- Modify "users" table
+ALTER TABLE "users" ADD COLUMN "is_service_account" boolean NOT NULL DEFAULT false, ADD COLUMN "owner" character varying(255) NULL;
+-- Create index "idx_users_is_service_account" to table: "users"
+CREATE INDEX "idx_users_is_service_account" ON "users" ("is_service_account");
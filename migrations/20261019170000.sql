@@ -0,0 +1,20 @@
+-- Modify "pub_tokens" table
+ALTER TABLE "pub_tokens" ADD COLUMN "package" character varying(255) NULL;
+-- Create "trusted_publishers" table
+CREATE TABLE "trusted_publishers" (
+  "id" uuid NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  "deleted_at" timestamptz NULL,
+  "package" character varying(255) NOT NULL,
+  "issuer" character varying(255) NOT NULL,
+  "audience" character varying(255) NOT NULL,
+  "subject" character varying(255) NOT NULL,
+  "claims" text NULL,
+  "remarks" character varying(255) NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_trusted_publishers_deleted_at" to table: "trusted_publishers"
+CREATE INDEX "idx_trusted_publishers_deleted_at" ON "trusted_publishers" ("deleted_at");
+-- Create index "idx_trusted_publishers_package" to table: "trusted_publishers"
+CREATE INDEX "idx_trusted_publishers_package" ON "trusted_publishers" ("package");
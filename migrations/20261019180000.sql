@@ -0,0 +1,10 @@
+-- Create "user_sso_states" table
+CREATE TABLE "user_sso_states" (
+  "state" character varying(64) NOT NULL,
+  "nonce" character varying(64) NOT NULL,
+  "code_verifier" character varying(64) NOT NULL,
+  "expired_at" timestamptz NOT NULL,
+  PRIMARY KEY ("state")
+);
+-- Create index "idx_user_sso_states_expired_at" to table: "user_sso_states"
+CREATE INDEX "idx_user_sso_states_expired_at" ON "user_sso_states" ("expired_at");
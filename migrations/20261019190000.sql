@@ -0,0 +1,8 @@
+-- Create "trusted_publisher_exchanges" table
+CREATE TABLE "trusted_publisher_exchanges" (
+  "token_hash" character varying(64) NOT NULL,
+  "expired_at" timestamptz NOT NULL,
+  PRIMARY KEY ("token_hash")
+);
+-- Create index "idx_trusted_publisher_exchanges_expired_at" to table: "trusted_publisher_exchanges"
+CREATE INDEX "idx_trusted_publisher_exchanges_expired_at" ON "trusted_publisher_exchanges" ("expired_at");
@@ -0,0 +1,6 @@
+-- Drop "pub_versions" table
+DROP TABLE "pub_versions";
+-- Drop "pub_packages" table
+DROP TABLE "pub_packages";
+-- Drop "pub_tokens" table
+DROP TABLE "pub_tokens";
@@ -0,0 +1,2 @@
+-- Drop "user_otps" table
+DROP TABLE "user_otps";
@@ -0,0 +1,4 @@
+-- Modify "pub_tokens" table
+ALTER TABLE "pub_tokens" ALTER COLUMN "id" SET DEFAULT uuid_generate_v4();
+-- Modify "users" table
+ALTER TABLE "users" ALTER COLUMN "id" SET DEFAULT uuid_generate_v4();
@@ -0,0 +1,2 @@
+-- Drop "rate_limits" table
+DROP TABLE "rate_limits";
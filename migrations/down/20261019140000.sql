@@ -0,0 +1,4 @@
+-- Drop index "idx_pub_tokens_token_hash" from table: "pub_tokens"
+DROP INDEX "idx_pub_tokens_token_hash";
+-- Modify "pub_tokens" table
+ALTER TABLE "pub_tokens" DROP COLUMN "token_hash", DROP COLUMN "token_hint";
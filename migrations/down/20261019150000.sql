@@ -0,0 +1,6 @@
+-- Drop "pub_token_usages" table
+DROP TABLE "pub_token_usages";
+-- Drop index "idx_pub_tokens_last_used_at" from table: "pub_tokens"
+DROP INDEX "idx_pub_tokens_last_used_at";
+-- Modify "pub_tokens" table
+ALTER TABLE "pub_tokens" DROP COLUMN "last_used_at", DROP COLUMN "last_used_ip", DROP COLUMN "last_used_user_agent", DROP COLUMN "last_used_operation";
@@ -0,0 +1,4 @@
+-- Drop index "idx_users_is_service_account" from table: "users"
+DROP INDEX "idx_users_is_service_account";
+-- Modify "users" table
+ALTER TABLE "users" DROP COLUMN "is_service_account", DROP COLUMN "owner";
@@ -0,0 +1,4 @@
+-- Drop "trusted_publishers" table
+DROP TABLE "trusted_publishers";
+-- Modify "pub_tokens" table
+ALTER TABLE "pub_tokens" DROP COLUMN "package";
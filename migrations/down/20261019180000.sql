@@ -0,0 +1,2 @@
+-- Drop "user_sso_states" table
+DROP TABLE "user_sso_states";
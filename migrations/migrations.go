@@ -0,0 +1,30 @@
+// Package migrations embeds the atlas migration directories, so the binary can migrate without atlas.
+// `down/` of each directory holds the rollback of the migration with the same version, atlas ignores it.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed *.sql atlas.sum down/*.sql
+//go:embed sqlite/*.sql sqlite/atlas.sum sqlite/down/*.sql
+//go:embed mysql/*.sql mysql/atlas.sum mysql/down/*.sql
+var files embed.FS
+
+// directories by gorm dialect name, see the envs of atlas.hcl
+var directories = map[string]string{
+	"postgres": ".",
+	"sqlite":   "sqlite",
+	"mysql":    "mysql",
+}
+
+// Dir = migration directory of the dialect
+func Dir(dialect string) (fs.FS, error) {
+	directory, ok := directories[dialect]
+	if !ok {
+		return nil, fmt.Errorf("no migrations for dialect %s", dialect)
+	}
+	return fs.Sub(files, directory)
+}
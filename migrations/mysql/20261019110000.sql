@@ -0,0 +1,71 @@
+-- Create "users" table
+CREATE TABLE `users` (
+  `id` char(36) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `deleted_at` datetime(3) NULL,
+  `name` longtext NOT NULL,
+  `email` varchar(191) NOT NULL,
+  `password` longtext NOT NULL,
+  `is_admin` bool NOT NULL DEFAULT 0,
+  `can_write` bool NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `idx_users_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `uni_users_email` (`email`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
+-- Create "user_otps" table
+CREATE TABLE `user_otps` (
+  `id` char(36) NOT NULL,
+  `purpose` longtext NOT NULL,
+  `otp` longtext NOT NULL,
+  `expired_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_users_user_otp` FOREIGN KEY (`id`) REFERENCES `users` (`id`) ON UPDATE NO ACTION ON DELETE NO ACTION
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
+-- Create "pub_tokens" table
+CREATE TABLE `pub_tokens` (
+  `id` char(36) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `deleted_at` datetime(3) NULL,
+  `remarks` longtext NOT NULL,
+  `write` bool NOT NULL DEFAULT 0,
+  `expired_at` datetime(3) NOT NULL,
+  `user_id` char(36) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `fk_pub_tokens_user` (`user_id`),
+  INDEX `idx_pub_tokens_deleted_at` (`deleted_at`),
+  CONSTRAINT `fk_pub_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON UPDATE CASCADE ON DELETE SET NULL
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
+-- Create "pub_packages" table
+CREATE TABLE `pub_packages` (
+  `name` varchar(191) NOT NULL,
+  `private` bool NOT NULL DEFAULT 1,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`name`),
+  INDEX `idx_pub_packages_deleted_at` (`deleted_at`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
+-- Create "pub_versions" table
+CREATE TABLE `pub_versions` (
+  `package_name` varchar(191) NOT NULL,
+  `version` varchar(191) NOT NULL,
+  `version_number_major` bigint unsigned NOT NULL,
+  `version_number_minor` bigint unsigned NOT NULL,
+  `version_number_patch` bigint unsigned NOT NULL,
+  `prerelease` bool NOT NULL DEFAULT 0,
+  `broken` bool NOT NULL DEFAULT 0,
+  `pubspec` json NOT NULL DEFAULT (_utf8mb4'{}'),
+  `uploader_id` char(36) NULL,
+  `readme` text NULL,
+  `changelog` text NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `deleted_at` datetime(3) NULL,
+  INDEX `fk_pub_versions_uploader` (`uploader_id`),
+  INDEX `idx_pub_versions_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_pub_versions_pubversion` (`package_name`, `version`),
+  CONSTRAINT `fk_pub_packages_versions` FOREIGN KEY (`package_name`) REFERENCES `pub_packages` (`name`) ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT `fk_pub_versions_uploader` FOREIGN KEY (`uploader_id`) REFERENCES `users` (`id`) ON UPDATE CASCADE ON DELETE SET NULL
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1,22 @@
+-- Create "settings" table
+CREATE TABLE `settings` (
+  `key` varchar(191) NOT NULL,
+  `value` longtext NOT NULL,
+  `updated_by` char(36) NULL,
+  `updated_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`key`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
+-- Create "setting_histories" table
+CREATE TABLE `setting_histories` (
+  `id` char(36) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `deleted_at` datetime(3) NULL,
+  `key` varchar(191) NOT NULL,
+  `old_value` longtext NULL,
+  `new_value` longtext NULL,
+  `changed_by` char(36) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_setting_histories_deleted_at` (`deleted_at`),
+  INDEX `idx_setting_histories_key` (`key`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1,8 @@
+-- Create "rate_limits" table
+CREATE TABLE `rate_limits` (
+  `key` varchar(191) NOT NULL,
+  `hits` bigint NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`key`),
+  INDEX `idx_rate_limits_expires_at` (`expires_at`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1,2 @@
+-- Modify "pub_tokens" table
+ALTER TABLE `pub_tokens` ADD COLUMN `token_hash` varchar(64) NULL, ADD COLUMN `token_hint` varchar(16) NULL, ADD UNIQUE INDEX `idx_pub_tokens_token_hash` (`token_hash`);
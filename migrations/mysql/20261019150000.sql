@@ -0,0 +1,15 @@
+-- Modify "pub_tokens" table
+ALTER TABLE `pub_tokens` ADD COLUMN `last_used_at` datetime(3) NULL, ADD COLUMN `last_used_ip` varchar(64) NULL, ADD COLUMN `last_used_user_agent` varchar(255) NULL, ADD COLUMN `last_used_operation` varchar(16) NULL, ADD INDEX `idx_pub_tokens_last_used_at` (`last_used_at`);
+-- Create "pub_token_usages" table
+CREATE TABLE `pub_token_usages` (
+  `token_id` char(36) NOT NULL,
+  `day` varchar(10) NOT NULL,
+  `operation` varchar(16) NOT NULL,
+  `ip` varchar(64) NOT NULL,
+  `user_agent` varchar(255) NOT NULL,
+  `count` bigint NOT NULL,
+  `last_used_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`token_id`, `day`, `operation`, `ip`),
+  INDEX `idx_pub_token_usages_day` (`day`),
+  CONSTRAINT `fk_pub_token_usages_token` FOREIGN KEY (`token_id`) REFERENCES `pub_tokens` (`id`) ON UPDATE CASCADE ON DELETE CASCADE
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
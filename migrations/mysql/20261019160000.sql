@@ -0,0 +1,2 @@
+-- Modify "users" table
+ALTER TABLE `users` ADD COLUMN `is_service_account` bool NOT NULL DEFAULT 0, ADD COLUMN `owner` varchar(255) NULL, ADD INDEX `idx_users_is_service_account` (`is_service_account`);
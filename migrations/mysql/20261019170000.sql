@@ -0,0 +1,18 @@
+-- Modify "pub_tokens" table
+ALTER TABLE `pub_tokens` ADD COLUMN `package` varchar(255) NULL;
+-- Create "trusted_publishers" table
+CREATE TABLE `trusted_publishers` (
+  `id` char(36) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `deleted_at` datetime(3) NULL,
+  `package` varchar(255) NOT NULL,
+  `issuer` varchar(255) NOT NULL,
+  `audience` varchar(255) NOT NULL,
+  `subject` varchar(255) NOT NULL,
+  `claims` text NULL,
+  `remarks` varchar(255) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_trusted_publishers_deleted_at` (`deleted_at`),
+  INDEX `idx_trusted_publishers_package` (`package`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1,9 @@
+-- Create "user_sso_states" table
+CREATE TABLE `user_sso_states` (
+  `state` varchar(64) NOT NULL,
+  `nonce` varchar(64) NOT NULL,
+  `code_verifier` varchar(64) NOT NULL,
+  `expired_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`state`),
+  INDEX `idx_user_sso_states_expired_at` (`expired_at`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1,7 @@
+-- Create "trusted_publisher_exchanges" table
+CREATE TABLE `trusted_publisher_exchanges` (
+  `token_hash` varchar(64) NOT NULL,
+  `expired_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`token_hash`),
+  INDEX `idx_trusted_publisher_exchanges_expired_at` (`expired_at`)
+) CHARSET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
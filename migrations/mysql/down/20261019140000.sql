@@ -0,0 +1,2 @@
+-- Modify "pub_tokens" table
+ALTER TABLE `pub_tokens` DROP INDEX `idx_pub_tokens_token_hash`, DROP COLUMN `token_hash`, DROP COLUMN `token_hint`;
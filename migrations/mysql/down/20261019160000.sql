@@ -0,0 +1,2 @@
+-- Modify "users" table
+ALTER TABLE `users` DROP INDEX `idx_users_is_service_account`, DROP COLUMN `is_service_account`, DROP COLUMN `owner`;
@@ -0,0 +1,75 @@
+-- Create "users" table
+CREATE TABLE `users` (
+  `id` uuid NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `deleted_at` datetime NULL,
+  `name` text NOT NULL,
+  `email` text NOT NULL,
+  `password` text NOT NULL,
+  `is_admin` numeric NOT NULL DEFAULT false,
+  `can_write` numeric NOT NULL DEFAULT false,
+  PRIMARY KEY (`id`)
+);
+-- Create index "uni_users_email" to table: "users"
+CREATE UNIQUE INDEX `uni_users_email` ON `users` (`email`);
+-- Create index "idx_users_deleted_at" to table: "users"
+CREATE INDEX `idx_users_deleted_at` ON `users` (`deleted_at`);
+-- Create "user_otps" table
+CREATE TABLE `user_otps` (
+  `id` uuid NOT NULL,
+  `purpose` text NOT NULL,
+  `otp` text NOT NULL,
+  `expired_at` datetime NULL,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_users_user_otp` FOREIGN KEY (`id`) REFERENCES `users` (`id`) ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create "pub_tokens" table
+CREATE TABLE `pub_tokens` (
+  `id` uuid NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `deleted_at` datetime NULL,
+  `remarks` text NOT NULL,
+  `write` numeric NOT NULL DEFAULT false,
+  `expired_at` datetime NOT NULL,
+  `user_id` uuid NULL,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_pub_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON UPDATE CASCADE ON DELETE SET NULL
+);
+-- Create index "idx_pub_tokens_deleted_at" to table: "pub_tokens"
+CREATE INDEX `idx_pub_tokens_deleted_at` ON `pub_tokens` (`deleted_at`);
+-- Create "pub_packages" table
+CREATE TABLE `pub_packages` (
+  `name` text NOT NULL,
+  `private` numeric NOT NULL DEFAULT true,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `deleted_at` datetime NULL,
+  PRIMARY KEY (`name`)
+);
+-- Create index "idx_pub_packages_deleted_at" to table: "pub_packages"
+CREATE INDEX `idx_pub_packages_deleted_at` ON `pub_packages` (`deleted_at`);
+-- Create "pub_versions" table
+CREATE TABLE `pub_versions` (
+  `package_name` text NOT NULL,
+  `version` text NOT NULL,
+  `version_number_major` integer NOT NULL,
+  `version_number_minor` integer NOT NULL,
+  `version_number_patch` integer NOT NULL,
+  `prerelease` numeric NOT NULL DEFAULT false,
+  `broken` numeric NOT NULL DEFAULT false,
+  `pubspec` json NOT NULL DEFAULT '{}',
+  `uploader_id` uuid NULL,
+  `readme` text NULL,
+  `changelog` text NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `deleted_at` datetime NULL,
+  CONSTRAINT `fk_pub_versions_uploader` FOREIGN KEY (`uploader_id`) REFERENCES `users` (`id`) ON UPDATE CASCADE ON DELETE SET NULL,
+  CONSTRAINT `fk_pub_packages_versions` FOREIGN KEY (`package_name`) REFERENCES `pub_packages` (`name`) ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_pub_versions_deleted_at" to table: "pub_versions"
+CREATE INDEX `idx_pub_versions_deleted_at` ON `pub_versions` (`deleted_at`);
+-- Create index "idx_pub_versions_pubversion" to table: "pub_versions"
+CREATE UNIQUE INDEX `idx_pub_versions_pubversion` ON `pub_versions` (`package_name`, `version`);
@@ -0,0 +1,24 @@
+-- Create "settings" table
+CREATE TABLE `settings` (
+  `key` text NOT NULL,
+  `value` text NOT NULL,
+  `updated_by` uuid NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`key`)
+);
+-- Create "setting_histories" table
+CREATE TABLE `setting_histories` (
+  `id` uuid NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `deleted_at` datetime NULL,
+  `key` text NOT NULL,
+  `old_value` text NULL,
+  `new_value` text NULL,
+  `changed_by` uuid NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_setting_histories_deleted_at" to table: "setting_histories"
+CREATE INDEX `idx_setting_histories_deleted_at` ON `setting_histories` (`deleted_at`);
+-- Create index "idx_setting_histories_key" to table: "setting_histories"
+CREATE INDEX `idx_setting_histories_key` ON `setting_histories` (`key`);
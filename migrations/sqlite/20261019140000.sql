@@ -0,0 +1,6 @@
+-- Add column "token_hash" to table: "pub_tokens"
+ALTER TABLE `pub_tokens` ADD COLUMN `token_hash` text NULL;
+-- Add column "token_hint" to table: "pub_tokens"
+ALTER TABLE `pub_tokens` ADD COLUMN `token_hint` text NULL;
+-- Create index "idx_pub_tokens_token_hash" to table: "pub_tokens"
+CREATE UNIQUE INDEX `idx_pub_tokens_token_hash` ON `pub_tokens` (`token_hash`);
@@ -0,0 +1,24 @@
+-- Add column "last_used_at" to table: "pub_tokens"
+ALTER TABLE `pub_tokens` ADD COLUMN `last_used_at` datetime NULL;
+-- Add column "last_used_ip" to table: "pub_tokens"
+ALTER TABLE `pub_tokens` ADD COLUMN `last_used_ip` text NULL;
+-- Add column "last_used_user_agent" to table: "pub_tokens"
+ALTER TABLE `pub_tokens` ADD COLUMN `last_used_user_agent` text NULL;
+-- Add column "last_used_operation" to table: "pub_tokens"
+ALTER TABLE `pub_tokens` ADD COLUMN `last_used_operation` text NULL;
+-- Create index "idx_pub_tokens_last_used_at" to table: "pub_tokens"
+CREATE INDEX `idx_pub_tokens_last_used_at` ON `pub_tokens` (`last_used_at`);
+-- Create "pub_token_usages" table
+CREATE TABLE `pub_token_usages` (
+  `token_id` uuid NOT NULL,
+  `day` text NOT NULL,
+  `operation` text NOT NULL,
+  `ip` text NOT NULL,
+  `user_agent` text NOT NULL,
+  `count` integer NOT NULL,
+  `last_used_at` datetime NOT NULL,
+  PRIMARY KEY (`token_id`, `day`, `operation`, `ip`),
+  CONSTRAINT `fk_pub_token_usages_token` FOREIGN KEY (`token_id`) REFERENCES `pub_tokens` (`id`) ON UPDATE CASCADE ON DELETE CASCADE
+);
+-- Create index "idx_pub_token_usages_day" to table: "pub_token_usages"
+CREATE INDEX `idx_pub_token_usages_day` ON `pub_token_usages` (`day`);
@@ -0,0 +1,6 @@
+-- Add column "is_service_account" to table: "users"
+ALTER TABLE `users` ADD COLUMN `is_service_account` numeric NOT NULL DEFAULT false;
+-- Add column "owner" to table: "users"
+ALTER TABLE `users` ADD COLUMN `owner` text NULL;
+-- Create index "idx_users_is_service_account" to table: "users"
+CREATE INDEX `idx_users_is_service_account` ON `users` (`is_service_account`);
@@ -0,0 +1,20 @@
+-- Add column "package" to table: "pub_tokens"
+ALTER TABLE `pub_tokens` ADD COLUMN `package` text NULL;
+-- Create "trusted_publishers" table
+CREATE TABLE `trusted_publishers` (
+  `id` uuid NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `deleted_at` datetime NULL,
+  `package` text NOT NULL,
+  `issuer` text NOT NULL,
+  `audience` text NOT NULL,
+  `subject` text NOT NULL,
+  `claims` text NULL,
+  `remarks` text NULL,
+  PRIMARY KEY (`id`)
+);
+-- Create index "idx_trusted_publishers_deleted_at" to table: "trusted_publishers"
+CREATE INDEX `idx_trusted_publishers_deleted_at` ON `trusted_publishers` (`deleted_at`);
+-- Create index "idx_trusted_publishers_package" to table: "trusted_publishers"
+CREATE INDEX `idx_trusted_publishers_package` ON `trusted_publishers` (`package`);
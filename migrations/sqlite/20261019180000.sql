@@ -0,0 +1,10 @@
+-- Create "user_sso_states" table
+CREATE TABLE `user_sso_states` (
+  `state` text NOT NULL,
+  `nonce` text NOT NULL,
+  `code_verifier` text NOT NULL,
+  `expired_at` datetime NOT NULL,
+  PRIMARY KEY (`state`)
+);
+-- Create index "idx_user_sso_states_expired_at" to table: "user_sso_states"
+CREATE INDEX `idx_user_sso_states_expired_at` ON `user_sso_states` (`expired_at`);
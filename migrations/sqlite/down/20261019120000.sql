@@ -0,0 +1,4 @@
+-- Drop "setting_histories" table
+DROP TABLE `setting_histories`;
+-- Drop "settings" table
+DROP TABLE `settings`;
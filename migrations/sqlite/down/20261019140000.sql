@@ -0,0 +1,6 @@
+-- Drop index "idx_pub_tokens_token_hash" from table: "pub_tokens"
+DROP INDEX `idx_pub_tokens_token_hash`;
+-- Drop column "token_hint" from table: "pub_tokens"
+ALTER TABLE `pub_tokens` DROP COLUMN `token_hint`;
+-- Drop column "token_hash" from table: "pub_tokens"
+ALTER TABLE `pub_tokens` DROP COLUMN `token_hash`;
@@ -0,0 +1,12 @@
+-- Drop "pub_token_usages" table
+DROP TABLE `pub_token_usages`;
+-- Drop index "idx_pub_tokens_last_used_at" from table: "pub_tokens"
+DROP INDEX `idx_pub_tokens_last_used_at`;
+-- Drop column "last_used_operation" from table: "pub_tokens"
+ALTER TABLE `pub_tokens` DROP COLUMN `last_used_operation`;
+-- Drop column "last_used_user_agent" from table: "pub_tokens"
+ALTER TABLE `pub_tokens` DROP COLUMN `last_used_user_agent`;
+-- Drop column "last_used_ip" from table: "pub_tokens"
+ALTER TABLE `pub_tokens` DROP COLUMN `last_used_ip`;
+-- Drop column "last_used_at" from table: "pub_tokens"
+ALTER TABLE `pub_tokens` DROP COLUMN `last_used_at`;
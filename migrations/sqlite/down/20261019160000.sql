@@ -0,0 +1,6 @@
+-- Drop index "idx_users_is_service_account" from table: "users"
+DROP INDEX `idx_users_is_service_account`;
+-- Drop column "owner" from table: "users"
+ALTER TABLE `users` DROP COLUMN `owner`;
+-- Drop column "is_service_account" from table: "users"
+ALTER TABLE `users` DROP COLUMN `is_service_account`;
@@ -0,0 +1,4 @@
+-- Drop "trusted_publishers" table
+DROP TABLE `trusted_publishers`;
+-- Drop column "package" from table: "pub_tokens"
+ALTER TABLE `pub_tokens` DROP COLUMN `package`;
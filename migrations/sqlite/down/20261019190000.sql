@@ -0,0 +1,2 @@
+-- Drop "trusted_publisher_exchanges" table
+DROP TABLE `trusted_publisher_exchanges`;
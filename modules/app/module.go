@@ -15,9 +15,14 @@ type AppModule struct {
 	Validator       *validator.Validate
 }
 
-func NewFiber(responseService ResponseService) *fiber.App {
+func NewFiber(responseService ResponseService, config config.ConfigService) *fiber.App {
 	return fiber.New(fiber.Config{
 		ErrorHandler: responseService.ErrorHandler,
+		// package uploads are limited by the `upload_size_limit` setting, this is the hard limit
+		BodyLimit: config.Config().App.BodyLimit * 1024 * 1024,
+		// the first valid ip of the header, rate limits are per ip without a token
+		ProxyHeader:        config.Config().App.ProxyHeader,
+		EnableIPValidation: true,
 	})
 }
 
@@ -39,7 +44,7 @@ func fxRegister(lifeCycle fx.Lifecycle, module *AppModule) {
 
 func SetupModule(config config.ConfigService) *AppModule {
 	responseService := NewResponseService(config)
-	return NewModule(NewFiber(responseService), responseService, ProvideValidator())
+	return NewModule(NewFiber(responseService, config), responseService, ProvideValidator())
 }
 
 var FxModule = fx.Module("app", fx.Provide(NewFiber), fx.Provide(NewResponseService), fx.Provide(ProvideValidator), fx.Provide(NewModule), fx.Invoke(fxRegister))
@@ -36,14 +36,14 @@ func (service *responseServiceImpl) generateResponseCode(code int) *string {
 
 func NewResponseService(config config.ConfigService) ResponseService {
 	return &responseServiceImpl{
-		appName: config.Getenv("APP_CODE", "APP"),
+		appName: config.Config().App.Code,
 	}
 }
 
 // impl `ResponseService` start
 
 func (service *responseServiceImpl) Init(config config.ConfigService) {
-	service.appName = config.Getenv("APP_CODE", "APP")
+	service.appName = config.Config().App.Code
 }
 
 func (service *responseServiceImpl) CreateErrorResponse(code int, message string, errors []appmodel.Error) *appmodel.Response {
@@ -0,0 +1,50 @@
+package backupdto
+
+import "time"
+
+const (
+	// 2 added the settings, trusted publishers, token usage & the keys of incremental backups
+	FormatVersion = 2
+	// oldest format that can still be restored, its missing entries are empty
+	MinFormatVersion = 1
+
+	ManifestPath          = "manifest.json"
+	UsersPath             = "data/users.jsonl"
+	PubTokensPath         = "data/pub_tokens.jsonl"
+	PubTokenUsagesPath    = "data/pub_token_usages.jsonl"
+	SettingsPath          = "data/settings.jsonl"
+	SettingHistoriesPath  = "data/setting_histories.jsonl"
+	TrustedPublishersPath = "data/trusted_publishers.jsonl"
+	PubPackagesPath       = "data/pub_packages.jsonl"
+	PubVersionsPath       = "data/pub_versions.jsonl"
+	// primary keys of every row of a table at export time, in incremental backups only,
+	// restoring deletes the rows that aren't listed so purges & resets are carried over
+	KeysDirectory     = "keys/"
+	ArchivesDirectory = "archives/"
+)
+
+type ManifestDTO struct {
+	FormatVersion int        `json:"format_version"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Since         *time.Time `json:"since,omitempty"`
+}
+
+func (manifest *ManifestDTO) IsIncremental() bool {
+	return manifest.Since != nil
+}
+
+type BackupResultDTO struct {
+	Manifest          ManifestDTO `json:"manifest"`
+	Users             int         `json:"users"`
+	PubTokens         int         `json:"pub_tokens"`
+	PubTokenUsages    int         `json:"pub_token_usages"`
+	Settings          int         `json:"settings"`
+	SettingHistories  int         `json:"setting_histories"`
+	TrustedPublishers int         `json:"trusted_publishers"`
+	PubPackages       int         `json:"pub_packages"`
+	PubVersions       int         `json:"pub_versions"`
+	Archives          int         `json:"archives"`
+	MissingArchives   []string    `json:"missing_archives"`
+	// rows deleted by restoring an incremental backup, hard deleted since the previous one
+	Deleted int `json:"deleted"`
+}
@@ -0,0 +1,69 @@
+package backupdto
+
+import (
+	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/setting/settingmodel"
+	"private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
+	"private-pub-repo/modules/user/usermodel"
+
+	"github.com/google/uuid"
+)
+
+// UserRecord keeps the password hash, which is hidden from the model json
+type UserRecord struct {
+	usermodel.UserModel
+	Password *string `json:"password"`
+}
+
+func NewUserRecord(model *usermodel.UserModel) *UserRecord {
+	return &UserRecord{UserModel: *model, Password: model.Password}
+}
+
+func (record *UserRecord) ToModel() *usermodel.UserModel {
+	model := record.UserModel
+	model.Password = record.Password
+	return &model
+}
+
+// PubTokenRecord keeps the token hash, which is hidden from the model json
+type PubTokenRecord struct {
+	pubtokenmodel.PubTokenModel
+	TokenHash *string `json:"token_hash"`
+}
+
+func NewPubTokenRecord(model *pubtokenmodel.PubTokenModel) *PubTokenRecord {
+	return &PubTokenRecord{PubTokenModel: *model, TokenHash: model.TokenHash}
+}
+
+func (record *PubTokenRecord) ToModel() *pubtokenmodel.PubTokenModel {
+	model := record.PubTokenModel
+	model.TokenHash = record.TokenHash
+	return &model
+}
+
+// PubTokenUsageRecord keeps the token id, which is hidden from the model json
+type PubTokenUsageRecord struct {
+	pubtokenmodel.PubTokenUsageModel
+	TokenID uuid.UUID `json:"token_id"`
+}
+
+func NewPubTokenUsageRecord(model *pubtokenmodel.PubTokenUsageModel) *PubTokenUsageRecord {
+	return &PubTokenUsageRecord{PubTokenUsageModel: *model, TokenID: model.TokenID}
+}
+
+func (record *PubTokenUsageRecord) ToModel() *pubtokenmodel.PubTokenUsageModel {
+	model := record.PubTokenUsageModel
+	model.TokenID = record.TokenID
+	return &model
+}
+
+type SettingRecord = settingmodel.SettingModel
+
+type SettingHistoryRecord = settingmodel.SettingHistoryModel
+
+type TrustedPublisherRecord = trustedpublishermodel.TrustedPublisherModel
+
+type PubPackageRecord = pubmodel.PubPackageModel
+
+type PubVersionRecord = pubmodel.PubVersionModel
@@ -0,0 +1,42 @@
+package backup
+
+import (
+	"private-pub-repo/base"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/storage"
+
+	"go.uber.org/fx"
+)
+
+type BackupModule struct {
+	Service BackupService
+	db      db.DbService
+}
+
+func NewModule(service BackupService, db db.DbService) *BackupModule {
+	return &BackupModule{Service: service, db: db}
+}
+
+func fxRegister(lifeCycle fx.Lifecycle, module *BackupModule) {
+	base.FxRegister(module, lifeCycle)
+}
+
+func SetupModule(db *db.DbModule, monitor *monitor.MonitorModule, storage *storage.StorageModule) *BackupModule {
+	return NewModule(NewBackupService(monitor.Service, storage), db)
+}
+
+var FxModule = fx.Module("Backup", fx.Provide(NewBackupService), fx.Provide(NewModule), fx.Invoke(fxRegister))
+
+// implements `BaseModule` of `base/module.go` start
+
+func (module *BackupModule) OnStart() error {
+	module.Service.Init(module.db)
+	return nil
+}
+
+func (module *BackupModule) OnStop() error {
+	return nil
+}
+
+// implements `BaseModule` of `base/module.go` end
@@ -0,0 +1,641 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"private-pub-repo/modules/backup/backupdto"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/setting/settingmodel"
+	"private-pub-repo/modules/storage"
+	"private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
+	"private-pub-repo/modules/user/usermodel"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	archivePathFormat = "pub/packages/%s/versions/%s.tar.gz"
+	archivePrefix     = "pub/packages/"
+
+	exportBatchSize  = 500
+	restoreBatchSize = 100
+)
+
+// keyedTable = table whose rows can be hard deleted (purge, setting reset, user / token / trusted publisher deletion), the incremental backups
+// list its keys. in deletion order, the rows referencing others first
+type keyedTable struct {
+	name    string
+	model   interface{}
+	columns []string
+}
+
+var keyedTables = []keyedTable{
+	{name: "pub_token_usages", model: &pubtokenmodel.PubTokenUsageModel{}, columns: []string{"token_id", "day", "operation", "ip"}},
+	{name: "pub_tokens", model: &pubtokenmodel.PubTokenModel{}, columns: []string{"id"}},
+	{name: "trusted_publishers", model: &trustedpublishermodel.TrustedPublisherModel{}, columns: []string{"id"}},
+	{name: "settings", model: &settingmodel.SettingModel{}, columns: []string{"key"}},
+	{name: "pub_versions", model: &pubmodel.PubVersionModel{}, columns: []string{"package_name", "version"}},
+	{name: "pub_packages", model: &pubmodel.PubPackageModel{}, columns: []string{"name"}},
+	{name: "users", model: &usermodel.UserModel{}, columns: []string{"id"}},
+}
+
+// updated or soft deleted rows, of the tables made of `base.BaseModel`
+var changedColumns = []string{"updated_at", "deleted_at"}
+
+type BackupService interface {
+	Init(db db.DbService)
+	Export(context context.Context, writer io.Writer, since *time.Time) (*backupdto.BackupResultDTO, error)
+	Restore(context context.Context, reader io.Reader) (*backupdto.BackupResultDTO, error)
+}
+
+type backupServiceImpl struct {
+	monitorService monitor.MonitorService
+	storage        storage.StorageService
+	db             *gorm.DB
+}
+
+func NewBackupService(monitorService monitor.MonitorService, storage storage.StorageService) BackupService {
+	return &backupServiceImpl{
+		monitorService: monitorService,
+		storage:        storage,
+	}
+}
+
+// exportRows pages through the query, since `pub_versions` has no primary key for `FindInBatches`
+func exportRows[T any](query *gorm.DB, order string, encode func(row *T) error) (int, error) {
+	count := 0
+	for offset := 0; ; offset += exportBatchSize {
+		rows := []T{}
+		if err := query.Session(&gorm.Session{}).Order(order).Limit(exportBatchSize).Offset(offset).Find(&rows).Error; err != nil {
+			return count, err
+		}
+
+		for i := range rows {
+			if err := encode(&rows[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(rows) < exportBatchSize {
+			return count, nil
+		}
+	}
+}
+
+// writeTarEntry buffers the entry in a temporary file, since tar headers need the size upfront
+func writeTarEntry(tarWriter *tar.Writer, name string, modTime time.Time, write func(writer io.Writer) error) error {
+	buffer, err := os.CreateTemp("", "pubserver-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(buffer.Name())
+	defer buffer.Close()
+
+	bufferedWriter := bufio.NewWriter(buffer)
+	if err = write(bufferedWriter); err != nil {
+		return err
+	}
+	if err = bufferedWriter.Flush(); err != nil {
+		return err
+	}
+
+	size, err := buffer.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = buffer.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tarWriter, buffer)
+	return err
+}
+
+// restoreRows decodes json lines and inserts them in batches
+func restoreRows[T any](reader io.Reader, insert func(rows []T) error) (int, error) {
+	decoder := json.NewDecoder(reader)
+	count := 0
+	rows := make([]T, 0, restoreBatchSize)
+
+	for {
+		var row T
+		err := decoder.Decode(&row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		rows = append(rows, row)
+		if len(rows) == restoreBatchSize {
+			if err := insert(rows); err != nil {
+				return count, err
+			}
+			count += len(rows)
+			rows = rows[:0]
+		}
+	}
+
+	if len(rows) > 0 {
+		if err := insert(rows); err != nil {
+			return count, err
+		}
+		count += len(rows)
+	}
+
+	return count, nil
+}
+
+// incremental backups also include rows soft deleted since then, so deletions are carried over.
+// columns are the times a row changes at
+func (service *backupServiceImpl) changedSince(query *gorm.DB, since *time.Time, columns ...string) *gorm.DB {
+	query = query.Unscoped()
+	if since == nil {
+		return query
+	}
+
+	conditions := make([]string, len(columns))
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = query.Statement.Quote(column) + " >= ?"
+		values[i] = since
+	}
+	return query.Where(strings.Join(conditions, " OR "), values...)
+}
+
+// tableKeys = primary keys of every row of table, soft deleted ones included
+func (service *backupServiceImpl) tableKeys(db *gorm.DB, table keyedTable) ([][]string, error) {
+	columns := make([]string, len(table.columns))
+	for i, column := range table.columns {
+		columns[i] = db.Statement.Quote(column)
+	}
+
+	rows, err := db.Unscoped().Model(table.model).Select(strings.Join(columns, ", ")).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := [][]string{}
+	for rows.Next() {
+		key := make([]string, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range key {
+			pointers[i] = &key[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// deleteMissing hard deletes the rows of table whose key isn't listed by reader, they were deleted since the
+// previous backup. returns the deleted keys
+func (service *backupServiceImpl) deleteMissing(tx *gorm.DB, table keyedTable, reader io.Reader) ([][]string, error) {
+	listed := map[string]bool{}
+	decoder := json.NewDecoder(reader)
+	for {
+		var key []string
+		err := decoder.Decode(&key)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != len(table.columns) {
+			return nil, fmt.Errorf("key of %s has %d columns instead of %d", table.name, len(key), len(table.columns))
+		}
+		listed[strings.Join(key, "\x00")] = true
+	}
+
+	keys, err := service.tableKeys(tx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := [][]string{}
+	for _, key := range keys {
+		if listed[strings.Join(key, "\x00")] {
+			continue
+		}
+
+		conditions := map[string]interface{}{}
+		for i, column := range table.columns {
+			conditions[column] = key[i]
+		}
+		if err := tx.Unscoped().Where(conditions).Delete(table.model).Error; err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, key)
+	}
+
+	return deleted, nil
+}
+
+func (service *backupServiceImpl) insertQuery(tx *gorm.DB, incremental bool, conflictColumns ...string) *gorm.DB {
+	tx = tx.Omit(clause.Associations)
+	if !incremental {
+		return tx
+	}
+
+	columns := make([]clause.Column, len(conflictColumns))
+	for i, name := range conflictColumns {
+		columns[i] = clause.Column{Name: name}
+	}
+	return tx.Clauses(clause.OnConflict{Columns: columns, UpdateAll: true})
+}
+
+func (service *backupServiceImpl) ensureEmpty(tx *gorm.DB) error {
+	for _, model := range []interface{}{&usermodel.UserModel{}, &pubtokenmodel.PubTokenModel{}, &trustedpublishermodel.TrustedPublisherModel{}, &pubmodel.PubPackageModel{}, &pubmodel.PubVersionModel{}} {
+		var count int64
+		if err := tx.Unscoped().Model(model).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("full backup can only be restored into an empty deployment, make sure no migration seeder or upload has been run")
+		}
+	}
+	return nil
+}
+
+// restoreEntry restores the tar entry name, read from reader
+func (service *backupServiceImpl) restoreEntry(context context.Context, tx *gorm.DB, name string, reader io.Reader, result *backupdto.BackupResultDTO, deletedArchives *[]string) (err error) {
+	incremental := result.Manifest.IsIncremental()
+
+	switch {
+	case name == backupdto.UsersPath:
+		result.Users, err = restoreRows(reader, func(rows []backupdto.UserRecord) error {
+			models := make([]*usermodel.UserModel, len(rows))
+			for i := range rows {
+				models[i] = rows[i].ToModel()
+			}
+			return service.insertQuery(tx, incremental, "id").Create(models).Error
+		})
+	case name == backupdto.PubTokensPath:
+		result.PubTokens, err = restoreRows(reader, func(rows []backupdto.PubTokenRecord) error {
+			models := make([]*pubtokenmodel.PubTokenModel, len(rows))
+			for i := range rows {
+				models[i] = rows[i].ToModel()
+			}
+			return service.insertQuery(tx, incremental, "id").Create(models).Error
+		})
+	case name == backupdto.PubTokenUsagesPath:
+		result.PubTokenUsages, err = restoreRows(reader, func(rows []backupdto.PubTokenUsageRecord) error {
+			models := make([]*pubtokenmodel.PubTokenUsageModel, len(rows))
+			for i := range rows {
+				models[i] = rows[i].ToModel()
+			}
+			return service.insertQuery(tx, incremental, "token_id", "day", "operation", "ip").Create(models).Error
+		})
+	case name == backupdto.SettingsPath:
+		result.Settings, err = restoreRows(reader, func(rows []backupdto.SettingRecord) error {
+			return service.insertQuery(tx, incremental, "key").Create(rows).Error
+		})
+	case name == backupdto.SettingHistoriesPath:
+		result.SettingHistories, err = restoreRows(reader, func(rows []backupdto.SettingHistoryRecord) error {
+			return service.insertQuery(tx, incremental, "id").Create(rows).Error
+		})
+	case name == backupdto.TrustedPublishersPath:
+		result.TrustedPublishers, err = restoreRows(reader, func(rows []backupdto.TrustedPublisherRecord) error {
+			return service.insertQuery(tx, incremental, "id").Create(rows).Error
+		})
+	case name == backupdto.PubPackagesPath:
+		result.PubPackages, err = restoreRows(reader, func(rows []backupdto.PubPackageRecord) error {
+			return service.insertQuery(tx, incremental, "name").Create(rows).Error
+		})
+	case name == backupdto.PubVersionsPath:
+		result.PubVersions, err = restoreRows(reader, func(rows []backupdto.PubVersionRecord) error {
+			return service.insertQuery(tx, incremental, "package_name", "version").Create(rows).Error
+		})
+	case strings.HasPrefix(name, backupdto.KeysDirectory):
+		tableName := strings.TrimSuffix(strings.TrimPrefix(name, backupdto.KeysDirectory), ".jsonl")
+		index := slices.IndexFunc(keyedTables, func(table keyedTable) bool { return table.name == tableName })
+		if !incremental || index < 0 {
+			return fmt.Errorf("unknown backup entry %s", name)
+		}
+
+		deleted, err := service.deleteMissing(tx, keyedTables[index], reader)
+		if err != nil {
+			return err
+		}
+		result.Deleted += len(deleted)
+
+		if tableName == "pub_versions" {
+			for _, key := range deleted {
+				*deletedArchives = append(*deletedArchives, fmt.Sprintf(archivePathFormat, key[0], key[1]))
+			}
+		}
+	case strings.HasPrefix(name, backupdto.ArchivesDirectory):
+		key := strings.TrimPrefix(name, backupdto.ArchivesDirectory)
+		if !strings.HasPrefix(key, archivePrefix) || strings.Contains(key, "..") {
+			return fmt.Errorf("invalid archive entry %s", name)
+		}
+		if err = service.storage.Upload(context, key, reader); err == nil {
+			result.Archives++
+		}
+	default:
+		return fmt.Errorf("unknown backup entry %s", name)
+	}
+
+	return
+}
+
+// impl `BackupService` start
+
+func (service *backupServiceImpl) Init(db db.DbService) {
+	service.db = db.Default()
+}
+
+func (service *backupServiceImpl) Export(context context.Context, writer io.Writer, since *time.Time) (*backupdto.BackupResultDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "BackupService.Export", map[string]interface{}{
+		"incremental": since != nil,
+	})
+	defer span.End()
+
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	result := backupdto.BackupResultDTO{
+		Manifest: backupdto.ManifestDTO{
+			FormatVersion: backupdto.FormatVersion,
+			CreatedAt:     time.Now(),
+			Since:         since,
+		},
+		MissingArchives: []string{},
+	}
+	modTime := result.Manifest.CreatedAt
+	db := service.db.WithContext(spanContext)
+
+	err := writeTarEntry(tarWriter, backupdto.ManifestPath, modTime, func(writer io.Writer) error {
+		return json.NewEncoder(writer).Encode(result.Manifest)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeTarEntry(tarWriter, backupdto.UsersPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.Users, err = exportRows(service.changedSince(db.Model(&usermodel.UserModel{}), since, changedColumns...), "created_at, id", func(row *usermodel.UserModel) error {
+			return encoder.Encode(backupdto.NewUserRecord(row))
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeTarEntry(tarWriter, backupdto.PubTokensPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.PubTokens, err = exportRows(service.changedSince(db.Model(&pubtokenmodel.PubTokenModel{}), since, changedColumns...), "created_at, id", func(row *pubtokenmodel.PubTokenModel) error {
+			return encoder.Encode(backupdto.NewPubTokenRecord(row))
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeTarEntry(tarWriter, backupdto.PubTokenUsagesPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.PubTokenUsages, err = exportRows(service.changedSince(db.Model(&pubtokenmodel.PubTokenUsageModel{}), since, "last_used_at"), "day, token_id, operation, ip", func(row *pubtokenmodel.PubTokenUsageModel) error {
+			return encoder.Encode(backupdto.NewPubTokenUsageRecord(row))
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeTarEntry(tarWriter, backupdto.SettingsPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.Settings, err = exportRows(service.changedSince(db.Model(&settingmodel.SettingModel{}), since, "updated_at"), db.Statement.Quote("key"), func(row *backupdto.SettingRecord) error {
+			return encoder.Encode(row)
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeTarEntry(tarWriter, backupdto.SettingHistoriesPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.SettingHistories, err = exportRows(service.changedSince(db.Model(&settingmodel.SettingHistoryModel{}), since, changedColumns...), "created_at, id", func(row *backupdto.SettingHistoryRecord) error {
+			return encoder.Encode(row)
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeTarEntry(tarWriter, backupdto.TrustedPublishersPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.TrustedPublishers, err = exportRows(service.changedSince(db.Model(&trustedpublishermodel.TrustedPublisherModel{}), since, changedColumns...), "created_at, id", func(row *backupdto.TrustedPublisherRecord) error {
+			return encoder.Encode(row)
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeTarEntry(tarWriter, backupdto.PubPackagesPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.PubPackages, err = exportRows(service.changedSince(db.Model(&pubmodel.PubPackageModel{}), since, changedColumns...), "name", func(row *backupdto.PubPackageRecord) error {
+			return encoder.Encode(row)
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// archives never change once published, so only the versions changed since then need their archive exported.
+	// by update time, the imported versions keep the creation time of the repository they come from
+	archiveKeys := []string{}
+	err = writeTarEntry(tarWriter, backupdto.PubVersionsPath, modTime, func(writer io.Writer) (err error) {
+		encoder := json.NewEncoder(writer)
+		result.PubVersions, err = exportRows(service.changedSince(db.Model(&pubmodel.PubVersionModel{}), since, changedColumns...), "package_name, version", func(row *backupdto.PubVersionRecord) error {
+			if since == nil || (row.UpdatedAt != nil && !row.UpdatedAt.Before(*since)) {
+				archiveKeys = append(archiveKeys, fmt.Sprintf(archivePathFormat, row.PackageName, row.Version))
+			}
+			return encoder.Encode(row)
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if since != nil {
+		for _, table := range keyedTables {
+			err = writeTarEntry(tarWriter, backupdto.KeysDirectory+table.name+".jsonl", modTime, func(writer io.Writer) error {
+				keys, err := service.tableKeys(db, table)
+				if err != nil {
+					return err
+				}
+
+				encoder := json.NewEncoder(writer)
+				for _, key := range keys {
+					if err := encoder.Encode(key); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, key := range archiveKeys {
+		object, err := service.storage.Stat(spanContext, key)
+		if err == storage.ErrNotFound {
+			result.MissingArchives = append(result.MissingArchives, key)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := service.storage.Download(spanContext, key)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name:    backupdto.ArchivesDirectory + key,
+			Mode:    0644,
+			Size:    object.Size,
+			ModTime: modTime,
+		})
+		if err == nil {
+			_, err = io.Copy(tarWriter, reader)
+		}
+		reader.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to export archive %s: %w", key, err)
+		}
+		result.Archives++
+	}
+
+	if err = tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (service *backupServiceImpl) Restore(context context.Context, reader io.Reader) (*backupdto.BackupResultDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "BackupService.Restore", map[string]interface{}{})
+	defer span.End()
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	result := backupdto.BackupResultDTO{MissingArchives: []string{}}
+	// archives of the versions deleted by an incremental backup, removed once the rows are
+	deletedArchives := []string{}
+
+	// all the rows or none, a failed restore can be retried as is. archives can't be rolled back,
+	// the ones already uploaded are overwritten by the retry
+	err = service.db.WithContext(spanContext).Transaction(func(tx *gorm.DB) error {
+		hasManifest := false
+
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+
+			if !hasManifest {
+				if header.Name != backupdto.ManifestPath {
+					return fmt.Errorf("invalid backup, %s must be the first entry", backupdto.ManifestPath)
+				}
+				if err := json.NewDecoder(tarReader).Decode(&result.Manifest); err != nil {
+					return err
+				}
+				if result.Manifest.FormatVersion < backupdto.MinFormatVersion || result.Manifest.FormatVersion > backupdto.FormatVersion {
+					return fmt.Errorf("unsupported backup format version %d", result.Manifest.FormatVersion)
+				}
+				if !result.Manifest.IsIncremental() {
+					if err := service.ensureEmpty(tx); err != nil {
+						return err
+					}
+				}
+				hasManifest = true
+				continue
+			}
+
+			if err := service.restoreEntry(spanContext, tx, header.Name, tarReader, &result, &deletedArchives); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", header.Name, err)
+			}
+		}
+
+		if !hasManifest {
+			return fmt.Errorf("invalid backup, %s not found", backupdto.ManifestPath)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the rows are committed already, a leftover archive is only unused space
+	for _, key := range deletedArchives {
+		if err := service.storage.Delete(spanContext, key); err != nil && err != storage.ErrNotFound {
+			service.monitorService.Logger().WarnContext(spanContext, "failed to delete archive of a deleted version", "key", key, "error", err)
+		}
+	}
+
+	return &result, nil
+}
+
+// impl `BackupService` end
@@ -0,0 +1,200 @@
+package config
+
+// Config = typed configuration, loaded from the config file then overridden by the envs of the `env` tags.
+// `default` is used when neither sets a value, `secret` values are redacted by `config:check`
+type Config struct {
+	App       AppConfig       `yaml:"app" toml:"app"`
+	Db        DbConfig        `yaml:"db" toml:"db"`
+	Jwt       JwtConfig       `yaml:"jwt" toml:"jwt"`
+	Storage   StorageConfig   `yaml:"storage" toml:"storage"`
+	Smtp      SmtpConfig      `yaml:"smtp" toml:"smtp"`
+	User      UserConfig      `yaml:"user" toml:"user"`
+	Monitor   MonitorConfig   `yaml:"monitor" toml:"monitor"`
+	Pub       PubConfig       `yaml:"pub" toml:"pub"`
+	Setting   SettingConfig   `yaml:"setting" toml:"setting"`
+	Health    HealthConfig    `yaml:"health" toml:"health"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	PubToken  PubTokenConfig  `yaml:"pub_token" toml:"pub_token"`
+	// TrustedPublishing = publishing from ci with the oidc tokens of its provider
+	TrustedPublishing TrustedPublishingConfig `yaml:"trusted_publishing" toml:"trusted_publishing"`
+	// Sso = login of the users with an openid connect provider
+	Sso SsoConfig `yaml:"sso" toml:"sso"`
+}
+
+type AppConfig struct {
+	// prefix of the response codes, and service name of the traces
+	Code string `yaml:"code" toml:"code" env:"APP_CODE" default:"APP" validate:"required"`
+	Host string `yaml:"host" toml:"host" env:"APP_HOST"`
+	Port int    `yaml:"port" toml:"port" env:"PORT" default:"3000" validate:"min=1,max=65535"`
+	// megabytes, hard limit of request bodies, the upload size limit setting can't go above it
+	BodyLimit int `yaml:"body_limit" toml:"body_limit" env:"BODY_LIMIT" default:"100" validate:"min=1"`
+	// header holding the client ip behind a reverse proxy, e.g. X-Forwarded-For, only set it behind a proxy
+	ProxyHeader string `yaml:"proxy_header" toml:"proxy_header" env:"PROXY_HEADER"`
+}
+
+type DbConfig struct {
+	Connection string `yaml:"connection" toml:"connection" env:"DB_CONNECTION" validate:"required,oneof=postgres mysql sqlite"`
+	Host       string `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port       string `yaml:"port" toml:"port" env:"DB_PORT" validate:"omitempty,numeric"`
+	Database   string `yaml:"database" toml:"database" env:"DB_DATABASE"`
+	Username   string `yaml:"username" toml:"username" env:"DB_USERNAME"`
+	Password   string `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Locale     string `yaml:"locale" toml:"locale" env:"DB_LOCALE"`
+	// replaces host, port, database, username & password
+	Url     string `yaml:"url" toml:"url" env:"DATABASE_URL" secret:"true"`
+	Logging bool   `yaml:"logging" toml:"logging" env:"DB_LOGGING"`
+	// gorm auto migration, for development only
+	AutoMigration  bool     `yaml:"auto_migration" toml:"auto_migration" env:"DB_AUTOMIGRATION"`
+	MigrateOnStart bool     `yaml:"migrate_on_start" toml:"migrate_on_start" env:"DB_MIGRATE_ON_START"`
+	ReplicaHosts   []string `yaml:"replica_hosts" toml:"replica_hosts" env:"DB_REPLICA_HOSTS"`
+	ReplicaUrls    []string `yaml:"replica_urls" toml:"replica_urls" env:"DATABASE_REPLICA_URLS" secret:"true"`
+}
+
+type JwtConfig struct {
+	// HS256 signs with `secret`, RS256 & EdDSA with the keys of `keys_dir`, that others can verify with the jwks
+	Algorithm string `yaml:"algorithm" toml:"algorithm" env:"JWT_ALGORITHM" default:"HS256" validate:"oneof=HS256 RS256 EdDSA"`
+	// anyone knowing it can sign tokens of any user. with RS256 / EdDSA, only kept to accept the tokens it signed
+	Secret string `yaml:"secret" toml:"secret" env:"JWT_SECRET" secret:"true" validate:"omitempty,min=32"`
+	// directory of the `<kid>.pem` private keys, made by `jwt:keygen`
+	KeysDir string `yaml:"keys_dir" toml:"keys_dir" env:"JWT_KEYS_DIR"`
+	// seconds, how often the keys directory is read again, for the added & removed keys
+	KeysRefresh int `yaml:"keys_refresh" toml:"keys_refresh" env:"JWT_KEYS_REFRESH" default:"60" validate:"min=1"`
+	// minutes
+	TokenLifetime   int `yaml:"token_lifetime" toml:"token_lifetime" env:"JWT_TOKEN_LIFETIME" default:"1" validate:"min=1"`
+	RefreshLifetime int `yaml:"refresh_lifetime" toml:"refresh_lifetime" env:"JWT_REFRESH_LIFETIME" default:"1" validate:"min=1"`
+}
+
+type StorageConfig struct {
+	Endpoint string `yaml:"endpoint" toml:"endpoint" env:"S3_ENDPOINT" validate:"omitempty,url"`
+	// endpoint of presigned urls, `endpoint` when empty
+	PublicEndpoint string `yaml:"public_endpoint" toml:"public_endpoint" env:"S3_PUBLIC_ENDPOINT" validate:"omitempty,url"`
+	Region         string `yaml:"region" toml:"region" env:"S3_REGION"`
+	KeyId          string `yaml:"key_id" toml:"key_id" env:"S3_KEY_ID"`
+	AccessKey      string `yaml:"access_key" toml:"access_key" env:"S3_ACCESS_KEY" secret:"true"`
+	Bucket         string `yaml:"bucket" toml:"bucket" env:"S3_BUCKET"`
+	UsePathStyle   bool   `yaml:"use_path_style" toml:"use_path_style" env:"S3_USE_PATH_STYLE"`
+	EnablePresign  bool   `yaml:"enable_presign" toml:"enable_presign" env:"S3_ENABLE_PRESIGN"`
+	// minutes
+	PresignTime int `yaml:"presign_time" toml:"presign_time" env:"S3_PRESIGN_TIME" default:"15" validate:"min=1"`
+}
+
+type SmtpConfig struct {
+	Host      string `yaml:"host" toml:"host" env:"SMTP_HOST"`
+	Port      int    `yaml:"port" toml:"port" env:"SMTP_PORT" default:"587" validate:"min=1,max=65535"`
+	Username  string `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password  string `yaml:"password" toml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	FromEmail string `yaml:"from_email" toml:"from_email" env:"SMTP_FROM_EMAIL" validate:"omitempty,email"`
+	FromName  string `yaml:"from_name" toml:"from_name" env:"SMTP_FROM_NAME"`
+}
+
+type UserConfig struct {
+	// minutes
+	OtpExpiredTime int `yaml:"otp_expired_time" toml:"otp_expired_time" env:"OTP_EXPIRED_TIME" default:"5" validate:"min=1"`
+}
+
+type MonitorConfig struct {
+	// open telemetry collector, disabled when empty
+	OtlpUrl string `yaml:"otlp_url" toml:"otlp_url" env:"OTLP_URL" validate:"omitempty,url"`
+	// metrics of the collector, `otlp_url` with /v1/traces replaced by /v1/metrics when empty
+	OtlpMetricsUrl string `yaml:"otlp_metrics_url" toml:"otlp_metrics_url" env:"OTLP_METRICS_URL" validate:"omitempty,url"`
+	// seconds, how often metrics are pushed to the collector
+	OtlpMetricsInterval int `yaml:"otlp_metrics_interval" toml:"otlp_metrics_interval" env:"OTLP_METRICS_INTERVAL" default:"60" validate:"min=1"`
+	// prometheus endpoint `/metrics`, on its own listener
+	MetricsEnabled bool `yaml:"metrics_enabled" toml:"metrics_enabled" env:"METRICS_ENABLED"`
+	// address of the metrics listener, apart from the api so it stays out of public reach, e.g. 0.0.0.0:9090 for a
+	// scraper of another host
+	MetricsListen string `yaml:"metrics_listen" toml:"metrics_listen" env:"METRICS_LISTEN" default:"127.0.0.1:9090" validate:"hostname_port"`
+	// label the download redirects by package, one series per package
+	MetricsPackageLabel bool `yaml:"metrics_package_label" toml:"metrics_package_label" env:"METRICS_PACKAGE_LABEL"`
+	// text (key=value) or json, one line per entry
+	LogFormat string `yaml:"log_format" toml:"log_format" env:"LOG_FORMAT" default:"text" validate:"oneof=text json"`
+	LogLevel  string `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" default:"info" validate:"oneof=debug info warn error"`
+}
+
+type PubConfig struct {
+	// pub repository that serves the packages this one doesn't have, e.g. https://pub.dev
+	UpstreamUrl string `yaml:"upstream_url" toml:"upstream_url" env:"UPSTREAM_URL" validate:"omitempty,url"`
+	// hosted repositories the admin import endpoint can import from, e.g. https://pub.dev. the endpoint is disabled
+	// when empty, `pub:import` isn't restricted
+	ImportUrls       []string               `yaml:"import_urls" toml:"import_urls" env:"IMPORT_URLS" validate:"dive,url"`
+	ConsistencyCheck ConsistencyCheckConfig `yaml:"consistency_check" toml:"consistency_check"`
+}
+
+type ConsistencyCheckConfig struct {
+	// minutes, disabled when 0
+	Interval int `yaml:"interval" toml:"interval" env:"CONSISTENCY_CHECK_INTERVAL" validate:"min=0"`
+	// minutes
+	OrphanMinAge  int  `yaml:"orphan_min_age" toml:"orphan_min_age" env:"CONSISTENCY_CHECK_ORPHAN_MIN_AGE" default:"60" validate:"min=0"`
+	DeleteOrphans bool `yaml:"delete_orphans" toml:"delete_orphans" env:"CONSISTENCY_CHECK_DELETE_ORPHANS"`
+	MarkBroken    bool `yaml:"mark_broken" toml:"mark_broken" env:"CONSISTENCY_CHECK_MARK_BROKEN"`
+}
+
+type SettingConfig struct {
+	// seconds, how often the runtime settings are reloaded from the database, for changes made on other instances
+	RefreshInterval int `yaml:"refresh_interval" toml:"refresh_interval" env:"SETTING_REFRESH_INTERVAL" default:"30" validate:"min=1"`
+}
+
+type HealthConfig struct {
+	// seconds, per component check of the readiness
+	Timeout int `yaml:"timeout" toml:"timeout" env:"HEALTH_CHECK_TIMEOUT" default:"3" validate:"min=1"`
+	// report the smtp server, it never makes the server unready
+	CheckSmtp bool `yaml:"check_smtp" toml:"check_smtp" env:"HEALTH_CHECK_SMTP"`
+}
+
+type RateLimitConfig struct {
+	// memory (per instance) or database (shared by the instances)
+	Store string `yaml:"store" toml:"store" env:"RATE_LIMIT_STORE" default:"database" validate:"oneof=memory database"`
+	// seconds
+	Window int `yaml:"window" toml:"window" env:"RATE_LIMIT_WINDOW" default:"60" validate:"min=1"`
+	// requests per window on the pub api, per pub token (per ip without a valid one), 0 disables the limit
+	Pub int `yaml:"pub" toml:"pub" env:"RATE_LIMIT_PUB" default:"600" validate:"min=0"`
+	// requests per window on the query api, per user (per ip without a valid token), 0 disables the limit
+	Query int `yaml:"query" toml:"query" env:"RATE_LIMIT_QUERY" default:"300" validate:"min=0"`
+	// requests per window on login & forgot password, per ip, 0 disables the limit
+	Public int `yaml:"public" toml:"public" env:"RATE_LIMIT_PUBLIC" default:"5" validate:"min=0"`
+}
+
+type PubTokenConfig struct {
+	// seconds, how often the token usage counted in memory is written to the database
+	UsageFlush int `yaml:"usage_flush" toml:"usage_flush" env:"PUB_TOKEN_USAGE_FLUSH" default:"60" validate:"min=1"`
+	// days the usage history is kept
+	UsageRetention int `yaml:"usage_retention" toml:"usage_retention" env:"PUB_TOKEN_USAGE_RETENTION" default:"90" validate:"min=1"`
+}
+
+type TrustedPublishingConfig struct {
+	// seconds, lifetime of the pub tokens exchanged for oidc tokens
+	TokenLifetime int `yaml:"token_lifetime" toml:"token_lifetime" env:"TRUSTED_PUBLISHING_TOKEN_LIFETIME" default:"900" validate:"min=60,max=86400"`
+	// seconds, how long the keys of an issuer are cached, unknown key ids refetch them sooner
+	JwksRefresh int `yaml:"jwks_refresh" toml:"jwks_refresh" env:"TRUSTED_PUBLISHING_JWKS_REFRESH" default:"3600" validate:"min=60"`
+	// accept http issuers, for local test issuers only
+	AllowHttp bool `yaml:"allow_http" toml:"allow_http" env:"TRUSTED_PUBLISHING_ALLOW_HTTP"`
+}
+
+type SsoConfig struct {
+	// openid connect issuer, e.g. https://accounts.google.com, single sign-on is disabled when empty
+	Issuer   string `yaml:"issuer" toml:"issuer" env:"SSO_ISSUER" validate:"omitempty,url"`
+	ClientId string `yaml:"client_id" toml:"client_id" env:"SSO_CLIENT_ID"`
+	// empty for public clients, the code is still protected by pkce
+	ClientSecret string `yaml:"client_secret" toml:"client_secret" env:"SSO_CLIENT_SECRET" secret:"true"`
+	// the callback as registered at the provider, e.g. https://pub.example.com/v1/users/sso/callback
+	RedirectUrl string `yaml:"redirect_url" toml:"redirect_url" env:"SSO_REDIRECT_URL" validate:"omitempty,url"`
+	// requested besides `openid`, the email claim is required
+	Scopes []string `yaml:"scopes" toml:"scopes" env:"SSO_SCOPES" default:"email,profile"`
+	// claim of the id token listing the groups of the user
+	GroupsClaim string `yaml:"groups_claim" toml:"groups_claim" env:"SSO_GROUPS_CLAIM" default:"groups"`
+	// members of any of them are admins, the others aren't. the admin flag is left as is when empty
+	AdminGroups []string `yaml:"admin_groups" toml:"admin_groups" env:"SSO_ADMIN_GROUPS"`
+	// members of any of them can publish, the others can't. the write flag is left as is when empty
+	WriteGroups []string `yaml:"write_groups" toml:"write_groups" env:"SSO_WRITE_GROUPS"`
+	// create the users signing in for the first time, otherwise only the existing users can, matched by email
+	JitProvisioning bool `yaml:"jit_provisioning" toml:"jit_provisioning" env:"SSO_JIT_PROVISIONING"`
+	// refuse the email & password login and the forgot password, the users sign in with the provider only
+	DisablePasswordLogin bool `yaml:"disable_password_login" toml:"disable_password_login" env:"SSO_DISABLE_PASSWORD_LOGIN"`
+	// accept id tokens without the email_verified claim, for providers that never send it. an email_verified false is
+	// still refused
+	AllowUnverifiedEmail bool `yaml:"allow_unverified_email" toml:"allow_unverified_email" env:"SSO_ALLOW_UNVERIFIED_EMAIL"`
+	// seconds, to complete the login at the provider
+	LoginTimeout int `yaml:"login_timeout" toml:"login_timeout" env:"SSO_LOGIN_TIMEOUT" default:"600" validate:"min=60"`
+	// accept an http issuer, for local test issuers only
+	AllowHttp bool `yaml:"allow_http" toml:"allow_http" env:"SSO_ALLOW_HTTP"`
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	redacted = "******"
+	// env of the config file path, also set by the `--config` flag
+	fileEnv = "CONFIG_FILE"
+)
+
+// looked up in the working directory when CONFIG_FILE is not set
+var defaultFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// secrets of .env.example & docker-compose.example.yml, copied as is more often than one would hope
+var sampleSecrets = []string{
+	"aaskdlfjkdasljflkdasflkasdflncxzkvnksljionlaksjflkadsfjkladsfqwe",
+	"thisisveryrandomsecretstringthatyoushouldnottypemanuallylikethis",
+}
+
+// configField = leaf of `Config`, path is made of the yaml keys
+type configField struct {
+	path  string
+	field reflect.StructField
+	value reflect.Value
+}
+
+// ValidationError = every invalid value, so they can be fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(err.Problems, "\n  ")
+}
+
+// FilePath = config file to load, empty when there is none
+func FilePath() string {
+	if path := os.Getenv(fileEnv); path != "" {
+		return path
+	}
+
+	for _, path := range defaultFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// Load = defaults, overridden by the file at path (yaml or toml, skipped when empty), overridden by the envs
+func Load(path string) (*Config, error) {
+	config := &Config{}
+
+	for _, field := range config.fields() {
+		if value, ok := field.field.Tag.Lookup("default"); ok {
+			if err := setField(field.value, value); err != nil {
+				return nil, fmt.Errorf("%s: default: %w", field.path, err)
+			}
+		}
+	}
+
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	problems := []string{}
+	for _, field := range config.fields() {
+		env := field.field.Tag.Get("env")
+		// empty envs are unset, like the `Getenv` fallback
+		if value := os.Getenv(env); env != "" && value != "" {
+			if err := setField(field.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s (%s): %v", field.path, env, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	return config, nil
+}
+
+func (config *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		metadata, err := toml.Decode(string(content), config)
+		if err != nil {
+			return err
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %s", undecoded[0])
+		}
+		return nil
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err := decoder.Decode(config)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown format, use .yaml, .yml or .toml")
+	}
+}
+
+// Validate refuses unsafe or inconsistent values, instead of silently falling back to defaults
+func (config *Config) Validate() error {
+	problems := []string{}
+	envs := map[string]string{}
+	for _, field := range config.fields() {
+		envs[field.path] = field.field.Tag.Get("env")
+	}
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("yaml"), ",")[0]
+	})
+
+	var validationErrors validator.ValidationErrors
+	if err := validate.Struct(config); errors.As(err, &validationErrors) {
+		for _, fieldError := range validationErrors {
+			// namespace is `Config.<yaml path>`
+			path := strings.SplitN(fieldError.Namespace(), ".", 2)[1]
+			problems = append(problems, fmt.Sprintf("%s (%s): %s", path, envs[path], describeValidation(fieldError)))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	for _, secret := range sampleSecrets {
+		if config.Jwt.Secret == secret {
+			problems = append(problems, "jwt.secret (JWT_SECRET): is the sample secret, generate one, e.g. `openssl rand -base64 48`")
+		}
+	}
+
+	if config.Jwt.Algorithm == "HS256" && config.Jwt.Secret == "" {
+		problems = append(problems, "jwt.secret (JWT_SECRET): is required with the HS256 algorithm")
+	}
+	if config.Jwt.Algorithm != "HS256" && config.Jwt.KeysDir == "" {
+		problems = append(problems, "jwt.keys_dir (JWT_KEYS_DIR): is required with the RS256 & EdDSA algorithms, see `jwt:keygen`")
+	}
+
+	switch config.Db.Connection {
+	case "postgres", "mysql":
+		if config.Db.Url == "" && (config.Db.Host == "" || config.Db.Database == "") {
+			problems = append(problems, "db: either url (DATABASE_URL) or host (DB_HOST) and database (DB_DATABASE) are required")
+		}
+	case "sqlite":
+		if config.Db.Url == "" && config.Db.Database == "" {
+			problems = append(problems, "db.database (DB_DATABASE): the database file is required")
+		}
+		if len(config.Db.ReplicaHosts) > 0 {
+			problems = append(problems, "db.replica_hosts (DB_REPLICA_HOSTS): sqlite has no hosts, use replica_urls")
+		}
+	}
+
+	if config.Sso.Issuer != "" {
+		if config.Sso.ClientId == "" {
+			problems = append(problems, "sso.client_id (SSO_CLIENT_ID): is required with an issuer")
+		}
+		if config.Sso.RedirectUrl == "" {
+			problems = append(problems, "sso.redirect_url (SSO_REDIRECT_URL): is required with an issuer")
+		}
+		if !strings.HasPrefix(config.Sso.Issuer, "https://") && !config.Sso.AllowHttp {
+			problems = append(problems, "sso.issuer (SSO_ISSUER): must be https")
+		}
+	} else if config.Sso.DisablePasswordLogin {
+		problems = append(problems, "sso.disable_password_login (SSO_DISABLE_PASSWORD_LOGIN): nobody could login without an issuer (SSO_ISSUER)")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func describeValidation(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if fieldError.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fieldError.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fieldError.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldError.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", fieldError.Param())
+	default:
+		return fmt.Sprintf("must be a valid %s", fieldError.Tag())
+	}
+}
+
+// Redacted = configuration keyed like the config file, with the secrets replaced
+func (config *Config) Redacted() map[string]interface{} {
+	result := map[string]interface{}{}
+
+	for _, field := range config.fields() {
+		var value interface{} = field.value.Interface()
+		if field.field.Tag.Get("secret") == "true" && !field.value.IsZero() {
+			value = redacted
+		}
+
+		keys := strings.Split(field.path, ".")
+		section := result
+		for _, key := range keys[:len(keys)-1] {
+			if _, ok := section[key]; !ok {
+				section[key] = map[string]interface{}{}
+			}
+			section = section[key].(map[string]interface{})
+		}
+		section[keys[len(keys)-1]] = value
+	}
+
+	return result
+}
+
+// envValues = effective values by env name, for `Getenv`
+func (config *Config) envValues() map[string]string {
+	values := map[string]string{}
+
+	for _, field := range config.fields() {
+		env := field.field.Tag.Get("env")
+		if env == "" || field.value.IsZero() {
+			continue
+		}
+
+		if list, ok := field.value.Interface().([]string); ok {
+			values[env] = strings.Join(list, ",")
+		} else {
+			values[env] = fmt.Sprint(field.value.Interface())
+		}
+	}
+
+	return values
+}
+
+func (config *Config) fields() []configField {
+	return collectFields(reflect.ValueOf(config).Elem(), "")
+}
+
+func collectFields(value reflect.Value, prefix string) []configField {
+	fields := []configField{}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		path := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+
+		if field.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectFields(value.Field(i), path+".")...)
+			continue
+		}
+
+		fields = append(fields, configField{path: path, field: field, value: value.Field(i)})
+	}
+
+	return fields
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Slice:
+		list := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Kind())
+	}
+
+	return nil
+}
@@ -9,14 +9,32 @@ import (
 )
 
 type ConfigModule struct {
+	values    *Config
+	envValues map[string]string
 }
 
 func NewModule() *ConfigModule {
+	LoadDotEnv()
+
+	values, err := Load(FilePath())
+
+	if err == nil {
+		err = values.Validate()
+	}
+
+	if err != nil {
+		log.Fatalf("%v\nrun `config:check` to see the effective configuration", err)
+	}
+
+	return &ConfigModule{values: values, envValues: values.envValues()}
+}
+
+// LoadDotEnv = envs of `.env`, real envs take precedence
+func LoadDotEnv() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file provided, will use OS env only")
 	}
-	return &ConfigModule{}
 }
 
 func ProvideService(module *ConfigModule) ConfigService {
@@ -3,12 +3,20 @@ package config
 import "os"
 
 type ConfigService interface {
+	// Getenv = raw value of key, prefer `Config` for the keys it has
 	Getenv(key string, fallback string) string
+	// Config = typed & validated configuration
+	Config() *Config
 }
 
 // impl `ConfigService` start
 
-func (*ConfigModule) Getenv(key string, fallback string) string {
+func (module *ConfigModule) Getenv(key string, fallback string) string {
+	// values of the config file are only known by the typed configuration
+	if value, ok := module.envValues[key]; ok {
+		return value
+	}
+
 	value := os.Getenv(key)
 	if len(value) == 0 {
 		return fallback
@@ -16,4 +24,8 @@ func (*ConfigModule) Getenv(key string, fallback string) string {
 	return value
 }
 
+func (module *ConfigModule) Config() *Config {
+	return module.values
+}
+
 // impl `ConfigService` end
@@ -1,12 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
-	"gorm.io/driver/mysql"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -22,7 +26,15 @@ type DbService interface {
 	RemoveConfig(profName string)
 	Default() *gorm.DB
 	Get(profName string) *gorm.DB
+	// Reader = default profile for read only queries, a replica unless the request already wrote. see replica.go
+	Reader(context context.Context) *gorm.DB
+	// Read = run query on `Reader`, retried on the primary when a replica does not have the record (yet)
+	Read(context context.Context, query func(tx *gorm.DB) error) error
 	AutoMigrate() bool
+	// Profiles = names of the connected profiles, sorted
+	Profiles() []string
+	// Ping = check the connection of a profile and of its replicas
+	Ping(context context.Context, profName string) error
 }
 
 // DbProfile = db configuration
@@ -36,32 +48,90 @@ type DbProfile struct {
 	Locale     string
 	DbUrl      string
 	Logging    bool
+	// Replicas = read only copies of the database, same connection & credentials
+	Replicas []DbProfile
 }
 
 func (module *DbModule) addDefaultConfig() {
-	module.autoMigrate = module.config.Getenv("DB_AUTOMIGRATION", "") == "true"
-	module.AddConfig(DefaultDbKey, &DbProfile{
-		Connection: module.config.Getenv("DB_CONNECTION", ""),
-		Host:       module.config.Getenv("DB_HOST", ""),
-		Port:       module.config.Getenv("DB_PORT", ""),
-		Database:   module.config.Getenv("DB_DATABASE", ""),
-		Username:   module.config.Getenv("DB_USERNAME", ""),
-		Password:   module.config.Getenv("DB_PASSWORD", ""),
-		Locale:     module.config.Getenv("DB_LOCALE", ""),
-		DbUrl:      module.config.Getenv("DATABASE_URL", ""),
-		Logging:    module.config.Getenv("DB_LOGGING", "") == "true",
-	})
+	dbConfig := module.config.Config().Db
+	module.autoMigrate = dbConfig.AutoMigration
+	profile := &DbProfile{
+		Connection: dbConfig.Connection,
+		Host:       dbConfig.Host,
+		Port:       dbConfig.Port,
+		Database:   dbConfig.Database,
+		Username:   dbConfig.Username,
+		Password:   dbConfig.Password,
+		Locale:     dbConfig.Locale,
+		DbUrl:      dbConfig.Url,
+		Logging:    dbConfig.Logging,
+	}
+
+	profile.Replicas = replicaProfiles(profile, dbConfig.ReplicaHosts, dbConfig.ReplicaUrls)
+	module.AddConfig(DefaultDbKey, profile)
+}
+
+// poolStats = connection pools of every profile & replica, e.g. default, default/replica-0
+func (module *DbModule) poolStats() map[string]sql.DBStats {
+	stats := map[string]sql.DBStats{}
+
+	for profName, db := range module.db {
+		if sqlDB, err := db.DB(); err == nil {
+			stats[profName] = sqlDB.Stats()
+		}
+		if replicas, ok := module.replicas[profName]; ok {
+			for i, replica := range replicas.dbs {
+				if sqlDB, err := replica.DB(); err == nil {
+					stats[fmt.Sprintf("%s/replica-%d", profName, i)] = sqlDB.Stats()
+				}
+			}
+		}
+	}
+
+	return stats
 }
 
 // impl `DbService` start
 
 // AddConfig = add configuration
 func (module *DbModule) AddConfig(profName string, config *DbProfile) {
+	module.db[profName] = module.openProfile(profName, config)
+	delete(module.replicas, profName)
+
+	if module.autoMigrate && config.Connection == "postgres" {
+		module.db[profName].Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`)
+	}
+
+	if len(config.Replicas) == 0 {
+		return
+	}
+
+	if err := registerWriteTracking(module.db[profName]); err != nil {
+		module.fatal("DB profile callback error", "profile", profName, "error", err)
+	}
+
+	replicas := &replicaSet{}
+	for i := range config.Replicas {
+		replicas.dbs = append(replicas.dbs, module.openProfile(fmt.Sprintf("%s replica %d", profName, i), &config.Replicas[i]))
+	}
+	module.replicas[profName] = replicas
+}
+
+// fatal = nothing works without the database
+func (module *DbModule) fatal(message string, args ...interface{}) {
+	module.monitorService.Logger().Error(message, args...)
+	os.Exit(1)
+}
+
+func (module *DbModule) openProfile(profName string, config *DbProfile) *gorm.DB {
 	var err error
-	gormConfig := gorm.Config{}
+	var db *gorm.DB
+	gormConfig := gorm.Config{
+		Logger: newGormLogger(module.monitorService.Logger(), logger.Warn),
+	}
 
 	if config.Logging {
-		gormConfig.Logger = logger.Default.LogMode(logger.Info)
+		gormConfig.Logger = gormConfig.Logger.LogMode(logger.Info)
 	}
 
 	if config.Connection == "mysql" {
@@ -72,10 +142,11 @@ func (module *DbModule) AddConfig(profName string, config *DbProfile) {
 			suffix += url.QueryEscape(config.Locale)
 		}
 		suffix += "&parseTime=true&multiStatements=true"
-		module.db[profName], err = gorm.Open(
-			mysql.Open(config.Username+":"+config.Password+"@tcp("+config.Host+":"+config.Port+")/"+config.Database+suffix),
-			&gormConfig,
-		)
+		dsn := config.Username + ":" + config.Password + "@tcp(" + config.Host + ":" + config.Port + ")/" + config.Database + suffix
+		if config.DbUrl != "" {
+			dsn = config.DbUrl
+		}
+		db, err = gorm.Open(newMysqlDialector(dsn), &gormConfig)
 	} else if config.Connection == "postgres" {
 		var connInfo string
 		if config.DbUrl != "" {
@@ -85,39 +156,75 @@ func (module *DbModule) AddConfig(profName string, config *DbProfile) {
 				"password='%s' dbname='%s' sslmode=disable",
 				config.Host, config.Port, config.Username, config.Password, config.Database)
 		}
-		module.db[profName], err = gorm.Open(
+		db, err = gorm.Open(
 			postgres.Open(connInfo),
 			&gormConfig,
 		)
+	} else if config.Connection == "sqlite" {
+		// sqlite compares timestamps as text, so they all have to be written in the same zone
+		gormConfig.NowFunc = func() time.Time { return time.Now().UTC() }
+		db, err = gorm.Open(
+			sqlite.Open(sqliteDsn(config)),
+			&gormConfig,
+		)
+	} else {
+		err = fmt.Errorf("unknown connection %s", config.Connection)
 	}
 
 	var sqlDB *sql.DB
 	if err == nil {
-		sqlDB, err = module.db[profName].DB()
+		sqlDB, err = db.DB()
 	}
 
 	if err != nil {
-		log.Fatalf("DB profile `%s` connect error: %v", profName, err)
+		module.fatal("DB profile connect error", "profile", profName, "error", err)
+	}
+
+	if config.Connection == "sqlite" && isSqliteMemory(config) {
+		// every connection would get its own empty in-memory database
+		sqlDB.SetMaxOpenConns(1)
 	}
 
 	err = sqlDB.Ping()
 
 	if err != nil {
-		log.Fatalf("DB profile `%s` ping error: %v", profName, err)
+		module.fatal("DB profile ping error", "profile", profName, "error", err)
 	}
 
-	if module.autoMigrate && config.Connection == "postgres" {
-		module.db[profName].Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`)
+	if err := db.Use(tracing.NewPlugin()); err != nil {
+		panic(err)
 	}
 
-	if err := module.db[profName].Use(tracing.NewPlugin()); err != nil {
-		panic(err)
+	return db
+}
+
+// sqliteDsn = `DB_DATABASE` is the database file, `DATABASE_URL` overrides the whole dsn
+func sqliteDsn(config *DbProfile) string {
+	if config.DbUrl != "" {
+		return config.DbUrl
+	}
+
+	// immediate transactions take the write lock upfront, instead of failing when upgrading a read lock under load
+	return config.Database + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)" +
+		"&_time_format=sqlite&_txlock=immediate"
+}
+
+func isSqliteMemory(config *DbProfile) bool {
+	return config.Database == ":memory:" || strings.Contains(config.DbUrl, ":memory:") || strings.Contains(config.DbUrl, "mode=memory")
+}
+
+func pingDb(context context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
 	}
+	return sqlDB.PingContext(context)
 }
 
 // RemoveConfig = remove configuration
 func (module *DbModule) RemoveConfig(profName string) {
 	delete(module.db, profName)
+	delete(module.replicas, profName)
 }
 
 // Default : get default DB profile
@@ -134,4 +241,34 @@ func (module *DbModule) AutoMigrate() bool {
 	return module.autoMigrate
 }
 
+func (module *DbModule) Profiles() []string {
+	profiles := make([]string, 0, len(module.db))
+	for profName := range module.db {
+		profiles = append(profiles, profName)
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+func (module *DbModule) Ping(context context.Context, profName string) error {
+	db, ok := module.db[profName]
+	if !ok {
+		return fmt.Errorf("unknown DB profile `%s`", profName)
+	}
+
+	if err := pingDb(context, db); err != nil {
+		return err
+	}
+
+	if replicas, ok := module.replicas[profName]; ok {
+		for i, replica := range replicas.dbs {
+			if err := pingDb(context, replica); err != nil {
+				return fmt.Errorf("replica %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
 // impl `DbService` end
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// queries slower than this are logged as warnings
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger = the logs of gorm written by the logger of the monitor module, with the ids of the request
+type gormLogger struct {
+	logger *slog.Logger
+	level  logger.LogLevel
+}
+
+func newGormLogger(slogLogger *slog.Logger, level logger.LogLevel) logger.Interface {
+	return &gormLogger{logger: slogLogger.With("component", "gorm"), level: level}
+}
+
+func (gormLogger *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copy := *gormLogger
+	copy.level = level
+	return &copy
+}
+
+func (gormLogger *gormLogger) Info(ctx context.Context, message string, data ...interface{}) {
+	if gormLogger.level >= logger.Info {
+		gormLogger.logger.InfoContext(ctx, fmt.Sprintf(message, data...))
+	}
+}
+
+func (gormLogger *gormLogger) Warn(ctx context.Context, message string, data ...interface{}) {
+	if gormLogger.level >= logger.Warn {
+		gormLogger.logger.WarnContext(ctx, fmt.Sprintf(message, data...))
+	}
+}
+
+func (gormLogger *gormLogger) Error(ctx context.Context, message string, data ...interface{}) {
+	if gormLogger.level >= logger.Error {
+		gormLogger.logger.ErrorContext(ctx, fmt.Sprintf(message, data...))
+	}
+}
+
+func (gormLogger *gormLogger) Trace(ctx context.Context, begin time.Time, query func() (string, int64), err error) {
+	if gormLogger.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+
+	switch {
+	// not found is an answer, not a failure
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && gormLogger.level >= logger.Error:
+		sql, rows := query()
+		gormLogger.logger.ErrorContext(ctx, "sql error", "error", err, "sql", sql, "rows", rows, "duration_ms", durationMs(elapsed))
+	case elapsed > slowQueryThreshold && gormLogger.level >= logger.Warn:
+		sql, rows := query()
+		gormLogger.logger.WarnContext(ctx, "slow sql", "sql", sql, "rows", rows, "duration_ms", durationMs(elapsed))
+	case gormLogger.level >= logger.Info:
+		sql, rows := query()
+		gormLogger.logger.InfoContext(ctx, "sql", "sql", sql, "rows", rows, "duration_ms", durationMs(elapsed))
+	}
+}
+
+func durationMs(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
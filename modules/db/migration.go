@@ -0,0 +1,503 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"private-pub-repo/migrations"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MigrationStateApplied  = "applied"
+	MigrationStatePending  = "pending"
+	MigrationStateModified = "modified"
+	// applied on the database, but unknown to this binary, e.g. after a downgrade
+	MigrationStateUnknown = "unknown"
+
+	migrationSumFile = "atlas.sum"
+	migrationDownDir = "down"
+	// any constant works, it only has to be the same for every instance
+	migrationLockId   = 7262010117
+	migrationLockName = "pubserver_migration"
+)
+
+// SchemaRevision = migration applied on the database
+type SchemaRevision struct {
+	Version     string    `gorm:"size:32;primaryKey"`
+	Description string    `gorm:"not null"`
+	Hash        string    `gorm:"size:64;not null"`
+	AppliedAt   time.Time `gorm:"not null"`
+	ExecutionMs int64     `gorm:"not null"`
+}
+
+func (SchemaRevision) TableName() string {
+	return "schema_revisions"
+}
+
+// Migration = migration file of the embedded directory
+type Migration struct {
+	Version     string
+	Description string
+	Up          string
+	Down        string
+	Hash        string
+}
+
+type MigrationStatus struct {
+	Version     string     `json:"version"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	AppliedAt   *time.Time `json:"applied_at"`
+}
+
+// Migrator runs the embedded atlas migrations of the dialect of db, without atlas.
+// applied versions are tracked in `schema_revisions`
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	dir, err := migrations.Dir(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyMigrationSum(dir); err != nil {
+		return nil, err
+	}
+
+	list, err := readMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+func (migrator *Migrator) Status(context context.Context) ([]MigrationStatus, error) {
+	revisions, err := migrator.revisions(migrator.db.WithContext(context))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range migrator.migrations {
+		status := MigrationStatus{Version: migration.Version, Description: migration.Description, State: MigrationStatePending}
+
+		if revision, ok := revisions[migration.Version]; ok {
+			status.State = MigrationStateApplied
+			status.AppliedAt = &revision.AppliedAt
+			if revision.Hash != migration.Hash {
+				status.State = MigrationStateModified
+			}
+			delete(revisions, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, revision := range revisions {
+		appliedAt := revision.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version: revision.Version, Description: revision.Description, State: MigrationStateUnknown, AppliedAt: &appliedAt,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// Migrate applies the pending migrations up to target (all when empty), each in its own transaction.
+// mysql commits DDL implicitly, a failing migration there may be left half applied
+func (migrator *Migrator) Migrate(context context.Context, target string, dryRun bool) ([]Migration, error) {
+	applied := []Migration{}
+
+	err := migrator.locked(context, func(conn *gorm.DB) error {
+		revisions, err := migrator.revisions(conn)
+		if err != nil {
+			return err
+		}
+
+		if len(revisions) == 0 && conn.Migrator().HasTable(atlasRevisionTable(conn)) {
+			return fmt.Errorf("database is migrated by atlas, mark its last applied version with `db:migrate --baseline <version>` once")
+		}
+
+		latest := latestRevision(revisions)
+
+		for _, migration := range migrator.migrations {
+			if target != "" && migration.Version > target {
+				break
+			}
+
+			if _, ok := revisions[migration.Version]; ok {
+				continue
+			}
+
+			if migration.Version < latest {
+				return fmt.Errorf("migration %s is older than the last applied migration %s", migration.Version, latest)
+			}
+
+			if !dryRun {
+				if err := migrator.apply(conn, migration.Version, migration.Up, &migration); err != nil {
+					return err
+				}
+			}
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Baseline marks the migrations up to version as applied without running them,
+// for databases migrated before, e.g. by atlas
+func (migrator *Migrator) Baseline(context context.Context, version string) ([]Migration, error) {
+	marked := []Migration{}
+
+	err := migrator.locked(context, func(conn *gorm.DB) error {
+		revisions, err := migrator.revisions(conn)
+		if err != nil {
+			return err
+		}
+
+		if len(revisions) > 0 {
+			return fmt.Errorf("baseline only works on a database without applied migrations")
+		}
+
+		if _, err := migrator.find(version); err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			if migration.Version > version {
+				break
+			}
+
+			if err := migrator.apply(conn, migration.Version, "", &migration); err != nil {
+				return err
+			}
+			marked = append(marked, migration)
+		}
+
+		return nil
+	})
+
+	return marked, err
+}
+
+// Rollback reverts the last steps applied migrations, or all the ones after target when it is set,
+// with the `down/<version>.sql` of each of them
+func (migrator *Migrator) Rollback(context context.Context, steps int, target string, dryRun bool) ([]Migration, error) {
+	reverted := []Migration{}
+
+	err := migrator.locked(context, func(conn *gorm.DB) error {
+		revisions, err := migrator.revisions(conn)
+		if err != nil {
+			return err
+		}
+
+		if target != "" {
+			if _, err := migrator.find(target); err != nil {
+				return err
+			}
+			steps = len(revisions)
+		}
+
+		versions := make([]string, 0, len(revisions))
+		for version := range revisions {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+
+		for _, version := range versions {
+			if len(reverted) >= steps || (target != "" && version <= target) {
+				break
+			}
+
+			migration, err := migrator.find(version)
+			if err != nil {
+				return err
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %s has no %s/%s.sql, it can't be rolled back", version, migrationDownDir, version)
+			}
+
+			if !dryRun {
+				if err := migrator.apply(conn, version, migration.Down, nil); err != nil {
+					return err
+				}
+			}
+			reverted = append(reverted, *migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// apply runs the statements, then records the migration as applied, or as reverted when migration is nil
+func (migrator *Migrator) apply(conn *gorm.DB, version string, script string, migration *Migration) error {
+	startedAt := time.Now()
+
+	return conn.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("migration %s: %w\n%s", version, err, statement)
+			}
+		}
+
+		if migration == nil {
+			return tx.Delete(&SchemaRevision{}, "version = ?", version).Error
+		}
+
+		return tx.Create(&SchemaRevision{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Hash:        migration.Hash,
+			AppliedAt:   time.Now(),
+			ExecutionMs: time.Since(startedAt).Milliseconds(),
+		}).Error
+	})
+}
+
+func (migrator *Migrator) revisions(conn *gorm.DB) (map[string]SchemaRevision, error) {
+	if err := conn.AutoMigrate(&SchemaRevision{}); err != nil {
+		return nil, err
+	}
+
+	list := []SchemaRevision{}
+	if err := conn.Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	revisions := map[string]SchemaRevision{}
+	for _, revision := range list {
+		revisions[revision.Version] = revision
+	}
+	return revisions, nil
+}
+
+func (migrator *Migrator) find(version string) (*Migration, error) {
+	for i := range migrator.migrations {
+		if migrator.migrations[i].Version == version {
+			return &migrator.migrations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown migration %s", version)
+}
+
+// locked keeps other instances from migrating at the same time, sqlite only has a single writer anyway
+func (migrator *Migrator) locked(context context.Context, action func(conn *gorm.DB) error) error {
+	return migrator.db.WithContext(context).Connection(func(conn *gorm.DB) error {
+		switch conn.Dialector.Name() {
+		case "postgres":
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockId).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockId)
+		case "mysql":
+			var locked int
+			if err := conn.Raw("SELECT GET_LOCK(?, 600)", migrationLockName).Scan(&locked).Error; err != nil {
+				return err
+			}
+			if locked != 1 {
+				return fmt.Errorf("timeout waiting for the migration lock")
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", migrationLockName)
+		}
+
+		return action(conn)
+	})
+}
+
+// atlasRevisionTable = where `atlas migrate apply` keeps its revisions, postgres gets a schema of its own
+func atlasRevisionTable(conn *gorm.DB) string {
+	if conn.Dialector.Name() == "postgres" {
+		return "atlas_schema_revisions.atlas_schema_revisions"
+	}
+	return "atlas_schema_revisions"
+}
+
+func latestRevision(revisions map[string]SchemaRevision) string {
+	latest := ""
+	for version := range revisions {
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// readMigrations = `<version>[_<description>].sql` files, sorted by version, like atlas
+func readMigrations(dir fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(dir, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	list := []Migration{}
+	for _, name := range names {
+		content, err := fs.ReadFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		version, description, _ := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		hash := sha256.Sum256(content)
+		migration := Migration{Version: version, Description: description, Up: string(content), Hash: hex.EncodeToString(hash[:])}
+
+		down, err := fs.ReadFile(dir, migrationDownDir+"/"+version+".sql")
+		if err == nil {
+			migration.Down = string(down)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		list = append(list, migration)
+	}
+
+	return list, nil
+}
+
+// verifyMigrationSum checks the directory against its atlas.sum, the same way `atlas migrate` does
+func verifyMigrationSum(dir fs.FS) error {
+	content, err := fs.ReadFile(dir, migrationSumFile)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(dir, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	// every file hash covers the files before it too
+	expected := []string{}
+	fileHash := sha256.New()
+	for _, name := range names {
+		file, err := fs.ReadFile(dir, name)
+		if err != nil {
+			return err
+		}
+		fileHash.Write([]byte(name))
+		fileHash.Write(file)
+		expected = append(expected, name+" h1:"+base64.StdEncoding.EncodeToString(fileHash.Sum(nil)))
+	}
+
+	sumHash := sha256.New()
+	for _, line := range expected {
+		name, hash, _ := strings.Cut(line, " h1:")
+		sumHash.Write([]byte(name))
+		sumHash.Write([]byte(hash))
+	}
+	expected = append([]string{"h1:" + base64.StdEncoding.EncodeToString(sumHash.Sum(nil))}, expected...)
+
+	actual := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			actual = append(actual, line)
+		}
+	}
+
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		return fmt.Errorf("migration files do not match %s, run `atlas migrate hash`", migrationSumFile)
+	}
+
+	return nil
+}
+
+// splitStatements splits a migration on `;`, except inside quotes, comments and postgres dollar quoted bodies
+func splitStatements(script string) []string {
+	statements := []string{}
+	current := strings.Builder{}
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" && !onlyComments(statement) {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		char := script[i]
+
+		switch {
+		case char == '\'' || char == '"' || char == '`':
+			end := i + 1
+			for end < len(script) && script[end] != char {
+				if script[end] == '\\' && char == '\'' {
+					end++
+				}
+				end++
+			}
+			current.WriteString(script[i:min(end+1, len(script))])
+			i = end
+		case char == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			current.WriteString(script[i : i+end])
+			i += end - 1
+		case char == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 4
+			}
+			current.WriteString(script[i:min(i+end+4, len(script))])
+			i += end + 3
+		case char == '$':
+			tagEnd := strings.IndexByte(script[i+1:], '$')
+			tag := ""
+			if tagEnd >= 0 {
+				tag = script[i : i+tagEnd+2]
+			}
+			if tag == "" || strings.ContainsAny(tag[1:len(tag)-1], " \t\n;'\"") {
+				current.WriteByte(char)
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				end = len(script) - i - 2*len(tag)
+			}
+			current.WriteString(script[i:min(i+end+2*len(tag), len(script))])
+			i += end + 2*len(tag) - 1
+		case char == ';':
+			flush()
+		default:
+			current.WriteByte(char)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+func onlyComments(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
@@ -1,21 +1,34 @@
 package db
 
 import (
+	"context"
 	"private-pub-repo/base"
+	"private-pub-repo/modules/app"
 	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/monitor"
 
+	"github.com/gofiber/fiber/v2"
 	"go.uber.org/fx"
 	"gorm.io/gorm"
 )
 
 type DbModule struct {
-	config      config.ConfigService
-	db          map[string]*gorm.DB
-	autoMigrate bool
+	config         config.ConfigService
+	app            *fiber.App
+	monitorService monitor.MonitorService
+	db             map[string]*gorm.DB
+	replicas       map[string]*replicaSet
+	autoMigrate    bool
 }
 
-func NewModule(config config.ConfigService) *DbModule {
-	return &DbModule{config: config, db: map[string]*gorm.DB{}}
+func NewModule(config config.ConfigService, app *fiber.App, monitorService monitor.MonitorService) *DbModule {
+	return &DbModule{
+		config:         config,
+		app:            app,
+		monitorService: monitorService,
+		db:             map[string]*gorm.DB{},
+		replicas:       map[string]*replicaSet{},
+	}
 }
 
 func ProvideService(module *DbModule) DbService {
@@ -26,16 +39,44 @@ func fxRegister(lifeCycle fx.Lifecycle, module *DbModule) {
 	base.FxRegister(module, lifeCycle)
 }
 
-func SetupModule(config config.ConfigService) *DbModule {
-	return NewModule(config)
+func SetupModule(config config.ConfigService, app *app.AppModule, monitor *monitor.MonitorModule) *DbModule {
+	return NewModule(config, app.App, monitor.Service)
 }
 
 var FxModule = fx.Module("Db", fx.Provide(NewModule), fx.Provide(ProvideService), fx.Invoke(fxRegister))
 
+// Connect = connect the default profile, for commands that don't start the module
+func (module *DbModule) Connect() {
+	module.addDefaultConfig()
+}
+
+// migrateOnStart = apply pending migrations before any other module touches the database
+func (module *DbModule) migrateOnStart() {
+	migrator, err := NewMigrator(module.Default())
+
+	if err == nil {
+		var applied []Migration
+		applied, err = migrator.Migrate(context.Background(), "", false)
+		for _, migration := range applied {
+			module.monitorService.Logger().Info("migration applied", "version", migration.Version)
+		}
+	}
+
+	if err != nil {
+		module.fatal("migration error", "error", err)
+	}
+}
+
 // implements `BaseModule` of `base/module.go` start
 
 func (module *DbModule) OnStart() error {
-	module.addDefaultConfig()
+	module.Connect()
+	module.app.Use(module.readSessionMiddleware)
+	module.monitorService.ObserveDbPools(module.poolStats)
+
+	if module.config.Config().Db.MigrateOnStart {
+		module.migrateOnStart()
+	}
 	return nil
 }
 
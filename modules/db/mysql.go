@@ -0,0 +1,43 @@
+package db
+
+import (
+	"strings"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// mysqlDialector adapts the postgres flavoured model tags for auto migration on mysql
+type mysqlDialector struct {
+	mysql.Dialector
+}
+
+type mysqlMigrator struct {
+	mysql.Migrator
+}
+
+func newMysqlDialector(dsn string) gorm.Dialector {
+	return mysqlDialector{Dialector: *mysql.Open(dsn).(*mysql.Dialector)}
+}
+
+func (dialector mysqlDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return mysqlMigrator{Migrator: dialector.Dialector.Migrator(db).(mysql.Migrator)}
+}
+
+func (migrator mysqlMigrator) FullDataTypeOf(field *schema.Field) clause.Expr {
+	expr := migrator.Migrator.FullDataTypeOf(field)
+
+	// mysql has no uuid type, uuids are stored as text
+	if field.DataType == "uuid" {
+		expr.SQL = "char(36)" + strings.TrimPrefix(expr.SQL, "uuid")
+	}
+
+	// json columns only take expressions as default
+	if strings.EqualFold(string(field.DataType), "json") && field.HasDefaultValue && field.DefaultValue != "" {
+		expr.SQL = strings.Replace(expr.SQL, "DEFAULT "+field.DefaultValue, "DEFAULT ("+field.DefaultValue+")", 1)
+	}
+
+	return expr
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// replicas only serve queries that go through `Reader` / `Read`, everything else stays on the primary.
+// a request reads from the primary again once it wrote anything, so it always sees its own writes.
+// outside of a request (commands, jobs) there is no way to tell, so everything goes to the primary.
+
+type replicaSet struct {
+	dbs  []*gorm.DB
+	next atomic.Uint64
+}
+
+// pick = round robin over the replicas
+func (replicas *replicaSet) pick() *gorm.DB {
+	return replicas.dbs[(replicas.next.Add(1)-1)%uint64(len(replicas.dbs))]
+}
+
+type readSessionKey struct{}
+
+// readSession = routing state of a single request
+type readSession struct {
+	written atomic.Bool
+}
+
+func withReadSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readSessionKey{}, &readSession{})
+}
+
+// WithoutWriteTracking = the statements of the returned context don't make the request stick to the primary,
+// for bookkeeping writes the reads of the request don't depend on (e.g. rate limit counters)
+func WithoutWriteTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readSessionKey{}, (*readSession)(nil))
+}
+
+func getReadSession(ctx context.Context) *readSession {
+	if ctx == nil {
+		return nil
+	}
+	session, _ := ctx.Value(readSessionKey{}).(*readSession)
+	return session
+}
+
+func (module *DbModule) readSessionMiddleware(c *fiber.Ctx) error {
+	c.SetUserContext(withReadSession(c.UserContext()))
+	return c.Next()
+}
+
+// registerWriteTracking marks the request of every statement executed on the primary as written.
+// reads on the primary are marked too (raw queries can't be told apart), they only make the request stick to the primary
+func registerWriteTracking(db *gorm.DB) error {
+	markWritten := func(tx *gorm.DB) {
+		if session := getReadSession(tx.Statement.Context); session != nil {
+			session.written.Store(true)
+		}
+	}
+
+	callback := db.Callback()
+
+	return errors.Join(
+		callback.Create().After("gorm:create").Register("db:mark_written", markWritten),
+		callback.Update().After("gorm:update").Register("db:mark_written", markWritten),
+		callback.Delete().After("gorm:delete").Register("db:mark_written", markWritten),
+		callback.Raw().After("gorm:raw").Register("db:mark_written", markWritten),
+	)
+}
+
+// replicaProfiles = replicas of the primary profile, either hosts (`host` or `host:port`, the rest of the primary
+// configuration is reused) or complete urls
+func replicaProfiles(primary *DbProfile, hosts []string, urls []string) []DbProfile {
+	replicas := []DbProfile{}
+
+	for _, host := range hosts {
+		replica := *primary
+		replica.DbUrl = ""
+		replica.Host, replica.Port, _ = strings.Cut(host, ":")
+		if replica.Port == "" {
+			replica.Port = primary.Port
+		}
+		replicas = append(replicas, replica)
+	}
+
+	for _, url := range urls {
+		replica := *primary
+		replica.DbUrl = url
+		replicas = append(replicas, replica)
+	}
+
+	return replicas
+}
+
+// impl `DbService` start
+
+func (module *DbModule) Reader(context context.Context) *gorm.DB {
+	db, _ := module.reader(context)
+	return db.WithContext(context)
+}
+
+func (module *DbModule) Read(context context.Context, query func(tx *gorm.DB) error) error {
+	db, replica := module.reader(context)
+	err := query(db.WithContext(context))
+
+	// replication lag, e.g. a token used right after it was created
+	if replica && errors.Is(err, gorm.ErrRecordNotFound) {
+		err = query(module.Default().WithContext(context))
+	}
+
+	return err
+}
+
+// impl `DbService` end
+
+func (module *DbModule) reader(context context.Context) (*gorm.DB, bool) {
+	replicas := module.replicas[DefaultDbKey]
+	session := getReadSession(context)
+
+	if replicas == nil || session == nil || session.written.Load() {
+		return module.Default(), false
+	}
+
+	return replicas.pick(), true
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/health/healthdto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type healthController struct {
+	service         HealthService
+	responseService app.ResponseService
+}
+
+func newHealthController(service HealthService, responseService app.ResponseService) *healthController {
+	return &healthController{
+		service:         service,
+		responseService: responseService,
+	}
+}
+
+// handlers start
+
+// probes answer plain json, not the response envelope, they are read by kubernetes & load balancers
+
+func (controller *healthController) handleLiveness(ctx *fiber.Ctx) error {
+	return ctx.JSON(controller.service.Liveness())
+}
+
+func (controller *healthController) handleReadiness(ctx *fiber.Ctx) error {
+	report := controller.service.Readiness(ctx.UserContext())
+
+	// errors may contain hosts & bucket names, only admins get them through the status endpoint
+	for i := range report.Components {
+		report.Components[i].Error = ""
+	}
+
+	status := fiber.StatusOK
+	if report.Status != healthdto.StatusUp {
+		status = fiber.StatusServiceUnavailable
+	}
+
+	return ctx.Status(status).JSON(report)
+}
+
+func (controller *healthController) handleStatus(ctx *fiber.Ctx) error {
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, controller.service.Readiness(ctx.UserContext()))
+}
+
+// handlers end
@@ -0,0 +1,23 @@
+package healthdto
+
+import "time"
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type ComponentDTO struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// false for components the server works without, e.g. smtp
+	Required  bool    `json:"required"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReportDTO struct {
+	Status     string         `json:"status"`
+	CheckedAt  time.Time      `json:"checked_at"`
+	Components []ComponentDTO `json:"components,omitempty"`
+}
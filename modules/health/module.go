@@ -0,0 +1,52 @@
+package health
+
+import (
+	"private-pub-repo/base"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/jwt"
+	"private-pub-repo/modules/mail"
+	"private-pub-repo/modules/storage"
+	"private-pub-repo/modules/user"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/fx"
+)
+
+type HealthModule struct {
+	Service        HealthService
+	controller     *healthController
+	jwtService     jwt.JwtService
+	userMiddleware user.UserJwtMiddleware
+	app            *fiber.App
+}
+
+func NewModule(service HealthService, controller *healthController, jwtService jwt.JwtService, userMiddleware user.UserJwtMiddleware, app *fiber.App) *HealthModule {
+	return &HealthModule{Service: service, controller: controller, jwtService: jwtService, userMiddleware: userMiddleware, app: app}
+}
+
+func fxRegister(lifeCycle fx.Lifecycle, module *HealthModule) {
+	base.FxRegister(module, lifeCycle)
+}
+
+func SetupModule(app *app.AppModule, db *db.DbModule, storage *storage.StorageModule, mail *mail.MailModule, jwt *jwt.JwtModule, user *user.UserModule, config *config.ConfigModule) *HealthModule {
+	service := NewHealthService(db, storage, mail, config)
+	controller := newHealthController(service, app.ResponseService)
+	return NewModule(service, controller, jwt, user.Middleware, app.App)
+}
+
+var FxModule = fx.Module("Health", fx.Provide(NewHealthService), fx.Provide(newHealthController), fx.Provide(NewModule), fx.Invoke(fxRegister))
+
+// implements `BaseModule` of `base/module.go` start
+
+func (module *HealthModule) OnStart() error {
+	module.registerRoutes()
+	return nil
+}
+
+func (module *HealthModule) OnStop() error {
+	return nil
+}
+
+// implements `BaseModule` of `base/module.go` end
@@ -0,0 +1,15 @@
+package health
+
+const (
+	rootPath      = "/"
+	livenessPath  = "livez"
+	readinessPath = "readyz"
+	statusPath    = "v1/status"
+)
+
+func (module *HealthModule) registerRoutes() {
+	module.app.Get(rootPath, module.controller.handleLiveness)
+	module.app.Get(livenessPath, module.controller.handleLiveness)
+	module.app.Get(readinessPath, module.controller.handleReadiness)
+	module.app.Get(statusPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleStatus)
+}
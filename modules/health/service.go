@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/health/healthdto"
+	"private-pub-repo/modules/mail"
+	"private-pub-repo/modules/storage"
+	"sync"
+	"time"
+)
+
+type HealthService interface {
+	// Liveness = the process serves requests, dependencies are not checked
+	Liveness() *healthdto.ReportDTO
+	// Readiness = every component checked concurrently, down when a required one is down
+	Readiness(context context.Context) *healthdto.ReportDTO
+}
+
+type componentCheck struct {
+	name     string
+	required bool
+	run      func(context context.Context) error
+}
+
+type healthServiceImpl struct {
+	dbService      db.DbService
+	storageService storage.StorageService
+	mailService    mail.MailService
+	timeout        time.Duration
+	checkSmtp      bool
+}
+
+func NewHealthService(dbService db.DbService, storageService storage.StorageService, mailService mail.MailService, config config.ConfigService) HealthService {
+	return &healthServiceImpl{
+		dbService:      dbService,
+		storageService: storageService,
+		mailService:    mailService,
+		timeout:        time.Duration(config.Config().Health.Timeout) * time.Second,
+		checkSmtp:      config.Config().Health.CheckSmtp,
+	}
+}
+
+func (service *healthServiceImpl) checks() []componentCheck {
+	checks := []componentCheck{}
+
+	for _, profName := range service.dbService.Profiles() {
+		checks = append(checks, componentCheck{name: "db:" + profName, required: true, run: func(context context.Context) error {
+			return service.dbService.Ping(context, profName)
+		}})
+	}
+
+	checks = append(checks, componentCheck{name: "storage", required: true, run: service.storageService.Ping})
+
+	if service.checkSmtp && service.mailService.Configured() {
+		checks = append(checks, componentCheck{name: "smtp", required: false, run: service.mailService.Ping})
+	}
+
+	return checks
+}
+
+func (service *healthServiceImpl) runCheck(parent context.Context, check componentCheck) healthdto.ComponentDTO {
+	checkContext, cancel := context.WithTimeout(parent, service.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.run(checkContext)
+
+	component := healthdto.ComponentDTO{
+		Name:      check.name,
+		Status:    healthdto.StatusUp,
+		Required:  check.required,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		component.Status = healthdto.StatusDown
+		component.Error = err.Error()
+	}
+
+	return component
+}
+
+// impl `HealthService` start
+
+func (service *healthServiceImpl) Liveness() *healthdto.ReportDTO {
+	return &healthdto.ReportDTO{Status: healthdto.StatusUp, CheckedAt: time.Now()}
+}
+
+func (service *healthServiceImpl) Readiness(context context.Context) *healthdto.ReportDTO {
+	checks := service.checks()
+	report := &healthdto.ReportDTO{
+		Status:     healthdto.StatusUp,
+		CheckedAt:  time.Now(),
+		Components: make([]healthdto.ComponentDTO, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Components[i] = service.runCheck(context, checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		if component.Required && component.Status == healthdto.StatusDown {
+			report.Status = healthdto.StatusDown
+		}
+	}
+
+	return report
+}
+
+// impl `HealthService` end
@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jwk = public part of a signing key, RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// rsa
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+func toJwk(key *SigningKey) jwk {
+	result := jwk{Kid: key.Id, Use: "sig", Alg: key.Algorithm}
+
+	switch public := key.public().(type) {
+	case *rsa.PublicKey:
+		result.Kty = "RSA"
+		result.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		result.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		result.Kty = "OKP"
+		result.Crv = "Ed25519"
+		result.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return result
+}
+
+// handleJwks = every key, including the ones not active yet so verifiers know them before they sign.
+// plain json instead of the response envelope, as expected by the jwt libraries
+func (module *JwtModule) handleJwks(c *fiber.Ctx) error {
+	set := jwkSet{Keys: []jwk{}}
+	for _, key := range *module.keys.Load() {
+		set.Keys = append(set.Keys, toJwk(key))
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(module.config.Config().Jwt.KeysRefresh))
+	return c.JSON(set)
+}
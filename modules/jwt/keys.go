@@ -0,0 +1,207 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	keyExtension = ".pem"
+	// start of the key ids, the time the key starts signing
+	keyIdTimeLayout = "20060102150405"
+	rsaKeyBits      = 3072
+	minRsaKeyBits   = 2048
+)
+
+var (
+	ErrNoSigningKey = errors.New("no active signing key")
+	errUnknownKey   = errors.New("unknown signing key")
+)
+
+// SigningKey = private key of the keys directory, the file name is the key id
+type SigningKey struct {
+	Id        string
+	Algorithm string
+	// the key signs from then on, until a more recent one of the same algorithm is active
+	ActiveFrom time.Time
+	method     jwt.SigningMethod
+	private    crypto.Signer
+}
+
+func (key *SigningKey) public() crypto.PublicKey {
+	return key.private.Public()
+}
+
+// keySet = the keys by activation, oldest first
+type keySet []*SigningKey
+
+// signingKey = most recently activated key of algorithm, nil when none is active yet
+func (keys keySet) signingKey(algorithm string, now time.Time) *SigningKey {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].Algorithm == algorithm && !keys[i].ActiveFrom.After(now) {
+			return keys[i]
+		}
+	}
+	return nil
+}
+
+func (keys keySet) find(id string) *SigningKey {
+	for _, key := range keys {
+		if key.Id == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// NewKeyId = `<activation time, utc>-<random>`, sortable by activation
+func NewKeyId(activeFrom time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return activeFrom.UTC().Format(keyIdTimeLayout) + "-" + hex.EncodeToString(suffix)
+}
+
+func keyActivation(id string) (time.Time, error) {
+	prefix, _, ok := strings.Cut(id, "-")
+	if !ok {
+		return time.Time{}, fmt.Errorf("key id %q is not `<yyyymmddhhmmss>-<suffix>`", id)
+	}
+
+	activeFrom, err := time.Parse(keyIdTimeLayout, prefix)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("key id %q is not `<yyyymmddhhmmss>-<suffix>`", id)
+	}
+
+	return activeFrom, nil
+}
+
+// GenerateKey = new PKCS#8 pem private key of algorithm
+func GenerateKey(algorithm string) ([]byte, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("algorithm %s has no key, use %s or %s", algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parseKey(id string, content []byte) (*SigningKey, error) {
+	activeFrom, err := keyActivation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("key %s is not a PKCS#8 pem private key", id)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+
+	key := &SigningKey{Id: id, ActiveFrom: activeFrom}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < minRsaKeyBits {
+			return nil, fmt.Errorf("key %s: rsa keys need at least %d bits", id, minRsaKeyBits)
+		}
+		key.Algorithm, key.method, key.private = AlgorithmRS256, jwt.SigningMethodRS256, private
+	case ed25519.PrivateKey:
+		key.Algorithm, key.method, key.private = AlgorithmEdDSA, jwt.SigningMethodEdDSA, private
+	default:
+		return nil, fmt.Errorf("key %s: only rsa & ed25519 keys are supported", id)
+	}
+
+	return key, nil
+}
+
+// LoadKeys = every `.pem` key of dir, one invalid key fails the whole directory
+func LoadKeys(dir string) ([]*SigningKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := keySet{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyExtension {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseKey(strings.TrimSuffix(entry.Name(), keyExtension), content)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	slices.SortFunc(keys, func(a *SigningKey, b *SigningKey) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	return keys, nil
+}
+
+// WriteKey = the key file of id in dir, readable by the owner only
+func WriteKey(dir string, id string, content []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, id+keyExtension)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(content); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -1,9 +1,12 @@
 package jwt
 
 import (
+	"os"
 	"private-pub-repo/base"
 	"private-pub-repo/modules/app"
 	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/monitor"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,18 +19,28 @@ type JwtClaim = jwt.MapClaims
 type JwtModule struct {
 	config          config.ConfigService
 	responseService app.ResponseService
+	monitorService  monitor.MonitorService
+	app             *fiber.App
 	lifetime        time.Duration
 	refreshLifetime time.Duration
+	algorithm       string
 	secret          string
+	// keys of the keys directory, replaced as a whole on reload
+	keys            atomic.Pointer[keySet]
 	handler         fiber.Handler
 	optionalHandler fiber.Handler
+	stopReload      chan struct{}
 }
 
-func NewModule(config config.ConfigService, responseService app.ResponseService) *JwtModule {
-	return &JwtModule{
+func NewModule(config config.ConfigService, responseService app.ResponseService, monitorService monitor.MonitorService, app *fiber.App) *JwtModule {
+	module := &JwtModule{
 		config:          config,
 		responseService: responseService,
+		monitorService:  monitorService,
+		app:             app,
 	}
+	module.keys.Store(&keySet{})
+	return module
 }
 
 func ProvideService(module *JwtModule) JwtService {
@@ -38,21 +51,57 @@ func fxRegister(lifeCycle fx.Lifecycle, module *JwtModule) {
 	base.FxRegister(module, lifeCycle)
 }
 
-func SetupModule(app *app.AppModule, config *config.ConfigModule) *JwtModule {
-	return NewModule(config, app.ResponseService)
+func SetupModule(app *app.AppModule, config *config.ConfigModule, monitor *monitor.MonitorModule) *JwtModule {
+	return NewModule(config, app.ResponseService, monitor.Service, app.App)
 }
 
 var FxModule = fx.Module("Jwt", fx.Provide(NewModule), fx.Provide(ProvideService), fx.Invoke(fxRegister))
 
+// startReload picks up the keys added to & removed from the keys directory
+func (module *JwtModule) startReload() {
+	module.stopReload = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(time.Duration(module.config.Config().Jwt.KeysRefresh) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				// the previous keys stay in use, a half written key file must not log everyone out
+				if err := module.reloadKeys(); err != nil {
+					module.monitorService.Logger().Error("jwt keys reload failed", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(module.stopReload)
+}
+
 // implements `BaseModule` of `base/module.go` start
 
 func (module *JwtModule) OnStart() error {
-	module.Init(module.config)
+	// signing nothing would refuse every login, better not to start at all
+	if err := module.Init(module.config); err != nil {
+		module.monitorService.Logger().Error("jwt keys unusable", "error", err)
+		os.Exit(1)
+	}
+
+	module.registerRoutes()
+
+	if module.config.Config().Jwt.KeysDir != "" {
+		module.startReload()
+	}
 
 	return nil
 }
 
 func (module *JwtModule) OnStop() error {
+	if module.stopReload != nil {
+		close(module.stopReload)
+		module.stopReload = nil
+	}
 	return nil
 }
 
@@ -0,0 +1,9 @@
+package jwt
+
+const (
+	jwksPath = ".well-known/jwks.json"
+)
+
+func (module *JwtModule) registerRoutes() {
+	module.app.Get(jwksPath, module.handleJwks)
+}
@@ -1,8 +1,9 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"private-pub-repo/modules/config"
-	"strconv"
 	"time"
 
 	jwtware "github.com/gofiber/contrib/jwt"
@@ -18,7 +19,7 @@ const (
 
 type JwtService interface {
 	jwtCommonMiddleware
-	Init(config config.ConfigService)
+	Init(config config.ConfigService) error
 	GetSecret() string
 	GetHandler() fiber.Handler
 	GetOptionalHandler() fiber.Handler
@@ -27,29 +28,109 @@ type JwtService interface {
 	GenerateAccessTokenTimed(id uuid.UUID, issuer string, now int64, payload map[string]interface{}, expiredAt *time.Time) (string, error)
 }
 
+// failureReason = why the token was refused, a fixed word for the metrics
+func failureReason(ctx *fiber.Ctx, err error) string {
+	switch {
+	case ctx.Get(fiber.HeaderAuthorization) == "":
+		return "missing"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	default:
+		return "invalid"
+	}
+}
+
 func (service *JwtModule) errorHandler(ctx *fiber.Ctx, err error) error {
-	println(err.Error())
+	reason := failureReason(ctx, err)
+	service.monitorService.Logger().InfoContext(ctx.UserContext(), "token refused", "reason", reason, "error", err)
+	service.monitorService.RecordAuthFailure(ctx.UserContext(), reason)
 	return fiber.NewError(401, "Unauthenticated")
 }
 
+// optionalErrorHandler = continue without user, a token that was sent but refused is still a failure
+func (service *JwtModule) optionalErrorHandler(ctx *fiber.Ctx, err error) error {
+	if reason := failureReason(ctx, err); reason != "missing" {
+		service.monitorService.RecordAuthFailure(ctx.UserContext(), reason)
+	}
+	return ctx.Next()
+}
+
+func (service *JwtModule) reloadKeys() error {
+	keys, err := LoadKeys(service.config.Config().Jwt.KeysDir)
+	if err != nil {
+		return err
+	}
+
+	set := keySet(keys)
+	service.keys.Store(&set)
+	return nil
+}
+
+// keyFunc = verification key of the token, by `kid`. tokens without one are signed by the secret
+func (service *JwtModule) keyFunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+
+	if id == "" {
+		if service.secret == "" || token.Method != jwt.SigningMethodHS256 {
+			return nil, errUnknownKey
+		}
+		return []byte(service.secret), nil
+	}
+
+	key := service.keys.Load().find(id)
+	// the algorithm comes from the key, never from the token
+	if key == nil || token.Method != key.method {
+		return nil, errUnknownKey
+	}
+
+	return key.public(), nil
+}
+
+// sign = token of claims, signed by the active key of the algorithm, or the secret
+func (service *JwtModule) sign(claims JwtClaim) (string, error) {
+	if service.algorithm == AlgorithmHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(service.secret))
+	}
+
+	key := service.keys.Load().signingKey(service.algorithm, time.Now())
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.Id
+	return token.SignedString(key.private)
+}
+
 // impl `JwtService` start
 
-func (service *JwtModule) Init(config config.ConfigService) {
-	service.secret = config.Getenv("JWT_SECRET", "")
+func (service *JwtModule) Init(config config.ConfigService) error {
+	service.algorithm = config.Config().Jwt.Algorithm
+	service.secret = config.Config().Jwt.Secret
 	service.handler = jwtware.New(jwtware.Config{
-		SigningKey:   jwtware.SigningKey{Key: []byte(service.secret)},
+		KeyFunc:      service.keyFunc,
 		ErrorHandler: service.errorHandler,
 	})
 	service.optionalHandler = jwtware.New(jwtware.Config{
-		SigningKey:   jwtware.SigningKey{Key: []byte(service.secret)},
-		ErrorHandler: func(c *fiber.Ctx, err error) error { return c.Next() },
+		KeyFunc:      service.keyFunc,
+		ErrorHandler: service.optionalErrorHandler,
 	})
-	if localLifetime, err := strconv.Atoi(config.Getenv("JWT_TOKEN_LIFETIME", "1")); err == nil {
-		service.lifetime = time.Duration(localLifetime) * time.Minute
+	service.lifetime = time.Duration(config.Config().Jwt.TokenLifetime) * time.Minute
+	service.refreshLifetime = time.Duration(config.Config().Jwt.RefreshLifetime) * time.Minute
+
+	if config.Config().Jwt.KeysDir == "" {
+		return nil
 	}
-	if localLifetime, err := strconv.Atoi(config.Getenv("JWT_REFRESH_LIFETIME", "1")); err == nil {
-		service.refreshLifetime = time.Duration(localLifetime) * time.Minute
+
+	if err := service.reloadKeys(); err != nil {
+		return err
 	}
+
+	if service.algorithm != AlgorithmHS256 && service.keys.Load().signingKey(service.algorithm, time.Now()) == nil {
+		return fmt.Errorf("%w of %s in %s, see `jwt:keygen`", ErrNoSigningKey, service.algorithm, config.Config().Jwt.KeysDir)
+	}
+
+	return nil
 }
 
 func (service *JwtModule) GetSecret() string {
@@ -110,9 +191,7 @@ func (service *JwtModule) GenerateToken(id uuid.UUID, issuer string, payload map
 }
 
 func (service *JwtModule) GenerateAccessTokenTimed(id uuid.UUID, issuer string, now int64, payload map[string]interface{}, expiredAt *time.Time) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	// Set claims
-	claims := token.Claims.(JwtClaim)
+	claims := JwtClaim{}
 
 	mergeJwtClaims(payload, claims)
 
@@ -123,7 +202,7 @@ func (service *JwtModule) GenerateAccessTokenTimed(id uuid.UUID, issuer string,
 	claims["iss"] = issuer
 	claims["aud"] = []string{JwtAppAud}
 	// Generate encoded token and send it as response.
-	return token.SignedString([]byte(service.GetSecret()))
+	return service.sign(claims)
 }
 
 func (service *JwtModule) generateAccessToken(id uuid.UUID, issuer string, now int64, payload map[string]interface{}) (string, error) {
@@ -132,10 +211,8 @@ func (service *JwtModule) generateAccessToken(id uuid.UUID, issuer string, now i
 }
 
 func (service *JwtModule) generateRefreshToken(id uuid.UUID, issuer string, now int64, payload map[string]interface{}) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
 	currentTime := time.Now()
-	// Set claims
-	claims := token.Claims.(JwtClaim)
+	claims := JwtClaim{}
 
 	mergeJwtClaims(payload, claims)
 
@@ -146,7 +223,7 @@ func (service *JwtModule) generateRefreshToken(id uuid.UUID, issuer string, now
 	claims["iss"] = issuer
 	claims["aud"] = []string{JwtRefreshAud}
 	// Generate encoded token and send it as response.
-	return token.SignedString([]byte(service.GetSecret()))
+	return service.sign(claims)
 }
 
 // impl `JwtService` end
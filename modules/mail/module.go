@@ -3,7 +3,6 @@ package mail
 import (
 	"private-pub-repo/base"
 	"private-pub-repo/modules/config"
-	"strconv"
 
 	"gopkg.in/gomail.v2"
 
@@ -17,19 +16,13 @@ type MailModule struct {
 }
 
 func NewModule(config config.ConfigService) *MailModule {
-	smtpHost := config.Getenv("SMTP_HOST", "")
-	smtpPort, err := strconv.Atoi(config.Getenv("SMTP_PORT", "587"))
-	if err != nil {
-		smtpPort = 587
-	}
-	smtpUsername := config.Getenv("SMTP_USERNAME", "")
-	smtpPassword := config.Getenv("SMTP_PASSWORD", "")
-	dialer := gomail.NewDialer(smtpHost, smtpPort, smtpUsername, smtpPassword)
+	smtp := config.Config().Smtp
+	dialer := gomail.NewDialer(smtp.Host, smtp.Port, smtp.Username, smtp.Password)
 
 	return &MailModule{
 		dialer:    dialer,
-		fromName:  config.Getenv("SMTP_FROM_NAME", ""),
-		fromEmail: config.Getenv("SMTP_FROM_EMAIL", ""),
+		fromName:  smtp.FromName,
+		fromEmail: smtp.FromEmail,
 	}
 }
 
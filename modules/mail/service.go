@@ -1,11 +1,17 @@
 package mail
 
 import (
+	"context"
+
 	"gopkg.in/gomail.v2"
 )
 
 type MailService interface {
 	Send(to []string, cc []string, subject, message string) error
+	// Ping = connect & authenticate to the smtp server without sending anything
+	Ping(context context.Context) error
+	// Configured = false when no smtp host is set
+	Configured() bool
 }
 
 // impl `MailService` start
@@ -28,4 +34,28 @@ func (service *MailModule) Send(to []string, cc []string, subject, message strin
 
 }
 
+func (service *MailModule) Ping(context context.Context) error {
+	result := make(chan error, 1)
+
+	// gomail has no context support, the dial keeps running in the background after a timeout
+	go func() {
+		sender, err := service.dialer.Dial()
+		if err == nil {
+			err = sender.Close()
+		}
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-context.Done():
+		return context.Err()
+	}
+}
+
+func (service *MailModule) Configured() bool {
+	return service.dialer.Host != ""
+}
+
 // impl `MailService` end
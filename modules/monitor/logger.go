@@ -0,0 +1,197 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"private-pub-repo/modules/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// routes called every few seconds by probes & scrapers, only logged when successful at debug level
+var quietRoutes = map[string]bool{"/": true, "/livez": true, "/readyz": true}
+
+type requestLogKey struct{}
+
+// requestLog = ids of a single request, added to every line logged with its context
+type requestLog struct {
+	id         string
+	mutex      sync.Mutex
+	attributes map[string]slog.Attr
+}
+
+func getRequestLog(ctx context.Context) *requestLog {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(requestLogKey{}).(*requestLog)
+	return fields
+}
+
+func (fields *requestLog) set(attributes []slog.Attr) {
+	fields.mutex.Lock()
+	defer fields.mutex.Unlock()
+
+	for _, attribute := range attributes {
+		fields.attributes[attribute.Key] = attribute
+	}
+}
+
+func (fields *requestLog) list() []slog.Attr {
+	fields.mutex.Lock()
+	defer fields.mutex.Unlock()
+
+	result := []slog.Attr{slog.String("request_id", fields.id)}
+	for _, attribute := range fields.attributes {
+		result = append(result, attribute)
+	}
+	sort.Slice(result[1:], func(i, j int) bool { return result[i+1].Key < result[j+1].Key })
+	return result
+}
+
+// contextHandler adds the request, trace & span ids of the context to the lines
+type contextHandler struct {
+	slog.Handler
+}
+
+func (handler contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if fields := getRequestLog(ctx); fields != nil {
+		record.AddAttrs(fields.list()...)
+	}
+
+	if ctx != nil {
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			record.AddAttrs(
+				slog.String("trace_id", spanContext.TraceID().String()),
+				slog.String("span_id", spanContext.SpanID().String()),
+			)
+		}
+	}
+
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler contextHandler) WithAttrs(attributes []slog.Attr) slog.Handler {
+	return contextHandler{handler.Handler.WithAttrs(attributes)}
+}
+
+func (handler contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{handler.Handler.WithGroup(name)}
+}
+
+// newLogger also becomes the default logger, so the `log` & `slog` package functions write the same lines
+func newLogger(config *config.Config) *slog.Logger {
+	var level slog.Level
+	// validated by the configuration, unknown levels can't happen
+	level.UnmarshalText([]byte(config.Monitor.LogLevel))
+
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if config.Monitor.LogFormat == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+
+	logger := slog.New(contextHandler{handler})
+	slog.SetDefault(logger)
+
+	return logger
+}
+
+// requestId = the id sent by the client or a proxy, a new one when there is none or it looks wrong
+func requestId(c *fiber.Ctx) string {
+	id := c.Get(requestIdHeader)
+
+	if id == "" || len(id) > 128 || strings.ContainsFunc(id, func(r rune) bool { return r < 0x21 || r > 0x7e }) {
+		return uuid.NewString()
+	}
+
+	return utils.CopyString(id)
+}
+
+// requestMiddleware = request id, metrics & access log of every request
+func (module *MonitorModule) requestMiddleware(c *fiber.Ctx) error {
+	start := time.Now()
+
+	fields := &requestLog{id: requestId(c), attributes: map[string]slog.Attr{}}
+	c.SetUserContext(context.WithValue(c.UserContext(), requestLogKey{}, fields))
+	c.Set(requestIdHeader, fields.id)
+
+	err := c.Next()
+	if err != nil {
+		// the error handler runs after the middlewares, it sets the status the client gets
+		if handlerErr := c.App().Config().ErrorHandler(c, err); handlerErr != nil {
+			c.Status(fiber.StatusInternalServerError)
+		}
+	}
+
+	// the method & path point to the request buffer, reused by the next request
+	method := utils.CopyString(c.Method())
+	route := c.Route().Path
+	status := c.Response().StatusCode()
+	duration := time.Since(start)
+
+	// requests without a route end on the last middleware, mounted on /
+	if status == fiber.StatusNotFound && route == "/" {
+		route = "unmatched"
+	}
+
+	module.Service.RecordHttpRequest(c.UserContext(), method, route, status, duration)
+
+	level := slog.LevelInfo
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		level = slog.LevelError
+	case status < fiber.StatusBadRequest && quietRoutes[route]:
+		level = slog.LevelDebug
+	}
+
+	attributes := []slog.Attr{
+		slog.String("method", method),
+		slog.String("path", utils.CopyString(c.Path())),
+		slog.String("route", route),
+		slog.Int("status", status),
+		slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+		slog.String("ip", c.IP()),
+	}
+	if err != nil {
+		attributes = append(attributes, slog.String("error", err.Error()))
+	}
+
+	module.Service.Logger().LogAttrs(c.UserContext(), level, "request", attributes...)
+
+	return nil
+}
+
+// impl `MonitorService` logs start
+
+func (service *monitorServiceImpl) Logger() *slog.Logger {
+	return service.logger
+}
+
+func (service *monitorServiceImpl) AddLogAttributes(context context.Context, attributes map[string]interface{}) {
+	fields := getRequestLog(context)
+	if fields == nil {
+		return
+	}
+
+	result := []slog.Attr{}
+	for key, value := range attributes {
+		result = append(result, slog.Any(key, value))
+	}
+	fields.set(result)
+}
+
+// impl `MonitorService` logs end
@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"private-pub-repo/modules/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// seconds
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// bytes, 1KB to 1GB
+var sizeBuckets = []float64{1 << 10, 16 << 10, 128 << 10, 512 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20, 256 << 20, 1 << 30}
+
+// metrics = the instruments, read by the prometheus registry and pushed to the collector when `OTLP_URL` is set
+type metrics struct {
+	provider          *sdkmetric.MeterProvider
+	registry          *prometheus.Registry
+	meter             metric.Meter
+	httpRequests      metric.Int64Counter
+	httpDuration      metric.Float64Histogram
+	uploads           metric.Int64Counter
+	uploadSize        metric.Int64Histogram
+	downloadRedirects metric.Int64Counter
+	authFailures      metric.Int64Counter
+	storageDuration   metric.Float64Histogram
+	// `package` label of the download redirects
+	packageLabel bool
+}
+
+// otlpMetricsUrl = metrics endpoint of the collector, derived from the traces one when not configured
+func otlpMetricsUrl(config *config.Config) string {
+	if config.Monitor.OtlpMetricsUrl != "" {
+		return config.Monitor.OtlpMetricsUrl
+	}
+
+	if config.Monitor.OtlpUrl == "" {
+		return ""
+	}
+
+	if url, ok := strings.CutSuffix(config.Monitor.OtlpUrl, "/v1/traces"); ok {
+		return url + "/v1/metrics"
+	}
+
+	return config.Monitor.OtlpUrl
+}
+
+func newMetrics(config *config.Config) *metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	prometheusReader, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	options := []sdkmetric.Option{
+		sdkmetric.WithReader(prometheusReader),
+		sdkmetric.WithResource(newResource(config.App.Code)),
+	}
+
+	if url := otlpMetricsUrl(config); url != "" {
+		exporter, err := otlpmetrichttp.New(context.Background(), otlpmetrichttp.WithEndpointURL(url))
+		if err != nil {
+			log.Fatal(err)
+		}
+		options = append(options, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+			exporter,
+			sdkmetric.WithInterval(time.Duration(config.Monitor.OtlpMetricsInterval)*time.Second),
+		)))
+	}
+
+	provider := sdkmetric.NewMeterProvider(options...)
+	meter := provider.Meter(config.App.Code)
+
+	result := &metrics{provider: provider, registry: registry, meter: meter, packageLabel: config.Monitor.MetricsPackageLabel}
+
+	// the instruments are created once with valid names, errors are not possible
+	result.httpRequests, _ = meter.Int64Counter("http.server.requests",
+		metric.WithDescription("handled http requests, by method, route & status"))
+	result.httpDuration, _ = meter.Float64Histogram("http.server.request.duration", metric.WithUnit("s"),
+		metric.WithDescription("duration of the http requests, by method, route & status"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...))
+	result.uploads, _ = meter.Int64Counter("pub.uploads",
+		metric.WithDescription("package version uploads, by result"))
+	result.uploadSize, _ = meter.Int64Histogram("pub.upload.size", metric.WithUnit("By"),
+		metric.WithDescription("size of the uploaded package archives, by result"),
+		metric.WithExplicitBucketBoundaries(sizeBuckets...))
+	result.downloadRedirects, _ = meter.Int64Counter("pub.download.redirects",
+		metric.WithDescription("archive downloads redirected to the storage, by package when enabled"))
+	result.authFailures, _ = meter.Int64Counter("auth.token.failures",
+		metric.WithDescription("refused tokens, by reason"))
+	result.storageDuration, _ = meter.Float64Histogram("storage.operation.duration", metric.WithUnit("s"),
+		metric.WithDescription("duration of the storage calls, by operation & result"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...))
+
+	return result
+}
+
+func resultAttribute(err error) attribute.KeyValue {
+	if err != nil {
+		return attribute.String("result", "failure")
+	}
+	return attribute.String("result", "success")
+}
+
+// impl `MonitorService` metrics start
+
+func (service *monitorServiceImpl) RecordHttpRequest(context context.Context, method string, route string, status int, duration time.Duration) {
+	attributes := metric.WithAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.String("http.response.status_code", strconv.Itoa(status)),
+	)
+	service.metrics.httpRequests.Add(context, 1, attributes)
+	service.metrics.httpDuration.Record(context, duration.Seconds(), attributes)
+}
+
+func (service *monitorServiceImpl) RecordUpload(context context.Context, size int64, err error) {
+	attributes := metric.WithAttributes(resultAttribute(err))
+	service.metrics.uploads.Add(context, 1, attributes)
+	service.metrics.uploadSize.Record(context, size, attributes)
+}
+
+func (service *monitorServiceImpl) RecordDownloadRedirect(context context.Context, packageName string) {
+	if !service.metrics.packageLabel {
+		service.metrics.downloadRedirects.Add(context, 1)
+		return
+	}
+	service.metrics.downloadRedirects.Add(context, 1, metric.WithAttributes(attribute.String("package", packageName)))
+}
+
+func (service *monitorServiceImpl) RecordAuthFailure(context context.Context, reason string) {
+	service.metrics.authFailures.Add(context, 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+func (service *monitorServiceImpl) RecordStorageOperation(context context.Context, operation string, start time.Time, err error) {
+	service.metrics.storageDuration.Record(context, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("operation", operation),
+		resultAttribute(err),
+	))
+}
+
+func (service *monitorServiceImpl) ObserveDbPools(stats func() map[string]sql.DBStats) {
+	meter := service.metrics.meter
+
+	open, _ := meter.Int64ObservableGauge("db.pool.connections.open",
+		metric.WithDescription("open connections, in use & idle, by pool"))
+	inUse, _ := meter.Int64ObservableGauge("db.pool.connections.in_use",
+		metric.WithDescription("connections in use, by pool"))
+	idle, _ := meter.Int64ObservableGauge("db.pool.connections.idle",
+		metric.WithDescription("idle connections, by pool"))
+	maxOpen, _ := meter.Int64ObservableGauge("db.pool.connections.max",
+		metric.WithDescription("max open connections, 0 for unlimited, by pool"))
+	waits, _ := meter.Int64ObservableCounter("db.pool.waits",
+		metric.WithDescription("waits for a free connection, by pool"))
+	waitDuration, _ := meter.Float64ObservableCounter("db.pool.wait.duration", metric.WithUnit("s"),
+		metric.WithDescription("time spent waiting for a free connection, by pool"))
+
+	_, err := meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		for pool, stat := range stats() {
+			attributes := metric.WithAttributes(attribute.String("pool", pool))
+			observer.ObserveInt64(open, int64(stat.OpenConnections), attributes)
+			observer.ObserveInt64(inUse, int64(stat.InUse), attributes)
+			observer.ObserveInt64(idle, int64(stat.Idle), attributes)
+			observer.ObserveInt64(maxOpen, int64(stat.MaxOpenConnections), attributes)
+			observer.ObserveInt64(waits, stat.WaitCount, attributes)
+			observer.ObserveFloat64(waitDuration, stat.WaitDuration.Seconds(), attributes)
+		}
+		return nil
+	}, open, inUse, idle, maxOpen, waits, waitDuration)
+
+	if err != nil {
+		service.logger.Error("db pool metrics error", "error", err)
+	}
+}
+
+// impl `MonitorService` metrics end
@@ -1,11 +1,17 @@
 package monitor
 
 import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
 	"private-pub-repo/base"
 	"private-pub-repo/modules/app"
 	"private-pub-repo/modules/config"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/fx"
 )
@@ -15,6 +21,8 @@ type MonitorModule struct {
 	app     *fiber.App
 	config  config.ConfigService
 	tp      *sdktrace.TracerProvider
+	// nil when the metrics are disabled
+	metricsServer *http.Server
 }
 
 func NewModule(service MonitorService, app *fiber.App, config config.ConfigService) *MonitorModule {
@@ -26,7 +34,7 @@ func fxRegister(lifeCycle fx.Lifecycle, module *MonitorModule) {
 }
 
 func SetupModule(app *app.AppModule, config *config.ConfigModule) *MonitorModule {
-	return NewModule(provideMonitorService(), app.App, config)
+	return NewModule(provideMonitorService(config), app.App, config)
 }
 
 var FxModule = fx.Module("Monitor", fx.Provide(NewModule), fx.Provide(provideMonitorService), fx.Invoke(fxRegister))
@@ -34,13 +42,53 @@ var FxModule = fx.Module("Monitor", fx.Provide(NewModule), fx.Provide(provideMon
 // implements `BaseModule` of `base/module.go` start
 
 func (module *MonitorModule) OnStart() error {
+	// the trace middleware goes first, the access log lines need its span
 	module.initOpentelemetry()
+
+	module.app.Use(module.requestMiddleware)
+
 	return nil
 }
 
 func (module *MonitorModule) OnStop() error {
+	if module.metricsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		module.metricsServer.Shutdown(ctx)
+	}
+
+	// pushes the last metrics to the collector
+	if err := module.Service.getMetrics().provider.Shutdown(context.Background()); err != nil {
+		module.Service.Logger().Error("meter provider shutdown error", "error", err)
+	}
+
 	module.destroyOpentelemetry()
 	return nil
 }
 
 // implements `BaseModule` of `base/module.go` end
+
+// ServeMetrics serves `/metrics` on its own listener when enabled, by the commands serving the api only. the address
+// is bound before returning, a port in use stops the start
+func (module *MonitorModule) ServeMetrics() error {
+	if !module.config.Config().Monitor.MetricsEnabled {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", module.config.Config().Monitor.MetricsListen)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/"+metricsPath, promhttp.HandlerFor(module.Service.getMetrics().registry, promhttp.HandlerOpts{}))
+	module.metricsServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := module.metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			module.Service.Logger().Error("metrics server error", "error", err)
+		}
+	}()
+
+	return nil
+}
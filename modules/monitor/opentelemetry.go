@@ -15,7 +15,7 @@ import (
 )
 
 func (module *MonitorModule) initOpentelemetry() {
-	url := module.config.Getenv("OTLP_URL", "")
+	url := module.config.Config().Monitor.OtlpUrl
 	if url == "" {
 		return
 	}
@@ -25,13 +25,13 @@ func (module *MonitorModule) initOpentelemetry() {
 }
 
 func (module *MonitorModule) destroyOpentelemetry() {
-	url := module.config.Getenv("OTLP_URL", "")
+	url := module.config.Config().Monitor.OtlpUrl
 	if url == "" {
 		return
 	}
 
 	if err := module.tp.Shutdown(context.Background()); err != nil {
-		log.Printf("Error shutting down tracer provider: %v", err)
+		module.Service.Logger().Error("tracer provider shutdown error", "error", err)
 	}
 
 	module.app.Use(otelfiber.Middleware())
@@ -46,17 +46,21 @@ func (module *MonitorModule) initTracer(url string) *sdktrace.TracerProvider {
 	if err != nil {
 		log.Fatal(err)
 	}
-	appCode := module.config.Getenv("APP_CODE", "App")
+	appCode := module.config.Config().App.Code
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String(appCode),
-			)),
+		sdktrace.WithResource(newResource(appCode)),
 	)
 	module.Service.setTracer(tp.Tracer(appCode))
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 	return tp
 }
+
+// newResource = the service of the traces & metrics
+func newResource(appCode string) *resource.Resource {
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(appCode),
+	)
+}
@@ -0,0 +1,5 @@
+package monitor
+
+const (
+	metricsPath = "metrics"
+)
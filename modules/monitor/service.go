@@ -2,7 +2,11 @@ package monitor
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"log/slog"
+	"private-pub-repo/modules/config"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -14,15 +18,33 @@ type MonitorTracer = trace.Tracer
 type MonitorService interface {
 	StartTraceSpan(context context.Context, title string, attributes map[string]interface{}) (context.Context, MonitorSpan)
 	SetCurrentSpanAttributes(context context.Context, attributes map[string]interface{})
+	// RecordHttpRequest = route is the registered path, e.g. v1/pub/api/packages/:package
+	RecordHttpRequest(context context.Context, method string, route string, status int, duration time.Duration)
+	// RecordUpload = size in bytes of the uploaded archive, err is the result of the upload
+	RecordUpload(context context.Context, size int64, err error)
+	RecordDownloadRedirect(context context.Context, packageName string)
+	// RecordAuthFailure = a refused token, reason is a fixed word (missing, invalid, expired, revoked, ...)
+	RecordAuthFailure(context context.Context, reason string)
+	RecordStorageOperation(context context.Context, operation string, start time.Time, err error)
+	// ObserveDbPools = stats of the connection pools by name, read on every collection
+	ObserveDbPools(stats func() map[string]sql.DBStats)
+	// Logger = lines logged with the context of a request carry its request, trace & span ids
+	Logger() *slog.Logger
+	// AddLogAttributes = added to the following lines of the request, e.g. user_id, token_id
+	AddLogAttributes(context context.Context, attributes map[string]interface{})
 	setTracer(tracer MonitorTracer)
+	getMetrics() *metrics
 }
 
 type monitorServiceImpl struct {
-	tracer MonitorTracer
+	tracer  MonitorTracer
+	metrics *metrics
+	logger  *slog.Logger
 }
 
-func provideMonitorService() MonitorService {
-	return &monitorServiceImpl{}
+func provideMonitorService(config config.ConfigService) MonitorService {
+	logger := newLogger(config.Config())
+	return &monitorServiceImpl{metrics: newMetrics(config.Config()), logger: logger}
 }
 
 func mapToAttributes(attributes map[string]interface{}) []attribute.KeyValue {
@@ -60,3 +82,7 @@ func (service *monitorServiceImpl) SetCurrentSpanAttributes(context context.Cont
 func (service *monitorServiceImpl) setTracer(tracer MonitorTracer) {
 	service.tracer = tracer
 }
+
+func (service *monitorServiceImpl) getMetrics() *metrics {
+	return service.metrics
+}
@@ -0,0 +1,350 @@
+// Package oidc verifies the id tokens of openid connect issuers, found by discovery,
+// for trusted publishing & the single sign-on of the users
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// discovery documents & key sets are small, anything bigger isn't one
+	maxDocumentSize = 1 << 20
+	// unknown key ids refetch the keys of the issuer, at most this often
+	minRefetchInterval = time.Minute
+	fetchTimeout       = 10 * time.Second
+	clockLeeway        = 30 * time.Second
+)
+
+var (
+	ErrInsecureUrl = errors.New("oidc urls must be https")
+	errUnknownKid  = errors.New("unknown oidc key id")
+	// asymmetric only, the secret of a symmetric one would have to be shared with us
+	validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+)
+
+// Discovery = the part of the openid configuration used here
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	JwksUri               string `json:"jwks_uri"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// TokenResponse = tokens of the token endpoint, for an authorization code
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IdToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+}
+
+type tokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type issuerKeys struct {
+	discovery *Discovery
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// Verifier = verify the id tokens of issuers, their openid configuration & keys are cached
+type Verifier struct {
+	client    *http.Client
+	refresh   time.Duration
+	allowHttp bool
+	mutex     sync.Mutex
+	issuers   map[string]*issuerKeys
+}
+
+func NewVerifier(refresh time.Duration, allowHttp bool) *Verifier {
+	return &Verifier{
+		client:    &http.Client{Timeout: fetchTimeout},
+		refresh:   refresh,
+		allowHttp: allowHttp,
+		issuers:   map[string]*issuerKeys{},
+	}
+}
+
+// CheckUrl refuses the urls that aren't https, http too when allowed
+func (verifier *Verifier) CheckUrl(rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Scheme != "https" && !(verifier.allowHttp && parsed.Scheme == "http") {
+		return ErrInsecureUrl
+	}
+
+	return nil
+}
+
+func (verifier *Verifier) getJson(ctx context.Context, rawUrl string, value interface{}) error {
+	if err := verifier.CheckUrl(rawUrl); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := verifier.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", rawUrl, response.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(response.Body, maxDocumentSize)).Decode(value)
+}
+
+// fetch = the openid configuration & the signing keys of issuer, cached
+func (verifier *Verifier) fetch(ctx context.Context, issuer string) (*issuerKeys, error) {
+	var config Discovery
+	if err := verifier.getJson(ctx, strings.TrimSuffix(issuer, "/")+discoveryPath, &config); err != nil {
+		return nil, err
+	}
+
+	if config.Issuer != issuer {
+		return nil, fmt.Errorf("openid configuration of %s is for issuer %s", issuer, config.Issuer)
+	}
+
+	var set jwkSet
+	if err := verifier.getJson(ctx, config.JwksUri, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		// keys of unsupported types are skipped, the others stay usable
+		if publicKey, err := parseJwk(key); err == nil {
+			keys[key.Kid] = publicKey
+		}
+	}
+
+	fetched := &issuerKeys{discovery: &config, keys: keys, fetchedAt: time.Now()}
+	verifier.mutex.Lock()
+	verifier.issuers[issuer] = fetched
+	verifier.mutex.Unlock()
+
+	return fetched, nil
+}
+
+func (verifier *Verifier) cached(issuer string) *issuerKeys {
+	verifier.mutex.Lock()
+	defer verifier.mutex.Unlock()
+	return verifier.issuers[issuer]
+}
+
+// Discover = the openid configuration of issuer, from the cache unless it is stale
+func (verifier *Verifier) Discover(ctx context.Context, issuer string) (*Discovery, error) {
+	if cached := verifier.cached(issuer); cached != nil && time.Since(cached.fetchedAt) < verifier.refresh {
+		return cached.discovery, nil
+	}
+
+	fetched, err := verifier.fetch(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetched.discovery, nil
+}
+
+// key = the key kid of issuer, from the cache unless it is stale or doesn't have kid
+func (verifier *Verifier) key(ctx context.Context, issuer string, kid string) (crypto.PublicKey, error) {
+	if cached := verifier.cached(issuer); cached != nil {
+		age := time.Since(cached.fetchedAt)
+		if key, ok := cached.keys[kid]; ok && age < verifier.refresh {
+			return key, nil
+		}
+		if age < minRefetchInterval {
+			return nil, errUnknownKid
+		}
+	}
+
+	fetched, err := verifier.fetch(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := fetched.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, errUnknownKid
+}
+
+// Verify = claims of the id token signed by issuer, not expired
+func (verifier *Verifier) Verify(ctx context.Context, token string, issuer string) (jwtlib.MapClaims, error) {
+	claims := jwtlib.MapClaims{}
+
+	_, err := jwtlib.ParseWithClaims(token, claims, func(parsed *jwtlib.Token) (interface{}, error) {
+		kid, _ := parsed.Header["kid"].(string)
+		return verifier.key(ctx, issuer, kid)
+	},
+		jwtlib.WithValidMethods(validMethods),
+		jwtlib.WithIssuer(issuer),
+		jwtlib.WithExpirationRequired(),
+		jwtlib.WithIssuedAt(),
+		jwtlib.WithLeeway(clockLeeway),
+	)
+
+	return claims, err
+}
+
+// Exchange = tokens of the token endpoint of issuer for an authorization code, form has the code & its parameters.
+// the client authenticates with basic auth, or only sends its id when it has no secret
+func (verifier *Verifier) Exchange(ctx context.Context, issuer string, clientId string, clientSecret string, form url.Values) (*TokenResponse, error) {
+	config, err := verifier.Discover(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifier.CheckUrl(config.TokenEndpoint); err != nil {
+		return nil, err
+	}
+
+	form.Set("grant_type", "authorization_code")
+	if clientSecret == "" {
+		form.Set("client_id", clientId)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(clientId), url.QueryEscape(clientSecret))
+	}
+
+	response, err := verifier.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body := io.LimitReader(response.Body, maxDocumentSize)
+	if response.StatusCode != http.StatusOK {
+		var failure tokenError
+		json.NewDecoder(body).Decode(&failure)
+		return nil, fmt.Errorf("token endpoint returned status %d: %s %s", response.StatusCode, failure.Error, failure.ErrorDescription)
+	}
+
+	var tokens TokenResponse
+	if err := json.NewDecoder(body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+
+	if tokens.IdToken == "" {
+		return nil, errors.New("token endpoint returned no id token")
+	}
+
+	return &tokens, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func parseJwk(key jwk) (crypto.PublicKey, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < 2048 || !e.IsInt64() {
+			return nil, fmt.Errorf("unsupported rsa key %s", key.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", key.Crv)
+		}
+		x, err := decodeBigInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid ec key %s", key.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil {
+			return nil, err
+		}
+		if key.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported okp key %s", key.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", key.Kty)
+}
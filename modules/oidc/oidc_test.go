@@ -0,0 +1,244 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+// testIssuer = stand-in issuer serving its openid configuration & the public keys of its signing keys
+type testIssuer struct {
+	server       *httptest.Server
+	mutex        sync.Mutex
+	keys         map[string]ed25519.PrivateKey
+	jwksRequests int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	issuer := &testIssuer{keys: map[string]ed25519.PrivateKey{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{Issuer: issuer.server.URL, JwksUri: issuer.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mutex.Lock()
+		defer issuer.mutex.Unlock()
+		issuer.jwksRequests++
+
+		set := jwkSet{}
+		for kid, key := range issuer.keys {
+			x := base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+			set.Keys = append(set.Keys, jwk{Kty: "OKP", Crv: "Ed25519", Kid: kid, Use: "sig", X: x})
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	issuer.server = httptest.NewTLSServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+// verifier = a verifier trusting the certificate of the issuer
+func (issuer *testIssuer) verifier() *Verifier {
+	verifier := NewVerifier(time.Hour, false)
+	verifier.client = issuer.server.Client()
+	return verifier
+}
+
+func (issuer *testIssuer) addKey(t *testing.T, kid string) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer.mutex.Lock()
+	issuer.keys[kid] = key
+	issuer.mutex.Unlock()
+}
+
+func (issuer *testIssuer) requests() int {
+	issuer.mutex.Lock()
+	defer issuer.mutex.Unlock()
+	return issuer.jwksRequests
+}
+
+// claims = valid claims of the issuer, lasting an hour
+func (issuer *testIssuer) claims() jwtlib.MapClaims {
+	now := time.Now()
+	return jwtlib.MapClaims{
+		"iss": issuer.server.URL,
+		"sub": "repo:acme/app:ref:refs/heads/main",
+		"aud": "pubserver",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+func (issuer *testIssuer) sign(t *testing.T, kid string, claims jwtlib.MapClaims) string {
+	t.Helper()
+
+	issuer.mutex.Lock()
+	key := issuer.keys[kid]
+	issuer.mutex.Unlock()
+
+	token := jwtlib.NewWithClaims(jwtlib.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key1")
+	verifier := issuer.verifier()
+	ctx := context.Background()
+
+	t.Run("valid token", func(t *testing.T) {
+		claims, err := verifier.Verify(ctx, issuer.sign(t, "key1", issuer.claims()), issuer.server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if subject, _ := claims.GetSubject(); subject != "repo:acme/app:ref:refs/heads/main" {
+			t.Fatalf("unexpected subject %q", subject)
+		}
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		claims := issuer.claims()
+		claims["iss"] = "https://issuer.example.invalid"
+		_, err := verifier.Verify(ctx, issuer.sign(t, "key1", claims), issuer.server.URL)
+		if !errors.Is(err, jwtlib.ErrTokenInvalidIssuer) {
+			t.Fatalf("expected an invalid issuer, got %v", err)
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		claims := issuer.claims()
+		claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+		claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		_, err := verifier.Verify(ctx, issuer.sign(t, "key1", claims), issuer.server.URL)
+		if !errors.Is(err, jwtlib.ErrTokenExpired) {
+			t.Fatalf("expected an expired token, got %v", err)
+		}
+	})
+
+	t.Run("token without expiry", func(t *testing.T) {
+		claims := issuer.claims()
+		delete(claims, "exp")
+		_, err := verifier.Verify(ctx, issuer.sign(t, "key1", claims), issuer.server.URL)
+		if !errors.Is(err, jwtlib.ErrTokenRequiredClaimMissing) {
+			t.Fatalf("expected a missing expiry, got %v", err)
+		}
+	})
+
+	t.Run("alg none", func(t *testing.T) {
+		token := jwtlib.NewWithClaims(jwtlib.SigningMethodNone, issuer.claims())
+		token.Header["kid"] = "key1"
+		signed, err := token.SignedString(jwtlib.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.Verify(ctx, signed, issuer.server.URL); !errors.Is(err, jwtlib.ErrTokenSignatureInvalid) {
+			t.Fatalf("expected alg none to be refused, got %v", err)
+		}
+	})
+
+	t.Run("hs256 signed with the public key", func(t *testing.T) {
+		token := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, issuer.claims())
+		token.Header["kid"] = "key1"
+		issuer.mutex.Lock()
+		publicKey := issuer.keys["key1"].Public().(ed25519.PublicKey)
+		issuer.mutex.Unlock()
+		signed, err := token.SignedString([]byte(publicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.Verify(ctx, signed, issuer.server.URL); !errors.Is(err, jwtlib.ErrTokenSignatureInvalid) {
+			t.Fatalf("expected hs256 to be refused, got %v", err)
+		}
+	})
+
+	t.Run("key of another issuer", func(t *testing.T) {
+		other := newTestIssuer(t)
+		other.addKey(t, "key1")
+		_, err := verifier.Verify(ctx, other.sign(t, "key1", issuer.claims()), issuer.server.URL)
+		if !errors.Is(err, jwtlib.ErrTokenSignatureInvalid) {
+			t.Fatalf("expected an invalid signature, got %v", err)
+		}
+	})
+
+	if requests := issuer.requests(); requests != 1 {
+		t.Fatalf("expected the keys to be fetched once, got %d", requests)
+	}
+}
+
+// TestVerifyUnknownKid = a rotated key is fetched, at most once per minRefetchInterval
+func TestVerifyUnknownKid(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key1")
+	verifier := issuer.verifier()
+	ctx := context.Background()
+
+	if _, err := verifier.Verify(ctx, issuer.sign(t, "key1", issuer.claims()), issuer.server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer.addKey(t, "key2")
+	rotated := issuer.sign(t, "key2", issuer.claims())
+
+	// the keys were just fetched, the unknown key id doesn't fetch them again
+	if _, err := verifier.Verify(ctx, rotated, issuer.server.URL); !errors.Is(err, errUnknownKid) {
+		t.Fatalf("expected an unknown key id, got %v", err)
+	}
+	if requests := issuer.requests(); requests != 1 {
+		t.Fatalf("expected no refetch within %s, got %d fetches", minRefetchInterval, requests)
+	}
+
+	// a minute later
+	verifier.mutex.Lock()
+	verifier.issuers[issuer.server.URL].fetchedAt = time.Now().Add(-minRefetchInterval)
+	verifier.mutex.Unlock()
+
+	if _, err := verifier.Verify(ctx, rotated, issuer.server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if requests := issuer.requests(); requests != 2 {
+		t.Fatalf("expected the unknown key id to refetch the keys, got %d fetches", requests)
+	}
+
+	// refetched, a key published since then waits for the next interval
+	issuer.addKey(t, "key3")
+	unpublished := issuer.sign(t, "key3", issuer.claims())
+	if _, err := verifier.Verify(ctx, unpublished, issuer.server.URL); !errors.Is(err, errUnknownKid) {
+		t.Fatalf("expected an unknown key id, got %v", err)
+	}
+	if requests := issuer.requests(); requests != 2 {
+		t.Fatalf("expected no refetch within %s, got %d fetches", minRefetchInterval, requests)
+	}
+}
+
+func TestCheckUrl(t *testing.T) {
+	if err := NewVerifier(time.Hour, false).CheckUrl("http://issuer.example.invalid"); !errors.Is(err, ErrInsecureUrl) {
+		t.Fatalf("expected http to be refused, got %v", err)
+	}
+	if err := NewVerifier(time.Hour, true).CheckUrl("http://issuer.example.invalid"); err != nil {
+		t.Fatal(err)
+	}
+}
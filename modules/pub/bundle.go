@@ -0,0 +1,614 @@
+package pub
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"private-pub-repo/modules/pub/pubdto"
+	"private-pub-repo/modules/pub/pubmodel"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// bundleVersion is a version the bundle can contain, found locally or on the upstream repository
+type bundleVersion struct {
+	packageName   string
+	version       string
+	semver        *semver.Version
+	pubspec       map[string]interface{}
+	source        string
+	archiveUrl    string
+	archiveSha256 string
+}
+
+type bundleRequirement struct {
+	packageName string
+	constraint  string
+	requiredBy  string
+	// lockfile entries are already the full dependency graph
+	followDependencies bool
+}
+
+type bundleResolver struct {
+	service     *pubServiceImpl
+	upstreamUrl string
+	versions    map[string][]bundleVersion
+	errors      map[string]error
+}
+
+func (service *pubServiceImpl) ExportBundle(context context.Context, writer io.Writer, options *pubdto.BundleExportDTO) (*pubdto.BundleManifestDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.ExportBundle", map[string]interface{}{})
+	defer span.End()
+
+	requirements, roots, err := bundleRequirements(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := bundleResolver{
+		service:     service,
+		upstreamUrl: strings.TrimRight(service.upstreamUrl(), "/"),
+		versions:    map[string][]bundleVersion{},
+		errors:      map[string]error{},
+	}
+
+	selected, unresolved := resolver.resolve(spanContext, requirements)
+
+	manifest := pubdto.BundleManifestDTO{
+		FormatVersion: pubdto.BundleFormatVersion,
+		CreatedAt:     time.Now(),
+		Roots:         roots,
+		Packages:      []pubdto.BundlePackageDTO{},
+		Unresolved:    unresolved,
+	}
+
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, version := range selected {
+		bundlePackage, err := service.writeBundleArchive(spanContext, tarWriter, &version, manifest.CreatedAt)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", version.packageName, version.version, err)
+		}
+		manifest.Packages = append(manifest.Packages, *bundlePackage)
+	}
+
+	// the manifest comes last, it carries the checksums of the archives written above
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    pubdto.BundleManifestPath,
+		Mode:    0644,
+		Size:    int64(len(manifestJson)),
+		ModTime: manifest.CreatedAt,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tarWriter.Write(manifestJson); err != nil {
+		return nil, err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// ImportBundle unpacks the bundle and imports its archives like a directory import,
+// so it reports per version and can be run again after an interruption
+func (service *pubServiceImpl) ImportBundle(context context.Context, reader io.Reader, dryRun bool, userId *uuid.UUID) (*pubdto.ImportReportDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.ImportBundle", map[string]interface{}{})
+	defer span.End()
+
+	directory, err := os.MkdirTemp("", "pub-bundle-*")
+
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(directory)
+
+	// an empty bundle still needs the directory to import from
+	if err := os.MkdirAll(filepath.Join(directory, pubdto.BundleArchivesDirectory), 0755); err != nil {
+		return nil, err
+	}
+
+	manifest, checksums, err := extractBundle(reader, directory, service.archiveSizeLimit())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.FormatVersion != pubdto.BundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", manifest.FormatVersion)
+	}
+
+	listed := make(map[string]bool, len(manifest.Packages))
+	for _, bundlePackage := range manifest.Packages {
+		listed[bundlePackage.Path] = true
+		checksum, ok := checksums[bundlePackage.Path]
+
+		if !ok {
+			return nil, fmt.Errorf("bundle is missing %s", bundlePackage.Path)
+		}
+
+		if !strings.EqualFold(checksum, bundlePackage.ArchiveSha256) {
+			return nil, fmt.Errorf("archive sha256 mismatch for %s", bundlePackage.Path)
+		}
+	}
+
+	// the whole directory is imported, an archive missing from the manifest would be published unchecked
+	for archivePath := range checksums {
+		if !listed[archivePath] {
+			return nil, fmt.Errorf("bundle archive %s is not in the manifest", archivePath)
+		}
+	}
+
+	return service.Import(spanContext, &pubdto.ImportDTO{
+		Source: pubdto.ImportSourceDirectory,
+		Path:   filepath.Join(directory, pubdto.BundleArchivesDirectory),
+		DryRun: dryRun,
+	}, userId)
+}
+
+func (service *pubServiceImpl) writeBundleArchive(context context.Context, tarWriter *tar.Writer, version *bundleVersion, modTime time.Time) (*pubdto.BundlePackageDTO, error) {
+	var reader io.ReadCloser
+	var err error
+
+	if version.source == pubdto.BundleSourcePrivate {
+		reader, err = service.storage.Download(context, fmt.Sprintf(filePathFormat, version.packageName, version.version))
+	} else {
+		var response *http.Response
+		response, err = service.hostedRequest(context, version.archiveUrl, "", "")
+
+		if response != nil {
+			reader = response.Body
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// tar headers need the size upfront, and upstream checksums are verified before anything is written
+	path, archiveSha256, err := writeTempArchive(reader, service.archiveSizeLimit())
+
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	if version.archiveSha256 != "" && !strings.EqualFold(archiveSha256, version.archiveSha256) {
+		return nil, fmt.Errorf("archive sha256 mismatch")
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return nil, err
+	}
+
+	bundlePackage := pubdto.BundlePackageDTO{
+		Name:          version.packageName,
+		Version:       version.version,
+		Source:        version.source,
+		Path:          fmt.Sprintf(pubdto.BundleArchivePathFormat, version.packageName, version.version),
+		ArchiveSha256: archiveSha256,
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    bundlePackage.Path,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: modTime,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(tarWriter, file); err != nil {
+		return nil, err
+	}
+
+	return &bundlePackage, nil
+}
+
+// extractBundle writes the archives of the bundle into directory, returning the manifest and the sha256 of every archive
+func extractBundle(reader io.Reader, directory string, archiveLimit int64) (*pubdto.BundleManifestDTO, map[string]string, error) {
+	gzipReader, err := gzip.NewReader(reader)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	checksums := map[string]string{}
+	var manifest *pubdto.BundleManifestDTO
+
+	for {
+		header, err := tarReader.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if header.Name == pubdto.BundleManifestPath {
+			manifest = &pubdto.BundleManifestDTO{}
+
+			if err := json.NewDecoder(tarReader).Decode(manifest); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(header.Name, pubdto.BundleArchivesDirectory) {
+			continue
+		}
+
+		// archives are written where the manifest lists them, anything else is refused by `ImportBundle`
+		if path.Clean(header.Name) != header.Name || !strings.HasSuffix(header.Name, archiveSuffix) {
+			return nil, nil, fmt.Errorf("invalid bundle archive path %s", header.Name)
+		}
+		name := filepath.FromSlash(header.Name)
+
+		checksum, err := extractBundleArchive(tarReader, filepath.Join(directory, name), archiveLimit)
+
+		if err != nil {
+			return nil, nil, err
+		}
+		checksums[header.Name] = checksum
+	}
+
+	if manifest == nil {
+		return nil, nil, errors.New("bundle has no manifest.json")
+	}
+
+	return manifest, checksums, nil
+}
+
+func extractBundleArchive(reader io.Reader, path string, limit int64) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	temporaryPath, checksum, err := writeTempArchive(reader, limit)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(temporaryPath, path); err != nil {
+		os.Remove(temporaryPath)
+		return "", err
+	}
+
+	return checksum, nil
+}
+
+// bundleRequirements turns the root packages and the lockfile into requirements, plus their description for the manifest
+func bundleRequirements(options *pubdto.BundleExportDTO) ([]bundleRequirement, []string, error) {
+	requirements := []bundleRequirement{}
+	roots := []string{}
+
+	for _, root := range options.Packages {
+		packageName, constraint, _ := strings.Cut(root, ":")
+		packageName = strings.TrimSpace(packageName)
+
+		if packageName == "" {
+			return nil, nil, fmt.Errorf("invalid package %s", root)
+		}
+
+		if _, err := parseBundleConstraint(constraint); err != nil {
+			return nil, nil, fmt.Errorf("invalid constraint for %s: %w", packageName, err)
+		}
+
+		requirements = append(requirements, bundleRequirement{
+			packageName:        packageName,
+			constraint:         strings.TrimSpace(constraint),
+			followDependencies: true,
+		})
+		roots = append(roots, root)
+	}
+
+	if len(options.Lockfile) > 0 {
+		lockfile := struct {
+			Packages map[string]struct {
+				Source  string `yaml:"source"`
+				Version string `yaml:"version"`
+			} `yaml:"packages"`
+		}{}
+
+		if err := yaml.Unmarshal(options.Lockfile, &lockfile); err != nil {
+			return nil, nil, fmt.Errorf("invalid pubspec.lock: %w", err)
+		}
+
+		packageNames := make([]string, 0, len(lockfile.Packages))
+
+		for packageName := range lockfile.Packages {
+			packageNames = append(packageNames, packageName)
+		}
+		sort.Strings(packageNames)
+
+		for _, packageName := range packageNames {
+			lockedPackage := lockfile.Packages[packageName]
+
+			// sdk, path and git dependencies are not served by a pub repository
+			if lockedPackage.Source != "hosted" {
+				continue
+			}
+
+			requirements = append(requirements, bundleRequirement{
+				packageName: packageName,
+				constraint:  lockedPackage.Version,
+				requiredBy:  "pubspec.lock",
+			})
+			roots = append(roots, packageName+":"+lockedPackage.Version)
+		}
+	}
+
+	if len(requirements) == 0 {
+		return nil, nil, errors.New("no packages to export")
+	}
+
+	return requirements, roots, nil
+}
+
+func parseBundleConstraint(constraint string) (*semver.Constraints, error) {
+	constraint = strings.TrimSpace(constraint)
+
+	if constraint == "" || constraint == "any" {
+		return nil, nil
+	}
+
+	return semver.NewConstraint(constraint)
+}
+
+// resolve picks the newest matching version for every requirement, following hosted dependencies.
+// This is not a version solver: different constraints on the same package may bring in several of its versions
+func (resolver *bundleResolver) resolve(context context.Context, requirements []bundleRequirement) ([]bundleVersion, []pubdto.BundleUnresolvedDTO) {
+	selected := []bundleVersion{}
+	seen := map[string]bool{}
+	unresolved := []pubdto.BundleUnresolvedDTO{}
+
+	for len(requirements) > 0 {
+		requirement := requirements[0]
+		requirements = requirements[1:]
+
+		version, err := resolver.pick(context, &requirement)
+
+		if err != nil {
+			unresolved = append(unresolved, pubdto.BundleUnresolvedDTO{
+				Name:       requirement.packageName,
+				Constraint: requirement.constraint,
+				RequiredBy: requirement.requiredBy,
+				Error:      err.Error(),
+			})
+			continue
+		}
+
+		key := version.packageName + "@" + version.version
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		selected = append(selected, *version)
+
+		if !requirement.followDependencies {
+			continue
+		}
+
+		dependencies := hostedDependencies(version.pubspec)
+		dependencyNames := make([]string, 0, len(dependencies))
+
+		for dependencyName := range dependencies {
+			dependencyNames = append(dependencyNames, dependencyName)
+		}
+		sort.Strings(dependencyNames)
+
+		for _, dependencyName := range dependencyNames {
+			requirements = append(requirements, bundleRequirement{
+				packageName:        dependencyName,
+				constraint:         dependencies[dependencyName],
+				requiredBy:         key,
+				followDependencies: true,
+			})
+		}
+	}
+
+	return selected, unresolved
+}
+
+func (resolver *bundleResolver) pick(context context.Context, requirement *bundleRequirement) (*bundleVersion, error) {
+	versions, err := resolver.list(context, requirement.packageName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	constraint, err := parseBundleConstraint(requirement.constraint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var best *bundleVersion
+
+	for i := range versions {
+		version := &versions[i]
+
+		if constraint == nil && version.semver.Prerelease() != "" {
+			continue
+		}
+
+		if constraint != nil && !constraint.Check(version.semver) {
+			continue
+		}
+
+		if best == nil || version.semver.GreaterThan(best.semver) {
+			best = version
+		}
+	}
+
+	if best == nil {
+		return nil, errors.New("no version matches")
+	}
+
+	return best, nil
+}
+
+// list returns the versions of a package, local packages take precedence over upstream ones
+func (resolver *bundleResolver) list(context context.Context, packageName string) ([]bundleVersion, error) {
+	if versions, ok := resolver.versions[packageName]; ok {
+		return versions, resolver.errors[packageName]
+	}
+
+	versions, err := resolver.listPrivate(context, packageName)
+
+	if err == nil && len(versions) == 0 {
+		if resolver.upstreamUrl == "" {
+			err = errors.New("package not found and no upstream configured")
+		} else {
+			versions, err = resolver.listUpstream(context, packageName)
+		}
+	}
+
+	resolver.versions[packageName] = versions
+	resolver.errors[packageName] = err
+	return versions, err
+}
+
+func (resolver *bundleResolver) listPrivate(context context.Context, packageName string) ([]bundleVersion, error) {
+	pubVersions := []pubmodel.PubVersionModel{}
+
+	err := resolver.service.db.WithContext(context).Model(&pubmodel.PubVersionModel{}).
+		Select("package_name", "version", "pubspec").
+		Joins("JOIN pub_packages ON pub_packages.name = pub_versions.package_name AND pub_packages.deleted_at IS NULL").
+		Where("package_name = ?", packageName).
+		Where("broken = ?", false).
+		Find(&pubVersions).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []bundleVersion{}
+
+	for _, pubVersion := range pubVersions {
+		semverObj, err := semver.NewVersion(pubVersion.Version)
+
+		if err != nil {
+			continue
+		}
+
+		var pubspec map[string]interface{}
+		json.Unmarshal([]byte(pubVersion.Pubspec), &pubspec)
+
+		versions = append(versions, bundleVersion{
+			packageName: packageName,
+			version:     pubVersion.Version,
+			semver:      semverObj,
+			pubspec:     pubspec,
+			source:      pubdto.BundleSourcePrivate,
+		})
+	}
+
+	return versions, nil
+}
+
+func (resolver *bundleResolver) listUpstream(context context.Context, packageName string) ([]bundleVersion, error) {
+	hostedPackage, err := resolver.service.fetchHostedPackage(context, resolver.upstreamUrl, "", packageName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []bundleVersion{}
+
+	for _, hostedVersion := range hostedPackage.Versions {
+		semverObj, err := semver.NewVersion(hostedVersion.Version)
+
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, bundleVersion{
+			packageName:   packageName,
+			version:       hostedVersion.Version,
+			semver:        semverObj,
+			pubspec:       hostedVersion.Pubspec,
+			source:        pubdto.BundleSourceUpstream,
+			archiveUrl:    hostedVersion.ArchiveUrl,
+			archiveSha256: hostedVersion.ArchiveSha256,
+		})
+	}
+
+	return versions, nil
+}
+
+// hostedDependencies returns the constraints of the hosted `dependencies` of a pubspec, dev dependencies are not needed to build
+func hostedDependencies(pubspec map[string]interface{}) map[string]string {
+	dependencies := map[string]string{}
+	declared, _ := pubspec["dependencies"].(map[string]interface{})
+
+	for packageName, value := range declared {
+		switch dependency := value.(type) {
+		case nil:
+			dependencies[packageName] = ""
+		case string:
+			dependencies[packageName] = dependency
+		case map[string]interface{}:
+			if dependency["sdk"] != nil || dependency["path"] != nil || dependency["git"] != nil {
+				continue
+			}
+			constraint, _ := dependency["version"].(string)
+			dependencies[packageName] = constraint
+		}
+	}
+
+	return dependencies
+}
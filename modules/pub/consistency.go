@@ -0,0 +1,150 @@
+package pub
+
+import (
+	"context"
+	"fmt"
+	"private-pub-repo/modules/pub/pubdto"
+	"private-pub-repo/modules/pub/pubmodel"
+	"strings"
+	"time"
+)
+
+const (
+	archivePrefix = "pub/packages/"
+	archiveSuffix = ".tar.gz"
+	stagingPrefix = "pub/staging/"
+)
+
+// parseArchiveKey is the reverse of `filePathFormat`
+func parseArchiveKey(key string) (packageName string, version string, ok bool) {
+	if !strings.HasPrefix(key, archivePrefix) || !strings.HasSuffix(key, archiveSuffix) {
+		return "", "", false
+	}
+
+	packageName, version, ok = strings.Cut(strings.TrimSuffix(strings.TrimPrefix(key, archivePrefix), archiveSuffix), "/versions/")
+	if !ok || packageName == "" || version == "" || strings.Contains(packageName, "/") || strings.Contains(version, "/") {
+		return "", "", false
+	}
+
+	return packageName, version, true
+}
+
+func (service *pubServiceImpl) CheckConsistency(context context.Context, options *pubdto.ConsistencyCheckDTO) (*pubdto.ConsistencyReportDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.CheckConsistency", map[string]interface{}{
+		"delete_orphans": options.DeleteOrphans,
+		"mark_broken":    options.MarkBroken,
+	})
+	defer span.End()
+
+	report := pubdto.ConsistencyReportDTO{
+		CheckedAt:       time.Now(),
+		MissingArchives: []pubdto.ConsistencyItemDTO{},
+		OrphanArchives:  []pubdto.ConsistencyItemDTO{},
+	}
+
+	// soft deleted versions still own their archive, so they must not be reported as orphans
+	versions := []pubmodel.PubVersionModel{}
+	result := service.db.WithContext(spanContext).Unscoped().Model(versions).
+		Select("package_name", "version", "broken", "created_at", "deleted_at").
+		Find(&versions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	objects, err := service.storage.List(spanContext, archivePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// staged archives are left behind only when the upload process died before cleaning up
+	stagedObjects, err := service.storage.List(spanContext, stagingPrefix)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, stagedObjects...)
+
+	report.ObjectCount = len(objects)
+
+	knownKeys := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		knownKeys[fmt.Sprintf(filePathFormat, version.PackageName, version.Version)] = true
+	}
+
+	storedKeys := make(map[string]bool, len(objects))
+	orphanDeadline := report.CheckedAt.Add(-options.OrphanMinAge)
+	for _, object := range objects {
+		storedKeys[object.Key] = true
+
+		if knownKeys[object.Key] {
+			continue
+		}
+
+		packageName, version, _ := parseArchiveKey(object.Key)
+		report.OrphanArchives = append(report.OrphanArchives, pubdto.ConsistencyItemDTO{
+			Key:          object.Key,
+			PackageName:  packageName,
+			Version:      version,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+
+		if !options.DeleteOrphans || (object.LastModified != nil && object.LastModified.After(orphanDeadline)) {
+			continue
+		}
+
+		if err := service.storage.Delete(spanContext, object.Key); err != nil {
+			return nil, err
+		}
+		report.DeletedOrphans++
+	}
+
+	for _, version := range versions {
+		if version.DeletedAt != nil && version.DeletedAt.Valid {
+			continue
+		}
+		report.VersionCount++
+
+		key := fmt.Sprintf(filePathFormat, version.PackageName, version.Version)
+		hasArchive := storedKeys[key]
+
+		if !hasArchive {
+			report.MissingArchives = append(report.MissingArchives, pubdto.ConsistencyItemDTO{
+				Key:         key,
+				PackageName: version.PackageName,
+				Version:     version.Version,
+			})
+		}
+
+		if !options.MarkBroken || hasArchive != version.Broken {
+			continue
+		}
+
+		// a version just committed may still be waiting for its archive to be promoted, like the orphans above
+		if version.CreatedAt != nil && version.CreatedAt.After(orphanDeadline) {
+			continue
+		}
+
+		result = service.db.WithContext(spanContext).Model(&pubmodel.PubVersionModel{}).
+			Where("package_name = ?", version.PackageName).
+			Where("version = ?", version.Version).
+			Update("broken", !hasArchive)
+
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		if hasArchive {
+			report.RestoredVersions++
+		} else {
+			report.MarkedBroken++
+		}
+	}
+
+	service.monitorService.SetCurrentSpanAttributes(spanContext, map[string]interface{}{
+		"missing_archives": len(report.MissingArchives),
+		"orphan_archives":  len(report.OrphanArchives),
+	})
+
+	return &report, nil
+}
@@ -0,0 +1,63 @@
+package pub
+
+import (
+	"context"
+	"private-pub-repo/modules/pub/pubdto"
+	"time"
+)
+
+func (module *PubModule) startConsistencyJob() {
+	checkConfig := module.config.Config().Pub.ConsistencyCheck
+	interval := checkConfig.Interval
+	if interval <= 0 {
+		return
+	}
+
+	options := pubdto.ConsistencyCheckDTO{
+		DeleteOrphans: checkConfig.DeleteOrphans,
+		MarkBroken:    checkConfig.MarkBroken,
+		OrphanMinAge:  time.Duration(checkConfig.OrphanMinAge) * time.Minute,
+	}
+
+	module.stopConsistencyJob = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				module.runConsistencyJob(&options)
+			case <-stop:
+				return
+			}
+		}
+	}(module.stopConsistencyJob)
+}
+
+func (module *PubModule) runConsistencyJob(options *pubdto.ConsistencyCheckDTO) {
+	report, err := module.Service.CheckConsistency(context.Background(), options)
+
+	if err != nil {
+		module.monitorService.Logger().Error("consistency check failed", "error", err)
+		return
+	}
+
+	module.monitorService.Logger().Info("consistency check",
+		"versions", report.VersionCount,
+		"objects", report.ObjectCount,
+		"missing_archives", len(report.MissingArchives),
+		"orphan_archives", len(report.OrphanArchives),
+		"orphans_deleted", report.DeletedOrphans,
+		"marked_broken", report.MarkedBroken,
+		"restored", report.RestoredVersions,
+	)
+}
+
+func (module *PubModule) stopConsistencyJobIfRunning() {
+	if module.stopConsistencyJob != nil {
+		close(module.stopConsistencyJob)
+		module.stopConsistencyJob = nil
+	}
+}
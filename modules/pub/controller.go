@@ -1,23 +1,30 @@
 package pub
 
 import (
-	"fmt"
+	"errors"
 	"net/url"
 	"private-pub-repo/modules/app"
 	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/monitor"
 	"private-pub-repo/modules/pub/pubdto"
 	"private-pub-repo/modules/pubtoken"
 	"private-pub-repo/modules/user"
 	"private-pub-repo/utils"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 const (
 	jsonResponseType = "application/vnd.pub.v2+json"
 	validationError  = "Validation Error"
+	// per request of the import endpoint, larger imports are left to `pub:import`
+	importMaxPackages = 20
 )
 
 type pubController struct {
@@ -26,17 +33,21 @@ type pubController struct {
 	validator       *validator.Validate
 	middleware      pubtoken.PubTokenJwtMiddleware
 	userMiddleware  user.UserJwtMiddleware
+	monitorService  monitor.MonitorService
+	importUrls      []string
 }
 
 func newPubController(
 	service PubService, responseService app.ResponseService, validator *validator.Validate,
-	middleware pubtoken.PubTokenJwtMiddleware, userMiddleware user.UserJwtMiddleware) *pubController {
+	middleware pubtoken.PubTokenJwtMiddleware, userMiddleware user.UserJwtMiddleware, monitorService monitor.MonitorService, config config.ConfigService) *pubController {
 	return &pubController{
 		service:         service,
 		responseService: responseService,
 		validator:       validator,
 		middleware:      middleware,
 		userMiddleware:  userMiddleware,
+		monitorService:  monitorService,
+		importUrls:      config.Config().Pub.ImportUrls,
 	}
 }
 
@@ -100,7 +111,7 @@ func (controller *pubController) handleDoUpload(ctx *fiber.Ctx) error {
 		return controller.processError(ctx, fiber.StatusBadRequest, err.Error())
 	}
 
-	err = controller.service.UploadVersion(ctx.UserContext(), file, controller.middleware.GetPubUserId(ctx))
+	err = controller.service.UploadVersion(ctx.UserContext(), file, controller.middleware.GetPubUserId(ctx), controller.middleware.GetPubPackage(ctx))
 
 	if err != nil {
 		return ctx.Redirect(ctx.BaseURL()+"/"+finishUploadUrlPath+"?error="+url.QueryEscape(err.Error()), fiber.StatusNoContent)
@@ -177,7 +188,6 @@ func (controller *pubController) handleQueryVersionList(ctx *fiber.Ctx) error {
 	packageName := ctx.Params("package")
 
 	publicOnly := !utils.HasJwt(ctx) || controller.userMiddleware.HasAccess(ctx) != nil
-	fmt.Printf("%v", !utils.HasJwt(ctx))
 
 	list, err := controller.service.QueryVersionList(ctx.UserContext(), packageName, request, publicOnly)
 
@@ -204,9 +214,158 @@ func (controller *pubController) handleQueryVersionDetail(ctx *fiber.Ctx) error
 	return controller.responseService.SendSuccessDetailResponse(ctx, 200, user)
 }
 
+func (controller *pubController) handleQueryPackageDelete(ctx *fiber.Ctx) error {
+	err := controller.service.DeletePackage(ctx.UserContext(), ctx.Params("package"))
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, nil)
+}
+
+func (controller *pubController) handleQueryVersionDelete(ctx *fiber.Ctx) error {
+	err := controller.service.DeleteVersion(ctx.UserContext(), ctx.Params("package"), ctx.Params("version"))
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, nil)
+}
+
+func (controller *pubController) handleQueryDeletedPackageList(ctx *fiber.Ctx) error {
+	request := appmodel.NewGetListRequest(ctx.Query("page"), ctx.Query("limit"), ctx.Query("search"))
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	list, err := controller.service.QueryDeletedPackageList(ctx.UserContext(), request)
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+
+	return controller.responseService.SendSuccessResponse(ctx, 200, appmodel.PaginationResponse{
+		List: list,
+	})
+}
+
+func (controller *pubController) handleQueryDeletedVersionList(ctx *fiber.Ctx) error {
+	request := appmodel.NewGetListRequest(ctx.Query("page"), ctx.Query("limit"), ctx.Query("search"))
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	list, err := controller.service.QueryDeletedVersionList(ctx.UserContext(), ctx.Params("package"), request)
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+
+	return controller.responseService.SendSuccessResponse(ctx, 200, appmodel.PaginationResponse{
+		List: list,
+	})
+}
+
+func (controller *pubController) handleQueryPackageRestore(ctx *fiber.Ctx) error {
+	pubPackage, err := controller.service.RestorePackage(ctx.UserContext(), ctx.Params("package"))
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, pubPackage)
+}
+
+func (controller *pubController) handleQueryVersionRestore(ctx *fiber.Ctx) error {
+	pubVersion, err := controller.service.RestoreVersion(ctx.UserContext(), ctx.Params("package"), ctx.Params("version"))
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, pubVersion)
+}
+
+func (controller *pubController) handleQueryPackagePurge(ctx *fiber.Ctx) error {
+	err := controller.service.PurgePackage(ctx.UserContext(), ctx.Params("package"))
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, nil)
+}
+
+func (controller *pubController) handleQueryVersionPurge(ctx *fiber.Ctx) error {
+	err := controller.service.PurgeVersion(ctx.UserContext(), ctx.Params("package"), ctx.Params("version"))
+
+	if err != nil {
+		return controller.deletionError(ctx, err)
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, nil)
+}
+
+func (controller *pubController) handleQueryImport(ctx *fiber.Ctx) error {
+	request := pubdto.ImportDTO{}
+	ctx.BodyParser(&request)
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	// the server filesystem & any url would be readable by the admins, directories & other repositories are left to `pub:import`
+	if request.Source != pubdto.ImportSourceHosted {
+		return fiber.NewError(400, "Directory imports are only available with the pub:import command")
+	}
+
+	if !slices.ContainsFunc(controller.importUrls, func(importUrl string) bool {
+		return strings.TrimRight(importUrl, "/") == strings.TrimRight(request.Url, "/")
+	}) {
+		return fiber.NewError(403, "Url is not one of the IMPORT_URLS of the configuration")
+	}
+
+	if len(request.Packages) == 0 || len(request.Packages) > importMaxPackages {
+		return fiber.NewError(400, "List 1 to "+strconv.Itoa(importMaxPackages)+" packages, import more with the pub:import command")
+	}
+
+	userId, err := utils.GetFiberJwtUserId(ctx)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	request.SameOriginArchives = true
+	report, err := controller.service.Import(ctx.UserContext(), &request, &userId)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, report)
+}
+
 // handlers end
 
+// deletionError = status of the errors of the deletion endpoints, database errors are logged instead of shown
+func (controller *pubController) deletionError(ctx *fiber.Ctx, err error) error {
+	var fiberError *fiber.Error
+	switch {
+	case errors.As(err, &fiberError):
+		return fiberError
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.ErrNotFound
+	}
+
+	controller.monitorService.Logger().ErrorContext(ctx.UserContext(), "package deletion failed", "error", err)
+	return fiber.ErrInternalServerError
+}
+
 func (controller *pubController) handleControllerError(ctx *fiber.Ctx, currentPath string, err error) error {
+	if err == ErrPackageUnavailable {
+		return controller.processError(ctx, fiber.StatusNotFound, "Not Found")
+	}
+
 	if err == fiber.ErrNotFound {
 		if url := controller.service.GetUpstreamUrl(ctx.UserContext(), currentPath); url != nil {
 			ctx.Redirect(*url, fiber.StatusFound)
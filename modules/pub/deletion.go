@@ -0,0 +1,316 @@
+package pub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/utils"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (service *pubServiceImpl) DeletePackage(context context.Context, packageName string) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.DeletePackage", map[string]interface{}{
+		"package": packageName,
+	})
+	defer span.End()
+
+	// versions share the package deletion time, so restoring the package only brings back
+	// versions deleted together with it, not the ones deleted individually before.
+	// `NowFunc` rounds it to the precision the dialect stores, otherwise equality on it breaks
+	now := service.db.NowFunc()
+
+	return service.db.WithContext(spanContext).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&pubmodel.PubPackageModel{}).Where("name = ?", packageName).Update("deleted_at", now)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return fiber.ErrNotFound
+		}
+
+		return tx.Model(&pubmodel.PubVersionModel{}).Where("package_name = ?", packageName).Update("deleted_at", now).Error
+	})
+}
+
+func (service *pubServiceImpl) DeleteVersion(context context.Context, packageName string, version string) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.DeleteVersion", map[string]interface{}{
+		"package": packageName,
+		"version": version,
+	})
+	defer span.End()
+
+	result := service.db.WithContext(spanContext).
+		Where("package_name = ?", packageName).
+		Where("version = ?", version).
+		Delete(&pubmodel.PubVersionModel{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fiber.ErrNotFound
+	}
+
+	return nil
+}
+
+func (service *pubServiceImpl) RestorePackage(context context.Context, packageName string) (*pubmodel.PubPackageModel, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.RestorePackage", map[string]interface{}{
+		"package": packageName,
+	})
+	defer span.End()
+
+	pubPackage := pubmodel.PubPackageModel{}
+
+	err := service.db.WithContext(spanContext).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&pubPackage, "name = ?", packageName)
+
+		if result.Error != nil {
+			return fiber.ErrNotFound
+		}
+
+		result = tx.Unscoped().Model(&pubmodel.PubVersionModel{}).
+			Where("package_name = ?", packageName).
+			Where("deleted_at = ?", pubPackage.DeletedAt).
+			Update("deleted_at", nil)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		pubPackage.DeletedAt = nil
+		return tx.Unscoped().Model(&pubmodel.PubPackageModel{}).Where("name = ?", packageName).Update("deleted_at", nil).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &pubPackage, nil
+}
+
+func (service *pubServiceImpl) RestoreVersion(context context.Context, packageName string, version string) (*pubmodel.PubVersionModel, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.RestoreVersion", map[string]interface{}{
+		"package": packageName,
+		"version": version,
+	})
+	defer span.End()
+
+	pubPackage := pubmodel.PubPackageModel{}
+	result := service.db.WithContext(spanContext).First(&pubPackage, "name = ?", packageName)
+
+	if result.Error != nil {
+		return nil, fiber.NewError(409, fmt.Sprintf("package %s doesn't exist or is deleted, restore the package first", packageName))
+	}
+
+	result = service.db.WithContext(spanContext).Unscoped().Model(&pubmodel.PubVersionModel{}).
+		Where("package_name = ?", packageName).
+		Where("version = ?", version).
+		Where("deleted_at IS NOT NULL").
+		Update("deleted_at", nil)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fiber.ErrNotFound
+	}
+
+	return service.QueryVersionDetail(spanContext, packageName, version, false)
+}
+
+func (service *pubServiceImpl) PurgePackage(context context.Context, packageName string) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.PurgePackage", map[string]interface{}{
+		"package": packageName,
+	})
+	defer span.End()
+
+	versions := []pubmodel.PubVersionModel{}
+
+	err := service.db.WithContext(spanContext).Transaction(func(tx *gorm.DB) error {
+		pubPackage := pubmodel.PubPackageModel{}
+		result := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL").
+			First(&pubPackage, "name = ?", packageName)
+
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fiber.NewError(404, fmt.Sprintf("deleted package %s not found, only deleted package can be purged", packageName))
+		}
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Unscoped().Where("package_name = ?", packageName).Find(&versions)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Unscoped().Where("package_name = ?", packageName).Delete(&pubmodel.PubVersionModel{})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Unscoped().Where("name = ?", packageName).Delete(&pubmodel.PubPackageModel{}).Error
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// rows are gone already, archives that fail to be deleted will show up as orphans in `storage:check`
+	for _, version := range versions {
+		if err := service.storage.Delete(spanContext, fmt.Sprintf(filePathFormat, packageName, version.Version)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (service *pubServiceImpl) PurgeVersion(context context.Context, packageName string, version string) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.PurgeVersion", map[string]interface{}{
+		"package": packageName,
+		"version": version,
+	})
+	defer span.End()
+
+	result := service.db.WithContext(spanContext).Unscoped().
+		Where("package_name = ?", packageName).
+		Where("version = ?", version).
+		Where("deleted_at IS NOT NULL").
+		Delete(&pubmodel.PubVersionModel{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fiber.NewError(404, fmt.Sprintf("deleted version %s of package %s not found, only deleted version can be purged", version, packageName))
+	}
+
+	return service.storage.Delete(spanContext, fmt.Sprintf(filePathFormat, packageName, version))
+}
+
+func (service *pubServiceImpl) QueryDeletedPackageList(context context.Context, req *appmodel.GetListRequest) (*appmodel.PaginationResponseList, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.QueryDeletedPackageList", map[string]interface{}{})
+	defer span.End()
+	var count int64
+	packages := []pubmodel.PubPackageModel{}
+	query := service.db.WithContext(spanContext).Unscoped().Model(packages).Where("deleted_at IS NOT NULL")
+
+	if req.Search != "" {
+		query.Where(utils.InsensitiveLike("name"), utils.ContainsPattern(req.Search))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Perform count and find concurrently using goroutines
+	errChan := make(chan error, 2)
+	go func() {
+		defer wg.Done()
+		errChan <- query.Session(&gorm.Session{}).Count(&count).Error
+	}()
+
+	go func() {
+		defer wg.Done()
+		query = query.Session(&gorm.Session{})
+		errChan <- query.
+			Order("deleted_at DESC").
+			Limit(req.Limit).Offset((req.Page - 1) * req.Limit).Find(&packages).Error
+	}()
+
+	wg.Wait()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-errChan:
+			if err != nil {
+				return nil, err
+			}
+		default:
+		}
+	}
+
+	count32 := int(count)
+
+	return &appmodel.PaginationResponseList{
+		Pagination: &appmodel.PaginationResponsePagination{
+			Page:  &req.Page,
+			Size:  &req.Limit,
+			Total: &count32,
+		},
+		Content: packages,
+	}, nil
+}
+
+func (service *pubServiceImpl) QueryDeletedVersionList(context context.Context, packageName string, req *appmodel.GetListRequest) (*appmodel.PaginationResponseList, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.QueryDeletedVersionList", map[string]interface{}{})
+	defer span.End()
+	var count int64
+	versions := []pubmodel.PubVersionModel{}
+	query := service.db.WithContext(spanContext).Unscoped().Model(versions).
+		Select("package_name", "version", "broken", "created_at", "updated_at", "deleted_at").
+		Where("package_name = ?", packageName).
+		Where("deleted_at IS NOT NULL")
+
+	if req.Search != "" {
+		query.Where(utils.InsensitiveLike("version"), utils.ContainsPattern(req.Search))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Perform count and find concurrently using goroutines
+	errChan := make(chan error, 2)
+	go func() {
+		defer wg.Done()
+		errChan <- query.Session(&gorm.Session{}).Count(&count).Error
+	}()
+
+	go func() {
+		defer wg.Done()
+		query = query.Session(&gorm.Session{})
+		errChan <- query.
+			Order("deleted_at DESC").
+			Limit(req.Limit).Offset((req.Page - 1) * req.Limit).Find(&versions).Error
+	}()
+
+	wg.Wait()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-errChan:
+			if err != nil {
+				return nil, err
+			}
+		default:
+		}
+	}
+
+	count32 := int(count)
+
+	return &appmodel.PaginationResponseList{
+		Pagination: &appmodel.PaginationResponsePagination{
+			Page:  &req.Page,
+			Size:  &req.Limit,
+			Total: &count32,
+		},
+		Content: versions,
+	}, nil
+}
@@ -0,0 +1,591 @@
+package pub
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"private-pub-repo/modules/pub/pubdto"
+	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/modules/setting"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// pub.dev lists every package & version of a package in one document, a few megabytes at most
+	maxHostedDocumentSize = 32 * 1024 * 1024
+	// the whole request, reading an archive as large as the upload size limit included.
+	// the import endpoint waits for it, its context isn't cancelled when the client disconnects
+	hostedRequestTimeout = 5 * time.Minute
+)
+
+// importCandidate is a single version found in the import source, not yet published
+type importCandidate struct {
+	packageName string
+	version     string
+	source      string
+	publishedAt *time.Time
+	// open is nil for hosted candidates, their archive is downloaded right before publishing
+	open          func() (io.ReadCloser, error)
+	archiveSha256 string
+}
+
+func newDirectoryCandidate(path string, pubspec map[string]interface{}, open func() (io.ReadCloser, error)) importCandidate {
+	name, _ := pubspec["name"].(string)
+	version, _ := pubspec["version"].(string)
+	return importCandidate{packageName: name, version: version, source: path, open: open}
+}
+
+type hostedVersionDTO struct {
+	Version       string                 `json:"version"`
+	ArchiveUrl    string                 `json:"archive_url"`
+	ArchiveSha256 string                 `json:"archive_sha256"`
+	Published     *time.Time             `json:"published"`
+	Pubspec       map[string]interface{} `json:"pubspec"`
+}
+
+type hostedPackageDTO struct {
+	Name     string             `json:"name"`
+	Versions []hostedVersionDTO `json:"versions"`
+}
+
+func (service *pubServiceImpl) Import(context context.Context, options *pubdto.ImportDTO, userId *uuid.UUID) (*pubdto.ImportReportDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.Import", map[string]interface{}{
+		"source": options.Source,
+	})
+	defer span.End()
+
+	report := pubdto.ImportReportDTO{Results: []pubdto.ImportResultDTO{}}
+
+	switch options.Source {
+	case pubdto.ImportSourceHosted:
+		baseUrl := strings.TrimRight(options.Url, "/")
+		packageNames := options.Packages
+
+		if len(packageNames) == 0 {
+			names, err := service.fetchHostedPackageNames(spanContext, baseUrl, options.Token)
+
+			if err != nil {
+				return nil, err
+			}
+			packageNames = names
+		}
+
+		for _, packageName := range packageNames {
+			candidates, err := service.fetchHostedCandidates(spanContext, baseUrl, options.Token, packageName)
+
+			if err != nil {
+				// a missing package should not stop the rest of the import
+				service.recordImportResult(spanContext, &report, pubdto.ImportResultDTO{
+					PackageName: packageName, Source: baseUrl, Status: pubdto.ImportStatusFailed, Error: err.Error(),
+				})
+				continue
+			}
+
+			if err := service.importCandidates(spanContext, &report, candidates, baseUrl, options, userId); err != nil {
+				return &report, err
+			}
+		}
+	case pubdto.ImportSourceDirectory:
+		candidates, err := service.findDirectoryCandidates(options.Path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := service.importCandidates(spanContext, &report, candidates, "", options, userId); err != nil {
+			return &report, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown import source %s", options.Source)
+	}
+
+	return &report, nil
+}
+
+// importCandidates publishes the candidates in the given order. Versions that already exist,
+// including soft deleted ones, are skipped, so a partial import can simply be run again
+func (service *pubServiceImpl) importCandidates(
+	context context.Context,
+	report *pubdto.ImportReportDTO,
+	candidates []importCandidate,
+	baseUrl string,
+	options *pubdto.ImportDTO,
+	userId *uuid.UUID,
+) error {
+	for _, candidate := range candidates {
+		if err := context.Err(); err != nil {
+			return err
+		}
+
+		result := pubdto.ImportResultDTO{
+			PackageName: candidate.packageName,
+			Version:     candidate.version,
+			Source:      candidate.source,
+		}
+
+		exists, err := service.versionExists(context, candidate.packageName, candidate.version)
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case exists:
+			result.Status = pubdto.ImportStatusSkipped
+			result.Error = "version already exists"
+		case options.DryRun:
+			result.Status = pubdto.ImportStatusImported
+		default:
+			err = service.importCandidate(context, &candidate, baseUrl, options.Token, options.SameOriginArchives, userId)
+
+			if errors.Is(err, ErrVersionExists) {
+				result.Status = pubdto.ImportStatusSkipped
+				result.Error = "version already exists"
+			} else if err != nil {
+				result.Status = pubdto.ImportStatusFailed
+				result.Error = err.Error()
+			} else {
+				result.Status = pubdto.ImportStatusImported
+			}
+		}
+
+		service.recordImportResult(context, report, result)
+	}
+
+	return nil
+}
+
+func (service *pubServiceImpl) importCandidate(context context.Context, candidate *importCandidate, baseUrl string, token string, sameOriginArchives bool, userId *uuid.UUID) error {
+	open := candidate.open
+
+	if open == nil {
+		if sameOriginArchives && !sameOrigin(candidate.source, baseUrl) {
+			return fmt.Errorf("archive url %s is not on the host of %s", candidate.source, baseUrl)
+		}
+
+		archivePath, err := service.downloadHostedArchive(context, candidate, baseUrl, token)
+
+		if err != nil {
+			return err
+		}
+		defer os.Remove(archivePath)
+
+		open = func() (io.ReadCloser, error) {
+			return os.Open(archivePath)
+		}
+	}
+
+	// the listing is what was checked for existing versions, another version would be published unchecked
+	packageName, version, err := service.archiveVersion(open)
+
+	if err != nil {
+		return err
+	}
+
+	if packageName != candidate.packageName || version != candidate.version {
+		return fmt.Errorf("archive contains %s %s instead", packageName, version)
+	}
+
+	_, err = service.publishArchive(context, open, userId, nil, candidate.publishedAt)
+	return err
+}
+
+// archiveVersion = package name & version of the pubspec of the archive
+func (service *pubServiceImpl) archiveVersion(open func() (io.ReadCloser, error)) (string, string, error) {
+	reader, err := open()
+
+	if err != nil {
+		return "", "", err
+	}
+	defer reader.Close()
+
+	tarPackageInfo := pubdto.TarPackageInfoDTO{}
+	hasPubspec, shouldReturn, err := service.readArchiveContent(reader, &tarPackageInfo)
+
+	if shouldReturn {
+		return "", "", err
+	}
+
+	if !hasPubspec {
+		return "", "", fmt.Errorf("did not find any pubspec.yaml file in the archive")
+	}
+
+	packageName, _ := tarPackageInfo.Pubspec["name"].(string)
+	version, _ := tarPackageInfo.Pubspec["version"].(string)
+
+	return packageName, version, nil
+}
+
+func (service *pubServiceImpl) recordImportResult(context context.Context, report *pubdto.ImportReportDTO, result pubdto.ImportResultDTO) {
+	attributes := []interface{}{"package", result.PackageName, "version", result.Version, "status", result.Status}
+	if result.Error != "" {
+		service.monitorService.Logger().WarnContext(context, "import", append(attributes, "error", result.Error)...)
+	} else {
+		service.monitorService.Logger().InfoContext(context, "import", attributes...)
+	}
+	report.Add(result)
+}
+
+func (service *pubServiceImpl) versionExists(context context.Context, packageName string, version string) (bool, error) {
+	var count int64
+
+	err := service.db.WithContext(context).Unscoped().Model(&pubmodel.PubVersionModel{}).
+		Where("package_name = ?", packageName).
+		Where("version = ?", version).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// hosted source start
+
+func (service *pubServiceImpl) hostedRequest(context context.Context, requestUrl string, baseUrl string, token string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(context, http.MethodGet, requestUrl, nil)
+
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", jsonResponseType)
+
+	// archives are usually served from a presigned storage url, which rejects a second authorization
+	if token != "" && sameOrigin(requestUrl, baseUrl) {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := service.hostedClient.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("GET %s: unexpected status %d", requestUrl, response.StatusCode)
+	}
+
+	return response, nil
+}
+
+func (service *pubServiceImpl) fetchHostedPackageNames(context context.Context, baseUrl string, token string) ([]string, error) {
+	response, err := service.hostedRequest(context, baseUrl+"/api/package-names", baseUrl, token)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not list packages, pass them explicitly instead: %w", err)
+	}
+	defer response.Body.Close()
+
+	body := struct {
+		Packages []string `json:"packages"`
+	}{}
+
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxHostedDocumentSize)).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return body.Packages, nil
+}
+
+// fetchHostedCandidates keeps the order of the version listing, which is the publish order
+func (service *pubServiceImpl) fetchHostedCandidates(context context.Context, baseUrl string, token string, packageName string) ([]importCandidate, error) {
+	hostedPackage, err := service.fetchHostedPackage(context, baseUrl, token, packageName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]importCandidate, len(hostedPackage.Versions))
+
+	for i, version := range hostedPackage.Versions {
+		candidates[i] = importCandidate{
+			packageName:   packageName,
+			version:       version.Version,
+			source:        version.ArchiveUrl,
+			publishedAt:   version.Published,
+			archiveSha256: version.ArchiveSha256,
+		}
+	}
+
+	return candidates, nil
+}
+
+func (service *pubServiceImpl) fetchHostedPackage(context context.Context, baseUrl string, token string, packageName string) (*hostedPackageDTO, error) {
+	response, err := service.hostedRequest(context, baseUrl+"/api/packages/"+url.PathEscape(packageName), baseUrl, token)
+
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	hostedPackage := hostedPackageDTO{}
+
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxHostedDocumentSize)).Decode(&hostedPackage); err != nil {
+		return nil, err
+	}
+
+	return &hostedPackage, nil
+}
+
+// downloadHostedArchive writes the archive to a temporary file, since publishing reads it twice
+func (service *pubServiceImpl) downloadHostedArchive(context context.Context, candidate *importCandidate, baseUrl string, token string) (string, error) {
+	response, err := service.hostedRequest(context, candidate.source, baseUrl, token)
+
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	path, archiveSha256, err := writeTempArchive(response.Body, service.archiveSizeLimit())
+
+	if err != nil {
+		return "", err
+	}
+
+	if candidate.archiveSha256 != "" && !strings.EqualFold(archiveSha256, candidate.archiveSha256) {
+		os.Remove(path)
+		return "", fmt.Errorf("archive sha256 mismatch")
+	}
+
+	return path, nil
+}
+
+// archiveSizeLimit = bytes, same limit as the uploads since the archives end up in the same storage
+func (service *pubServiceImpl) archiveSizeLimit() int64 {
+	return int64(service.settingService.Int(setting.UploadSizeLimit)) * 1024 * 1024
+}
+
+// writeTempArchive copies the archive into a temporary file, returning its path and sha256.
+// archives larger than limit bytes are refused
+func writeTempArchive(reader io.Reader, limit int64) (string, string, error) {
+	file, err := os.CreateTemp("", "pub-archive-*.tar.gz")
+
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(reader, limit+1))
+
+	if err == nil && written > limit {
+		err = fmt.Errorf("archive is larger than the upload size limit of %d MB", limit/1024/1024)
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return "", "", err
+	}
+
+	return file.Name(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func sameOrigin(first string, second string) bool {
+	firstUrl, err := url.Parse(first)
+
+	if err != nil {
+		return false
+	}
+
+	secondUrl, err := url.Parse(second)
+
+	if err != nil {
+		return false
+	}
+
+	return firstUrl.Scheme == secondUrl.Scheme && firstUrl.Host == secondUrl.Host
+}
+
+// hosted source end
+
+// directory source start
+
+// findDirectoryCandidates collects `.tar.gz` archives and unpacked packages, i.e. directories
+// holding a pubspec.yaml as found in `.pub-cache/hosted/<host>/<package>-<version>`.
+// Without publish dates the versions of a package are imported in semver order
+func (service *pubServiceImpl) findDirectoryCandidates(root string) ([]importCandidate, error) {
+	candidates := []importCandidate{}
+	invalid := []string{}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			pubspecPath := filepath.Join(path, "pubspec.yaml")
+
+			if _, err := os.Stat(pubspecPath); err != nil {
+				return nil
+			}
+
+			content, err := os.ReadFile(pubspecPath)
+
+			if err != nil {
+				return err
+			}
+
+			pubspec := map[string]interface{}{}
+
+			if err := yaml.Unmarshal(content, &pubspec); err != nil {
+				invalid = append(invalid, path)
+				return filepath.SkipDir
+			}
+
+			candidates = append(candidates, newDirectoryCandidate(path, pubspec, packDirectory(path)))
+			return filepath.SkipDir
+		}
+
+		if !strings.HasSuffix(entry.Name(), ".tar.gz") {
+			return nil
+		}
+
+		reader, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		tarPackageInfo := pubdto.TarPackageInfoDTO{}
+		hasPubspec, shouldReturn, _ := service.readArchiveContent(reader, &tarPackageInfo)
+
+		if shouldReturn || !hasPubspec {
+			invalid = append(invalid, path)
+			return nil
+		}
+
+		archivePath := path
+		candidates = append(candidates, newDirectoryCandidate(path, tarPackageInfo.Pubspec, func() (io.ReadCloser, error) {
+			return os.Open(archivePath)
+		}))
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range invalid {
+		service.monitorService.Logger().Warn("import: ignoring directory, no readable pubspec.yaml", "path", path)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].packageName != candidates[j].packageName {
+			return candidates[i].packageName < candidates[j].packageName
+		}
+
+		// unparsable versions go first, publishing reports them as invalid
+		first, errFirst := semver.NewVersion(candidates[i].version)
+		second, errSecond := semver.NewVersion(candidates[j].version)
+
+		if errFirst != nil || errSecond != nil {
+			return errFirst != nil && errSecond == nil
+		}
+		return first.LessThan(second)
+	})
+
+	return candidates, nil
+}
+
+// packDirectory returns an opener that archives an unpacked package the way `dart pub publish` does
+func packDirectory(root string) func() (io.ReadCloser, error) {
+	var archive []byte
+
+	return func() (io.ReadCloser, error) {
+		if archive == nil {
+			buffer := bytes.Buffer{}
+
+			if err := writeDirectoryArchive(root, &buffer); err != nil {
+				return nil, err
+			}
+			archive = buffer.Bytes()
+		}
+
+		return io.NopCloser(bytes.NewReader(archive)), nil
+	}
+}
+
+func writeDirectoryArchive(root string, writer io.Writer) error {
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(root, path)
+
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+// directory source end
@@ -0,0 +1,215 @@
+package pub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"private-pub-repo/modules/pub/pubdto"
+	"private-pub-repo/modules/pub/pubmodel"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// the static tree uses the same url layout as the server, so `<base url>/v1/pub` is the hosted url for both
+const (
+	mirrorRootPath           = "v1/pub"
+	mirrorPackageNamesPath   = mirrorRootPath + "/api/package-names"
+	mirrorDocumentPathFormat = mirrorRootPath + "/api/packages/%s"
+	mirrorArchivePathFormat  = mirrorRootPath + "/packages/%s/versions/%s.tar.gz"
+)
+
+func (service *pubServiceImpl) GenerateMirror(context context.Context, options *pubdto.MirrorDTO) (*pubdto.MirrorReportDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.GenerateMirror", map[string]interface{}{})
+	defer span.End()
+
+	baseUrl := strings.TrimRight(options.BaseUrl, "/")
+	report := pubdto.MirrorReportDTO{GeneratedAt: time.Now()}
+	packages := []pubmodel.PubPackageModel{}
+	query := service.db.WithContext(spanContext).Model(packages)
+
+	if !options.IncludePrivate {
+		query.Where("private = false")
+	}
+
+	if err := query.Order("name ASC").Find(&packages).Error; err != nil {
+		return nil, err
+	}
+
+	// every file of the tree, anything else is pruned
+	expected := map[string]bool{}
+	packageNames := []string{}
+
+	for _, pubPackage := range packages {
+		// same document the Pub API serves, only with archive urls pointing to the mirror
+		pubPackageDTO, err := service.VersionList(spanContext, pubPackage.Name, baseUrl, !options.IncludePrivate)
+
+		if err == fiber.ErrNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, version := range pubPackageDTO.Versions {
+			archivePath := fmt.Sprintf(mirrorArchivePathFormat, pubPackage.Name, version.Version)
+			expected[archivePath] = true
+
+			copied, err := service.mirrorArchive(spanContext, options.OutputPath, archivePath, fmt.Sprintf(filePathFormat, pubPackage.Name, version.Version))
+
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", pubPackage.Name, version.Version, err)
+			}
+
+			if copied {
+				report.CopiedArchives++
+			} else {
+				report.SkippedArchives++
+			}
+			report.Versions++
+		}
+
+		// documents are written after their archives, so a served document never points to a missing archive
+		documentPath := fmt.Sprintf(mirrorDocumentPathFormat, pubPackage.Name)
+		expected[documentPath] = true
+
+		if err := writeMirrorJson(options.OutputPath, documentPath, pubPackageDTO); err != nil {
+			return nil, err
+		}
+
+		packageNames = append(packageNames, pubPackage.Name)
+		report.Packages++
+	}
+
+	expected[mirrorPackageNamesPath] = true
+	err := writeMirrorJson(options.OutputPath, mirrorPackageNamesPath, map[string]interface{}{"packages": packageNames})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Prune {
+		pruned, err := pruneMirror(options.OutputPath, expected)
+
+		if err != nil {
+			return nil, err
+		}
+		report.PrunedFiles = pruned
+	}
+
+	return &report, nil
+}
+
+// mirrorArchive copies the archive unless the mirror already has it, archives never change once published
+func (service *pubServiceImpl) mirrorArchive(context context.Context, outputPath string, archivePath string, key string) (bool, error) {
+	object, err := service.storage.Stat(context, key)
+
+	if err != nil {
+		return false, err
+	}
+
+	if info, err := os.Stat(filepath.Join(outputPath, filepath.FromSlash(archivePath))); err == nil && info.Size() == object.Size {
+		return false, nil
+	}
+
+	reader, err := service.storage.Download(context, key)
+
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	return true, writeMirrorFile(outputPath, archivePath, reader)
+}
+
+func writeMirrorJson(outputPath string, path string, value interface{}) error {
+	content, err := json.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	return writeMirrorFile(outputPath, path, bytes.NewReader(content))
+}
+
+// writeMirrorFile replaces the file atomically, so the tree can be served while it is regenerated
+func writeMirrorFile(outputPath string, path string, reader io.Reader) error {
+	target := filepath.Join(outputPath, filepath.FromSlash(path))
+
+	// names & versions come from the database, they must not point outside of the tree
+	relativePath, err := filepath.Rel(outputPath, target)
+
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) || filepath.IsAbs(relativePath) {
+		return fmt.Errorf("mirror path %s is outside of %s", path, outputPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(target), ".mirror-*")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, reader)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), target)
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+	}
+
+	return err
+}
+
+func pruneMirror(outputPath string, expected map[string]bool) (int, error) {
+	root := filepath.Join(outputPath, filepath.FromSlash(mirrorRootPath))
+	pruned := 0
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(outputPath, path)
+
+		if err != nil {
+			return err
+		}
+
+		if expected[filepath.ToSlash(relativePath)] {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		pruned++
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return pruned, nil
+	}
+
+	return pruned, err
+}
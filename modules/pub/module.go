@@ -9,6 +9,8 @@ import (
 	"private-pub-repo/modules/monitor"
 	"private-pub-repo/modules/pub/pubmodel"
 	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/storage"
 	"private-pub-repo/modules/user"
 
@@ -17,17 +19,21 @@ import (
 )
 
 type PubModule struct {
-	Service        PubService
-	middleware     pubtoken.PubTokenJwtMiddleware
-	userMiddleware user.UserJwtMiddleware
-	controller     *pubController
-	jwtService     jwt.JwtService
-	db             db.DbService
-	app            *fiber.App
+	Service            PubService
+	middleware         pubtoken.PubTokenJwtMiddleware
+	userMiddleware     user.UserJwtMiddleware
+	controller         *pubController
+	jwtService         jwt.JwtService
+	db                 db.DbService
+	app                *fiber.App
+	config             config.ConfigService
+	monitorService     monitor.MonitorService
+	rateLimit          ratelimit.RateLimitService
+	stopConsistencyJob chan struct{}
 }
 
-func NewModule(service PubService, middleware pubtoken.PubTokenJwtMiddleware, userMiddleware user.UserJwtMiddleware, controller *pubController, jwtService jwt.JwtService, db db.DbService, app *fiber.App) *PubModule {
-	return &PubModule{Service: service, middleware: middleware, userMiddleware: userMiddleware, jwtService: jwtService, controller: controller, db: db, app: app}
+func NewModule(service PubService, middleware pubtoken.PubTokenJwtMiddleware, userMiddleware user.UserJwtMiddleware, controller *pubController, jwtService jwt.JwtService, db db.DbService, app *fiber.App, config config.ConfigService, monitorService monitor.MonitorService, rateLimit ratelimit.RateLimitService) *PubModule {
+	return &PubModule{Service: service, middleware: middleware, userMiddleware: userMiddleware, jwtService: jwtService, controller: controller, db: db, app: app, config: config, monitorService: monitorService, rateLimit: rateLimit}
 }
 
 func fxRegister(lifeCycle fx.Lifecycle, module *PubModule) {
@@ -37,11 +43,11 @@ func fxRegister(lifeCycle fx.Lifecycle, module *PubModule) {
 func SetupModule(
 	app *app.AppModule, db *db.DbModule, jwt *jwt.JwtModule, pubToken *pubtoken.PubTokenModule,
 	user *user.UserModule, monitor *monitor.MonitorModule, config *config.ConfigModule,
-	storage *storage.StorageModule,
+	storage *storage.StorageModule, setting *setting.SettingModule, rateLimit *ratelimit.RateLimitModule,
 ) *PubModule {
-	service := NewPubService(jwt, monitor.Service, config, storage)
-	controller := newPubController(service, app.ResponseService, app.Validator, pubToken.Middleware, user.Middleware)
-	return NewModule(service, pubToken.Middleware, user.Middleware, controller, jwt, db, app.App)
+	service := NewPubService(jwt, monitor.Service, storage, setting.Service)
+	controller := newPubController(service, app.ResponseService, app.Validator, pubToken.Middleware, user.Middleware, monitor.Service, config)
+	return NewModule(service, pubToken.Middleware, user.Middleware, controller, jwt, db, app.App, config, monitor.Service, rateLimit.Service)
 }
 
 var FxModule = fx.Module("Pub", fx.Provide(NewPubService), fx.Provide(newPubController), fx.Provide(NewModule), fx.Invoke(fxRegister))
@@ -56,10 +62,12 @@ func (module *PubModule) OnStart() error {
 	//run seeder
 	module.Service.Init(module.db)
 	module.registerRoutes()
+	module.startConsistencyJob()
 	return nil
 }
 
 func (module *PubModule) OnStop() error {
+	module.stopConsistencyJobIfRunning()
 	return nil
 }
 
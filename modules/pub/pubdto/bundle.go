@@ -0,0 +1,44 @@
+package pubdto
+
+import "time"
+
+const (
+	BundleFormatVersion = 1
+
+	BundleManifestPath      = "manifest.json"
+	BundleArchivesDirectory = "archives/"
+	BundleArchivePathFormat = BundleArchivesDirectory + "%s/%s.tar.gz"
+
+	BundleSourcePrivate  = "private"
+	BundleSourceUpstream = "upstream"
+)
+
+type BundleExportDTO struct {
+	// root packages as `name` for the newest version, or `name:constraint`, e.g. `foo:^1.2.0`
+	Packages []string
+	// content of a pubspec.lock, its hosted packages are taken as is, without resolving further
+	Lockfile []byte
+}
+
+type BundlePackageDTO struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Source        string `json:"source"`
+	Path          string `json:"path"`
+	ArchiveSha256 string `json:"archive_sha256"`
+}
+
+type BundleUnresolvedDTO struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint"`
+	RequiredBy string `json:"required_by,omitempty"`
+	Error      string `json:"error"`
+}
+
+type BundleManifestDTO struct {
+	FormatVersion int                   `json:"format_version"`
+	CreatedAt     time.Time             `json:"created_at"`
+	Roots         []string              `json:"roots"`
+	Packages      []BundlePackageDTO    `json:"packages"`
+	Unresolved    []BundleUnresolvedDTO `json:"unresolved"`
+}
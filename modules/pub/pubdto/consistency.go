@@ -0,0 +1,35 @@
+package pubdto
+
+import "time"
+
+type ConsistencyCheckDTO struct {
+	DeleteOrphans bool
+	MarkBroken    bool
+	// orphans younger than this are reported but never deleted, since an upload in progress
+	// writes the archive before its `pub_versions` row. versions younger than this aren't marked broken either,
+	// their archive may not be promoted yet
+	OrphanMinAge time.Duration
+}
+
+type ConsistencyItemDTO struct {
+	Key          string     `json:"key"`
+	PackageName  string     `json:"package_name,omitempty"`
+	Version      string     `json:"version,omitempty"`
+	Size         int64      `json:"size,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+}
+
+type ConsistencyReportDTO struct {
+	CheckedAt        time.Time            `json:"checked_at"`
+	VersionCount     int                  `json:"version_count"`
+	ObjectCount      int                  `json:"object_count"`
+	MissingArchives  []ConsistencyItemDTO `json:"missing_archives"`
+	OrphanArchives   []ConsistencyItemDTO `json:"orphan_archives"`
+	DeletedOrphans   int                  `json:"deleted_orphans"`
+	MarkedBroken     int                  `json:"marked_broken"`
+	RestoredVersions int                  `json:"restored_versions"`
+}
+
+func (report *ConsistencyReportDTO) IsConsistent() bool {
+	return len(report.MissingArchives) == 0 && len(report.OrphanArchives) == 0
+}
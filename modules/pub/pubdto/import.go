@@ -0,0 +1,52 @@
+package pubdto
+
+const (
+	ImportSourceHosted    = "hosted"
+	ImportSourceDirectory = "directory"
+
+	ImportStatusImported = "imported"
+	ImportStatusSkipped  = "skipped"
+	ImportStatusFailed   = "failed"
+)
+
+type ImportDTO struct {
+	Source string `json:"source" validate:"required,oneof=hosted directory"`
+	// base url of the hosted repository, e.g. `https://pub.dev`
+	Url   string `json:"url" validate:"required_if=Source hosted,omitempty,url"`
+	Token string `json:"token"`
+	// packages to import from the hosted repository, every package listed by `/api/package-names` when empty
+	Packages []string `json:"packages"`
+	// directory of `.tar.gz` archives, or a `.pub-cache/hosted` tree of unpacked packages
+	Path string `json:"path" validate:"required_if=Source directory"`
+	// only report what would be imported
+	DryRun bool `json:"dry_run"`
+	// refuse the archive urls on another host than `url`, set by the admin endpoint
+	SameOriginArchives bool `json:"-"`
+}
+
+type ImportResultDTO struct {
+	PackageName string `json:"package_name"`
+	Version     string `json:"version"`
+	Source      string `json:"source"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+type ImportReportDTO struct {
+	Imported int               `json:"imported"`
+	Skipped  int               `json:"skipped"`
+	Failed   int               `json:"failed"`
+	Results  []ImportResultDTO `json:"results"`
+}
+
+func (report *ImportReportDTO) Add(result ImportResultDTO) {
+	switch result.Status {
+	case ImportStatusImported:
+		report.Imported++
+	case ImportStatusSkipped:
+		report.Skipped++
+	case ImportStatusFailed:
+		report.Failed++
+	}
+	report.Results = append(report.Results, result)
+}
@@ -0,0 +1,22 @@
+package pubdto
+
+import "time"
+
+type MirrorDTO struct {
+	// directory the static tree is written to
+	OutputPath string
+	// url the tree will be served from, archive urls in the package documents point there
+	BaseUrl        string
+	IncludePrivate bool
+	// remove documents and archives of packages / versions that are no longer in the repository
+	Prune bool
+}
+
+type MirrorReportDTO struct {
+	GeneratedAt     time.Time `json:"generated_at"`
+	Packages        int       `json:"packages"`
+	Versions        int       `json:"versions"`
+	CopiedArchives  int       `json:"copied_archives"`
+	SkippedArchives int       `json:"skipped_archives"`
+	PrunedFiles     int       `json:"pruned_files"`
+}
@@ -16,6 +16,7 @@ type PubVersionModel struct {
 	VersionNumberMinor uint64               `json:"version_number_minor" gorm:"not null;"`
 	VersionNumberPatch uint64               `json:"version_number_patch" gorm:"not null;"`
 	Prerelease         bool                 `json:"prerelease" gorm:"not null;default:false;"`
+	Broken             bool                 `json:"broken" gorm:"not null;default:false;"`
 	Pubspec            datatypes.JSON       `json:"pubspec" gorm:"not null;default:'{}';"`
 	UploaderID         *uuid.UUID           `json:"user_id" gorm:"type:uuid;nullable;"`
 	Uploader           *usermodel.UserModel `gorm:"foreignKey:UploaderID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
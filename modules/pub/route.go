@@ -14,22 +14,50 @@ const (
 	queryPackageUpdatePath = queryPackageListPath + "/:package"
 	queryVersionListPath   = queryPackageUpdatePath + "/versions"
 	queryVersionDetailPath = queryVersionListPath + "/:version"
+
+	queryPackageRestorePath     = queryPackageUpdatePath + "/restore"
+	queryPackagePurgePath       = queryPackageUpdatePath + "/purge"
+	queryVersionRestorePath     = queryVersionDetailPath + "/restore"
+	queryVersionPurgePath       = queryVersionDetailPath + "/purge"
+	queryDeletedPackageListPath = "v1/pub/query/deleted-packages"
+	queryDeletedVersionListPath = queryPackageUpdatePath + "/deleted-versions"
+	queryImportPath             = "v1/pub/query/import"
 )
 
 func (module *PubModule) registerRoutes() {
-	module.app.Get(getUploadUrlPath, module.jwtService.GetHandler(), module.middleware.CanAccess,
-		module.middleware.CanWrite, module.controller.handleGetUploadUrl)
-	module.app.Post(uploadUrlPath, module.jwtService.GetHandler(), module.middleware.CanAccess,
-		module.middleware.CanWrite, module.controller.handleDoUpload)
-	module.app.Get(finishUploadUrlPath, module.jwtService.GetHandler(), module.middleware.CanAccess,
-		module.middleware.CanWrite, module.controller.handleFinishUpload)
-	module.app.Get(versionListPath, module.jwtService.GetOptionalHandler(), module.controller.handleVersionList)
-	module.app.Get(versionDetailPath, module.jwtService.GetOptionalHandler(), module.controller.handleVersionDetail)
-	module.app.Get(downloadPath, module.jwtService.GetOptionalHandler(), module.controller.handleDownloadPath)
+	// after the token handlers, the limits are per token when there is a valid one
+	pubRateLimit := module.rateLimit.Pub()
+	queryRateLimit := module.rateLimit.Query()
+
+	module.app.Get(getUploadUrlPath, module.middleware.GetHandler(), pubRateLimit, module.middleware.CanPublish, module.controller.handleGetUploadUrl)
+	module.app.Post(uploadUrlPath, module.middleware.GetHandler(), pubRateLimit, module.middleware.CanPublish, module.controller.handleDoUpload)
+	module.app.Get(finishUploadUrlPath, module.middleware.GetHandler(), pubRateLimit, module.middleware.CanPublish, module.controller.handleFinishUpload)
+	module.app.Get(versionListPath, module.middleware.GetOptionalHandler(), pubRateLimit, module.controller.handleVersionList)
+	module.app.Get(versionDetailPath, module.middleware.GetOptionalHandler(), pubRateLimit, module.controller.handleVersionDetail)
+	module.app.Get(downloadPath, module.middleware.GetOptionalHandler(), pubRateLimit, module.controller.handleDownloadPath)
 
-	module.app.Get(queryPackageListPath, module.jwtService.GetOptionalHandler(), module.controller.handleQueryPackageList)
-	module.app.Put(queryPackageUpdatePath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess,
+	module.app.Get(queryPackageListPath, module.jwtService.GetOptionalHandler(), queryRateLimit, module.controller.handleQueryPackageList)
+	module.app.Put(queryPackageUpdatePath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
 		module.userMiddleware.IsAdmin, module.controller.handleQueryPackageUpdate)
-	module.app.Get(queryVersionListPath, module.jwtService.GetOptionalHandler(), module.controller.handleQueryVersionList)
-	module.app.Get(queryVersionDetailPath, module.jwtService.GetOptionalHandler(), module.controller.handleQueryVersionDetail)
+	module.app.Get(queryVersionListPath, module.jwtService.GetOptionalHandler(), queryRateLimit, module.controller.handleQueryVersionList)
+	module.app.Get(queryVersionDetailPath, module.jwtService.GetOptionalHandler(), queryRateLimit, module.controller.handleQueryVersionDetail)
+
+	module.app.Delete(queryPackageUpdatePath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryPackageDelete)
+	module.app.Delete(queryVersionDetailPath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryVersionDelete)
+	module.app.Get(queryDeletedPackageListPath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryDeletedPackageList)
+	module.app.Get(queryDeletedVersionListPath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryDeletedVersionList)
+	module.app.Post(queryPackageRestorePath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryPackageRestore)
+	module.app.Post(queryVersionRestorePath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryVersionRestore)
+	module.app.Delete(queryPackagePurgePath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryPackagePurge)
+	module.app.Delete(queryVersionPurgePath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryVersionPurge)
+	module.app.Post(queryImportPath, module.jwtService.GetHandler(), queryRateLimit, module.userMiddleware.CanAccess,
+		module.userMiddleware.IsAdmin, module.controller.handleQueryImport)
 }
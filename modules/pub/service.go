@@ -5,20 +5,25 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"path/filepath"
 	"private-pub-repo/modules/app/appmodel"
-	"private-pub-repo/modules/config"
 	"private-pub-repo/modules/db"
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/monitor"
 	"private-pub-repo/modules/pub/pubdto"
 	"private-pub-repo/modules/pub/pubmodel"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/storage"
+	"private-pub-repo/utils"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/gofiber/fiber/v2"
@@ -28,41 +33,77 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-const filePathFormat = "pub/packages/%s/versions/%s.tar.gz"
+const (
+	filePathFormat    = "pub/packages/%s/versions/%s.tar.gz"
+	stagingPathFormat = "pub/staging/%s.tar.gz"
+)
+
+var ErrVersionExists = errors.New("version already exists")
+
+// ErrPackageUnavailable = not found, but the package is known here: deleted, or without published version.
+// unlike `fiber.ErrNotFound` it isn't resolved from the upstream, which could serve another package of the same name
+var ErrPackageUnavailable = fiber.NewError(fiber.StatusNotFound, "Not Found")
+
+// dart package names, they end up in storage keys & mirror paths
+var packageNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
 
 type PubService interface {
 	Init(db db.DbService)
 	VersionList(context context.Context, packageName string, baseUrl string, publicOnly bool) (*pubdto.PubPackageDTO, error)
 	VersionDetail(context context.Context, packageName string, version string, baseUrl string, publicOnly bool) (*pubdto.PubVersionDTO, error)
 	GetUpstreamUrl(context context.Context, path string) *string
-	UploadVersion(context context.Context, file *multipart.FileHeader, userId uuid.UUID) error
+	// UploadVersion = publish the archive, onlyPackage refuses the archives of other packages when not nil
+	UploadVersion(context context.Context, file *multipart.FileHeader, userId *uuid.UUID, onlyPackage *string) error
 	GetDownloadUrl(context context.Context, packageName string, version string, baseUrl string, publicOnly bool) (*string, error)
 	QueryPackageList(context context.Context, req *appmodel.GetListRequest, publicOnly bool) (*appmodel.PaginationResponseList, error)
 	QueryPackageUpdate(context context.Context, packageName string, updateDTO *pubdto.UpdatePubPackageDTO, publicOnly bool) (*pubmodel.PubPackageModel, error)
 	QueryVersionList(context context.Context, packageName string, req *appmodel.GetListRequest, publicOnly bool) (*appmodel.PaginationResponseList, error)
 	QueryVersionDetail(context context.Context, packageName string, version string, publicOnly bool) (*pubmodel.PubVersionModel, error)
+	CheckConsistency(context context.Context, options *pubdto.ConsistencyCheckDTO) (*pubdto.ConsistencyReportDTO, error)
+	DeletePackage(context context.Context, packageName string) error
+	DeleteVersion(context context.Context, packageName string, version string) error
+	RestorePackage(context context.Context, packageName string) (*pubmodel.PubPackageModel, error)
+	RestoreVersion(context context.Context, packageName string, version string) (*pubmodel.PubVersionModel, error)
+	PurgePackage(context context.Context, packageName string) error
+	PurgeVersion(context context.Context, packageName string, version string) error
+	QueryDeletedPackageList(context context.Context, req *appmodel.GetListRequest) (*appmodel.PaginationResponseList, error)
+	QueryDeletedVersionList(context context.Context, packageName string, req *appmodel.GetListRequest) (*appmodel.PaginationResponseList, error)
+	Import(context context.Context, options *pubdto.ImportDTO, userId *uuid.UUID) (*pubdto.ImportReportDTO, error)
+	ExportBundle(context context.Context, writer io.Writer, options *pubdto.BundleExportDTO) (*pubdto.BundleManifestDTO, error)
+	ImportBundle(context context.Context, reader io.Reader, dryRun bool, userId *uuid.UUID) (*pubdto.ImportReportDTO, error)
+	GenerateMirror(context context.Context, options *pubdto.MirrorDTO) (*pubdto.MirrorReportDTO, error)
 }
 
 type pubServiceImpl struct {
 	monitorService monitor.MonitorService
 	jwtService     jwt.JwtService
+	dbService      db.DbService
 	db             *gorm.DB
-	upstreamUrl    string
 	storage        storage.StorageService
+	settingService setting.SettingService
+	// hostedClient = requests of the imports to other pub repositories
+	hostedClient *http.Client
 }
 
-func NewPubService(jwtService jwt.JwtService, monitorService monitor.MonitorService, config *config.ConfigModule, storage storage.StorageService) PubService {
+func NewPubService(jwtService jwt.JwtService, monitorService monitor.MonitorService, storage storage.StorageService, settingService setting.SettingService) PubService {
 	return &pubServiceImpl{
 		jwtService:     jwtService,
 		monitorService: monitorService,
-		upstreamUrl:    config.Getenv("UPSTREAM_URL", ""),
 		storage:        storage,
+		settingService: settingService,
+		hostedClient:   &http.Client{Timeout: hostedRequestTimeout},
 	}
 }
 
+// upstreamUrl is a runtime setting, read on every use so changes apply right away
+func (service *pubServiceImpl) upstreamUrl() string {
+	return service.settingService.String(setting.UpstreamUrl)
+}
+
 // impl `PubService` start
 
 func (service *pubServiceImpl) Init(db db.DbService) {
+	service.dbService = db
 	service.db = db.Default()
 }
 
@@ -72,31 +113,42 @@ func (service *pubServiceImpl) VersionList(context context.Context, packageName
 	pubPackage := pubmodel.PubPackageModel{}
 	pubVersions := []pubmodel.PubVersionModel{}
 
-	result := service.db.WithContext(spanContext).First(&pubPackage, "name = ?", packageName)
+	// a package without (unbroken) versions is not found either, so a replica that lags behind falls back to the primary
+	err := service.dbService.Read(spanContext, func(tx *gorm.DB) error {
+		if err := tx.First(&pubPackage, "name = ?", packageName).Error; err != nil {
+			return err
+		}
 
-	if result.Error != nil {
-		return nil, fiber.ErrNotFound
+		tx.Model(pubVersions).
+			Select("package_name", "version", "pubspec").
+			Where("package_name = ?", packageName).
+			Where("broken = ?", false).
+			Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+				{Column: clause.Column{Name: "version_number_patch"}},
+				{Column: clause.Column{Name: "version_number_minor"}},
+				{Column: clause.Column{Name: "version_number_major"}},
+			}}).Find(&pubVersions)
+
+		if len(pubVersions) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+
+	if pubPackage.Name == "" {
+		return nil, service.packageNotFound(spanContext, packageName, publicOnly)
 	}
 
 	if *pubPackage.Private && publicOnly {
 		return nil, fiber.ErrForbidden
 	}
 
-	service.db.WithContext(spanContext).Model(pubVersions).
-		Select("package_name", "version", "pubspec").
-		Where("package_name = ?", packageName).
-		Order(clause.OrderBy{Columns: []clause.OrderByColumn{
-			{Column: clause.Column{Name: "version_number_patch"}},
-			{Column: clause.Column{Name: "version_number_minor"}},
-			{Column: clause.Column{Name: "version_number_major"}},
-		}}).Find(&pubVersions)
-
-	if len(pubVersions) > 0 {
-		pubDTO := pubdto.MapPubVersionsToPackageDTO(pubVersions, baseUrl)
-		return &pubDTO, nil
+	if err != nil {
+		return nil, ErrPackageUnavailable
 	}
 
-	return nil, fiber.ErrNotFound
+	pubDTO := pubdto.MapPubVersionsToPackageDTO(pubVersions, baseUrl)
+	return &pubDTO, nil
 }
 
 func (service *pubServiceImpl) VersionDetail(context context.Context, packageName string, version string, baseUrl string, publicOnly bool) (*pubdto.PubVersionDTO, error) {
@@ -105,24 +157,29 @@ func (service *pubServiceImpl) VersionDetail(context context.Context, packageNam
 	pubPackage := pubmodel.PubPackageModel{}
 	pubVersion := pubmodel.PubVersionModel{}
 
-	result := service.db.WithContext(spanContext).First(&pubPackage, "name = ?", packageName)
+	err := service.dbService.Read(spanContext, func(tx *gorm.DB) error {
+		if err := tx.First(&pubPackage, "name = ?", packageName).Error; err != nil {
+			return err
+		}
 
-	if result.Error != nil {
-		return nil, fiber.ErrNotFound
+		return tx.Model(pubVersion).
+			Select("package_name", "version", "pubspec").
+			Where("package_name = ?", packageName).
+			Where("version = ?", version).
+			Where("broken = ?", false).
+			First(&pubVersion).Error
+	})
+
+	if pubPackage.Name == "" {
+		return nil, service.packageNotFound(spanContext, packageName, publicOnly)
 	}
 
 	if *pubPackage.Private && publicOnly {
 		return nil, fiber.ErrForbidden
 	}
 
-	result = service.db.WithContext(spanContext).Model(pubVersion).
-		Select("package_name", "version", "pubspec").
-		Where("package_name = ?", packageName).
-		Where("version = ?", version).
-		First(&pubVersion)
-
-	if result.Error != nil {
-		return nil, fiber.ErrNotFound
+	if err != nil {
+		return nil, ErrPackageUnavailable
 	}
 
 	pubDTO := pubdto.MapPubVersionToDTO(&pubVersion, baseUrl)
@@ -130,29 +187,78 @@ func (service *pubServiceImpl) VersionDetail(context context.Context, packageNam
 	return &pubDTO, nil
 }
 
+// packageNotFound = the error of a package not found by the pub api, `ErrPackageUnavailable` when it is only deleted
+func (service *pubServiceImpl) packageNotFound(ctx context.Context, packageName string, publicOnly bool) error {
+	pubPackage := pubmodel.PubPackageModel{}
+	err := service.db.WithContext(ctx).Unscoped().Select("name", "private").
+		Where("name = ?", packageName).
+		Limit(1).Find(&pubPackage).Error
+
+	// without an answer the upstream isn't asked either
+	if err != nil {
+		return ErrPackageUnavailable
+	}
+
+	if pubPackage.Name == "" {
+		return fiber.ErrNotFound
+	}
+
+	if *pubPackage.Private && publicOnly {
+		return fiber.ErrForbidden
+	}
+
+	return ErrPackageUnavailable
+}
+
 func (service *pubServiceImpl) GetUpstreamUrl(context context.Context, path string) *string {
 	_, span := service.monitorService.StartTraceSpan(context, "PubService.GetUpstreamUrl", map[string]interface{}{})
 	defer span.End()
-	if service.upstreamUrl == "" {
+	upstreamUrl := service.upstreamUrl()
+	if upstreamUrl == "" {
 		return nil
 	}
-	newUrl := service.upstreamUrl + path
+	newUrl := upstreamUrl + path
 	return &newUrl
 }
 
-func (service *pubServiceImpl) UploadVersion(context context.Context, file *multipart.FileHeader, userId uuid.UUID) error {
-	_, span := service.monitorService.StartTraceSpan(context, "PubService.UploadVersion", map[string]interface{}{})
+func (service *pubServiceImpl) UploadVersion(context context.Context, file *multipart.FileHeader, userId *uuid.UUID, onlyPackage *string) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.UploadVersion", map[string]interface{}{})
 	defer span.End()
+
+	if limit := service.settingService.Int(setting.UploadSizeLimit); file.Size > int64(limit)*1024*1024 {
+		err := fmt.Errorf("package archive is larger than the upload size limit of %d MB", limit)
+		service.monitorService.RecordUpload(spanContext, file.Size, err)
+		return err
+	}
+
+	_, err := service.publishArchive(spanContext, func() (io.ReadCloser, error) {
+		return file.Open()
+	}, userId, onlyPackage, nil)
+	service.monitorService.RecordUpload(spanContext, file.Size, err)
+
+	return err
+}
+
+// publishArchive opens the archive twice: once to read its content, once to store it.
+// publishedAt keeps the original publish time of imported versions, nil means now
+func (service *pubServiceImpl) publishArchive(ctx context.Context, openArchive func() (io.ReadCloser, error), userId *uuid.UUID, onlyPackage *string, publishedAt *time.Time) (*pubmodel.PubVersionModel, error) {
 	tarPackageInfo := pubdto.TarPackageInfoDTO{}
 
+	reader, err := openArchive()
+
+	if err != nil {
+		return nil, err
+	}
+
 	// Loop through each entry in the tar archive
-	hasPubspec, shouldReturn, returnValue := service.readArchiveContent(file, &tarPackageInfo)
+	hasPubspec, shouldReturn, returnValue := service.readArchiveContent(reader, &tarPackageInfo)
+	reader.Close()
 	if shouldReturn {
-		return returnValue
+		return nil, returnValue
 	}
 
 	if !hasPubspec {
-		return fmt.Errorf("did not find any pubspec.yaml file in upload, aborting")
+		return nil, fmt.Errorf("did not find any pubspec.yaml file in upload, aborting")
 	}
 
 	parseOk := true
@@ -168,28 +274,37 @@ func (service *pubServiceImpl) UploadVersion(context context.Context, file *mult
 	semverObj, errSemver := semver.NewVersion(version)
 
 	if !parseOk || err != nil || errSemver != nil {
-		return fmt.Errorf("invalid pubspec.yaml")
+		return nil, fmt.Errorf("invalid pubspec.yaml")
 	}
 
-	result := service.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "name"}},
-		DoNothing: true,
-	}).Create(&pubmodel.PubPackageModel{Name: packageName})
+	if !packageNamePattern.MatchString(packageName) {
+		return nil, fmt.Errorf("invalid package name %q, only lowercase letters, digits and underscores are allowed", packageName)
+	}
 
-	if result.Error != nil {
-		return result.Error
+	if onlyPackage != nil && packageName != *onlyPackage {
+		return nil, fmt.Errorf("the token can only publish package %s, not %s", *onlyPackage, packageName)
 	}
 
-	err = service.storage.Upload(fmt.Sprintf(filePathFormat, packageName, version), file)
+	// stage the archive first, it is promoted to its final key only after the version is committed.
+	// the version is committed broken, hidden from the pub api like the versions missing their archive, until then
+	stagingKey := fmt.Sprintf(stagingPathFormat, uuid.New().String())
+
+	reader, err = openArchive()
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer reader.Close()
+
+	err = service.storage.Upload(ctx, stagingKey, reader)
+
+	if err != nil {
+		return nil, err
 	}
+	// cleanups should still run when the request that triggered them has been cancelled
+	defer service.storage.Delete(context.WithoutCancel(ctx), stagingKey)
 
-	result = service.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "package_name"}, {Name: "version"}},
-		DoUpdates: clause.AssignmentColumns([]string{"readme", "changelog", "pubspec", "uploader_id"}),
-	}).Create(&pubmodel.PubVersionModel{
+	pubVersion := pubmodel.PubVersionModel{
 		PackageName: packageName, Version: version,
 		VersionNumberMajor: semverObj.Major(),
 		VersionNumberMinor: semverObj.Minor(),
@@ -198,24 +313,114 @@ func (service *pubServiceImpl) UploadVersion(context context.Context, file *mult
 		Readme:             &tarPackageInfo.Readme,
 		Changelog:          &tarPackageInfo.Changelog,
 		Pubspec:            pubspecJson,
-		UploaderID:         &userId,
+		Broken:             true,
+		UploaderID:         userId,
+		CreatedAt:          publishedAt,
+	}
+
+	err = service.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return service.insertVersion(tx, &pubVersion)
 	})
 
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.storage.Copy(ctx, stagingKey, fmt.Sprintf(filePathFormat, packageName, version))
+
+	if err != nil {
+		// without its archive the version is unusable, so take it back instead of leaving it half-published
+		if cleanupErr := service.unpublishVersion(context.WithoutCancel(ctx), packageName, version); cleanupErr != nil {
+			service.monitorService.Logger().ErrorContext(ctx, "unpublishing version without archive failed",
+				"package", packageName, "version", version, "error", cleanupErr)
+		}
+		return nil, err
+	}
+
+	// the archive is in place, the version is published even when the request is gone by now
+	err = service.db.WithContext(context.WithoutCancel(ctx)).Model(&pubmodel.PubVersionModel{}).
+		Where("package_name = ?", packageName).
+		Where("version = ?", version).
+		Update("broken", false).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	pubVersion.Broken = false
+	return &pubVersion, nil
+}
+
+// unpublishVersion = remove a committed version, and its package when it has no other version (deleted ones included)
+func (service *pubServiceImpl) unpublishVersion(ctx context.Context, packageName string, version string) error {
+	return service.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the same lock as `insertVersion`, so a concurrent upload doesn't lose its package row
+		pubPackage := pubmodel.PubPackageModel{}
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&pubPackage, "name = ?", packageName).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().
+			Where("package_name = ?", packageName).
+			Where("version = ?", version).
+			Delete(&pubmodel.PubVersionModel{})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var count int64
+		result = tx.Unscoped().Model(&pubmodel.PubVersionModel{}).Where("package_name = ?", packageName).Count(&count)
+
+		if result.Error != nil || count > 0 {
+			return result.Error
+		}
+
+		return tx.Unscoped().Where("name = ?", packageName).Delete(&pubmodel.PubPackageModel{}).Error
+	})
+}
+
+// insertVersion must be called inside a transaction. Concurrent uploads of the same package are serialized
+// by the package row lock, and published versions are immutable, so only one of them can win.
+func (service *pubServiceImpl) insertVersion(tx *gorm.DB, pubVersion *pubmodel.PubVersionModel) error {
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(&pubmodel.PubPackageModel{Name: pubVersion.PackageName})
+
 	if result.Error != nil {
 		return result.Error
 	}
 
-	//upload tar.gz
-	return nil
-}
+	pubPackage := pubmodel.PubPackageModel{}
+	result = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pubPackage, "name = ?", pubVersion.PackageName)
 
-func (service *pubServiceImpl) readArchiveContent(file *multipart.FileHeader, tarPackageInfo *pubdto.TarPackageInfoDTO) (bool, bool, error) {
-	reader, err := file.Open()
-	if err != nil {
-		return false, true, err
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("package %s has been deleted", pubVersion.PackageName)
 	}
-	defer reader.Close()
 
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var count int64
+	result = tx.Unscoped().Model(&pubmodel.PubVersionModel{}).
+		Where("package_name = ?", pubVersion.PackageName).
+		Where("version = ?", pubVersion.Version).
+		Count(&count)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%w: version %s of package %s", ErrVersionExists, pubVersion.Version, pubVersion.PackageName)
+	}
+
+	return tx.Create(pubVersion).Error
+}
+
+func (service *pubServiceImpl) readArchiveContent(reader io.Reader, tarPackageInfo *pubdto.TarPackageInfoDTO) (bool, bool, error) {
 	// Create a Gzip reader
 	gzipReader, err := gzip.NewReader(reader)
 	if err != nil {
@@ -278,16 +483,18 @@ func (service *pubServiceImpl) readArchiveContent(file *multipart.FileHeader, ta
 }
 
 func (service *pubServiceImpl) GetDownloadUrl(context context.Context, packageName string, version string, baseUrl string, publicOnly bool) (*string, error) {
-	_, span := service.monitorService.StartTraceSpan(context, "PubService.GetDownloadUrl", map[string]interface{}{})
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubService.GetDownloadUrl", map[string]interface{}{})
 	defer span.End()
 
-	_, err := service.VersionDetail(context, packageName, version, baseUrl, publicOnly)
+	_, err := service.VersionDetail(spanContext, packageName, version, baseUrl, publicOnly)
 
 	if err != nil {
 		return nil, err
 	}
 
-	url := service.storage.GetUrl(fmt.Sprintf(filePathFormat, packageName, version))
+	url := service.storage.GetUrl(spanContext, fmt.Sprintf(filePathFormat, packageName, version))
+	// unknown packages are refused above, the label can't grow with random urls
+	service.monitorService.RecordDownloadRedirect(spanContext, packageName)
 	return &url, nil
 }
 
@@ -300,14 +507,14 @@ func (service *pubServiceImpl) QueryPackageList(
 	defer span.End()
 	var count int64
 	packages := []pubmodel.PubPackageModel{}
-	query := service.db.WithContext(spanContext).Model(packages)
+	query := service.dbService.Reader(spanContext).Model(packages)
 
 	if publicOnly {
 		query.Where("private = false")
 	}
 
 	if req.Search != "" {
-		query.Where("name ILIKE ?", "%"+req.Search+"%")
+		query.Where(utils.InsensitiveLike("name"), utils.ContainsPattern(req.Search))
 	}
 
 	var wg sync.WaitGroup
@@ -388,11 +595,11 @@ func (service *pubServiceImpl) QueryVersionList(
 
 	var count int64
 	versions := []pubmodel.PubVersionModel{}
-	query := service.db.WithContext(spanContext).Model(versions).Select("package_name", "version", "created_at", "updated_at").
+	query := service.db.WithContext(spanContext).Model(versions).Select("package_name", "version", "broken", "created_at", "updated_at").
 		Where("package_name = ?", pubPackage.Name)
 
 	if req.Search != "" {
-		query.Where("version ILIKE ?", "%"+req.Search+"%")
+		query.Where(utils.InsensitiveLike("version"), utils.ContainsPattern(req.Search))
 	}
 
 	var wg sync.WaitGroup
@@ -4,7 +4,9 @@ import (
 	"private-pub-repo/modules/app"
 	"private-pub-repo/modules/app/appmodel"
 	"private-pub-repo/modules/pubtoken/pubtokendto"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/utils"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -19,13 +21,15 @@ type pubTokenController struct {
 	service         PubTokenService
 	responseService app.ResponseService
 	validator       *validator.Validate
+	settingService  setting.SettingService
 }
 
-func newPubTokenController(service PubTokenService, responseService app.ResponseService, validator *validator.Validate) *pubTokenController {
+func newPubTokenController(service PubTokenService, responseService app.ResponseService, validator *validator.Validate, settingService setting.SettingService) *pubTokenController {
 	return &pubTokenController{
 		service:         service,
 		responseService: responseService,
 		validator:       validator,
+		settingService:  settingService,
 	}
 }
 
@@ -48,7 +52,8 @@ func (controller *pubTokenController) handleCreate(ctx *fiber.Ctx) error {
 		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
 	}
 
-	token, err := controller.service.Insert(ctx.UserContext(), request.ToModel(id, utils.IsFiberJwtCanWrite(ctx)))
+	defaultLifetime := time.Duration(controller.settingService.Int(setting.TokenLifetime)) * 24 * time.Hour
+	token, err := controller.service.Insert(ctx.UserContext(), request.ToModel(id, utils.IsFiberJwtCanWrite(ctx), defaultLifetime))
 
 	if err != nil {
 		return fiber.NewError(400, err.Error())
@@ -71,7 +76,14 @@ func (controller *pubTokenController) handleList(ctx *fiber.Ctx) error {
 		return fiber.NewError(400, err.Error())
 	}
 
-	list, err := controller.service.List(ctx.UserContext(), request, &userId)
+	filter := pubtokendto.NewListTokenDTO(&userId, ctx.Query("unused_days"))
+	err = controller.validator.Struct(filter)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	list, err := controller.service.List(ctx.UserContext(), request, filter)
 
 	if err != nil {
 		return fiber.NewError(400, err.Error())
@@ -100,7 +112,13 @@ func (controller *pubTokenController) handleDetail(ctx *fiber.Ctx) error {
 	if err != nil {
 		return fiber.NewError(400, err.Error())
 	}
-	return controller.responseService.SendSuccessDetailResponse(ctx, 200, pubToken)
+
+	usages, err := controller.service.UsageHistory(ctx.UserContext(), tokenId, &userId)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, pubtokendto.TokenDetailDTO{PubTokenModel: pubToken, Usages: usages})
 }
 
 func (controller *pubTokenController) handleUpdate(ctx *fiber.Ctx) error {
@@ -158,3 +176,111 @@ func (controller *pubTokenController) handleDelete(ctx *fiber.Ctx) error {
 }
 
 // handlers end
+
+// admin handlers start, the tokens of every user
+
+func (controller *pubTokenController) handleAdminList(ctx *fiber.Ctx) error {
+	request := appmodel.NewGetListRequest(ctx.Query("page"), ctx.Query("limit"), ctx.Query("search"))
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	filter, err := pubtokendto.NewAdminListTokenDTO(ctx.Query("user_id"), ctx.Query("write"), ctx.Query("expired"), ctx.Query("expires_days"), ctx.Query("unused_days"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	err = controller.validator.Struct(filter)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	list, err := controller.service.List(ctx.UserContext(), request, filter)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	return controller.responseService.SendSuccessResponse(ctx, 200, appmodel.PaginationResponse{
+		List: list,
+	})
+}
+
+func (controller *pubTokenController) handleAdminDetail(ctx *fiber.Ctx) error {
+	tokenId, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	pubToken, err := controller.service.Detail(ctx.UserContext(), tokenId, nil)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	usages, err := controller.service.UsageHistory(ctx.UserContext(), tokenId, nil)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, pubtokendto.TokenDetailDTO{PubTokenModel: pubToken, Usages: usages})
+}
+
+func (controller *pubTokenController) handleAdminDelete(ctx *fiber.Ctx) error {
+	tokenId, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	err = controller.service.Delete(ctx.UserContext(), tokenId, nil)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, nil)
+}
+
+func (controller *pubTokenController) handleAdminRevoke(ctx *fiber.Ctx) error {
+	request := pubtokendto.RevokeTokenDTO{}
+	err := ctx.BodyParser(&request)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	err = controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	revoked, err := controller.service.DeleteMany(ctx.UserContext(), request.IDs)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, pubtokendto.RevokeResultDTO{Revoked: revoked})
+}
+
+func (controller *pubTokenController) handleAdminRevokeUser(ctx *fiber.Ctx) error {
+	userId, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	revoked, err := controller.service.DeleteByUser(ctx.UserContext(), userId)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, pubtokendto.RevokeResultDTO{Revoked: revoked})
+}
+
+// admin handlers end
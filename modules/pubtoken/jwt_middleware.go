@@ -1,32 +1,112 @@
 package pubtoken
 
 import (
+	"errors"
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/monitor"
 	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/utils"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	jwtlib "github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+const bearerScheme = "Bearer "
+
+// refused jwt pub token, the user has to create a new one
+var errLegacyToken = fiber.NewError(401, "Unauthenticated, pub tokens of this kind are no longer accepted, create a new one")
+
 type PubTokenJwtMiddleware interface {
 	jwt.JwtMiddleware
 	CanWrite(c *fiber.Ctx) error
-	GetPubUserId(c *fiber.Ctx) uuid.UUID
+	// CanPublish = `CanAccess` & `CanWrite`, the use is counted as a publish instead of a read
+	CanPublish(c *fiber.Ctx) error
+	// GetPubUserId = user of the pub token, nil for the tokens of trusted publishing
+	GetPubUserId(c *fiber.Ctx) *uuid.UUID
+	// GetPubPackage = the only package the pub token can be used for, nil for every package
+	GetPubPackage(c *fiber.Ctx) *string
+	// GetHandler = `JwtService.GetHandler` of the pub api, also accepting the opaque tokens
+	GetHandler() fiber.Handler
+	// GetOptionalHandler = `JwtService.GetOptionalHandler` of the pub api, also accepting the opaque tokens
+	GetOptionalHandler() fiber.Handler
 }
 
 type pubTokenMiddlewareImpl struct {
 	jwtService      jwt.JwtService
 	pubTokenService PubTokenService
 	monitorService  monitor.MonitorService
+	settingService  setting.SettingService
 }
 
-func NewPubTokenJwtMiddleware(jwtService jwt.JwtService, pubTokenService PubTokenService, monitorService monitor.MonitorService) PubTokenJwtMiddleware {
+func NewPubTokenJwtMiddleware(jwtService jwt.JwtService, pubTokenService PubTokenService, monitorService monitor.MonitorService, settingService setting.SettingService) PubTokenJwtMiddleware {
 	return &pubTokenMiddlewareImpl{
 		jwtService:      jwtService,
 		pubTokenService: pubTokenService,
 		monitorService:  monitorService,
+		settingService:  settingService,
+	}
+}
+
+func bearerToken(c *fiber.Ctx) string {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) > len(bearerScheme) && strings.EqualFold(auth[:len(bearerScheme)], bearerScheme) {
+		return strings.TrimSpace(auth[len(bearerScheme):])
+	}
+	return ""
+}
+
+// claimsToken = the claims a jwt pub token of pubToken would have, so the rest of the request doesn't tell them apart
+func claimsToken(pubToken *pubtokenmodel.PubTokenModel) *jwtlib.Token {
+	return &jwtlib.Token{
+		Valid: true,
+		Claims: jwt.JwtClaim{
+			"sub": pubToken.ID.String(),
+			"iss": JwtIssuer,
+			"aud": []string{jwt.JwtAppAud},
+			"exp": pubToken.ExpiredAt.Unix(),
+		},
+	}
+}
+
+// opaqueHandler = authenticate the opaque tokens, the others (jwt or none) are left to jwtHandler
+func (service *pubTokenMiddlewareImpl) opaqueHandler(jwtHandler fiber.Handler, optional bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := bearerToken(c)
+		if !IsOpaqueToken(token) {
+			return jwtHandler(c)
+		}
+
+		pubToken, err := service.pubTokenService.FindByToken(c.UserContext(), token)
+
+		reason := ""
+		switch {
+		case errors.Is(err, errMalformedToken):
+			reason = "invalid"
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			reason = "revoked"
+		case err != nil:
+			return err
+		case pubToken.ExpiredAt.Before(time.Now()):
+			reason = "expired"
+		}
+
+		if reason != "" {
+			service.monitorService.RecordAuthFailure(c.UserContext(), reason)
+			if optional {
+				return c.Next()
+			}
+			service.monitorService.Logger().InfoContext(c.UserContext(), "token refused", "reason", reason)
+			return fiber.NewError(401, "Unauthenticated")
+		}
+
+		c.Locals("user", claimsToken(pubToken))
+		c.Locals("pub_token", pubToken)
+		return c.Next()
 	}
 }
 
@@ -40,13 +120,56 @@ func (service *pubTokenMiddlewareImpl) CanWrite(c *fiber.Ctx) error {
 	return c.Next()
 }
 
-func (service *pubTokenMiddlewareImpl) GetPubUserId(c *fiber.Ctx) uuid.UUID {
-	return c.Locals("pub_user_id").(uuid.UUID)
+func (service *pubTokenMiddlewareImpl) CanPublish(c *fiber.Ctx) error {
+	if err := service.hasAccess(c, pubtokenmodel.UsagePublish); err != nil {
+		return err
+	}
+
+	return service.CanWrite(c)
+}
+
+func (service *pubTokenMiddlewareImpl) GetPubUserId(c *fiber.Ctx) *uuid.UUID {
+	userId, _ := c.Locals("pub_user_id").(*uuid.UUID)
+	return userId
+}
+
+func (service *pubTokenMiddlewareImpl) GetPubPackage(c *fiber.Ctx) *string {
+	packageName, _ := c.Locals("pub_package").(*string)
+	return packageName
+}
+
+func (service *pubTokenMiddlewareImpl) GetHandler() fiber.Handler {
+	return service.opaqueHandler(service.jwtService.GetHandler(), false)
+}
+
+func (service *pubTokenMiddlewareImpl) GetOptionalHandler() fiber.Handler {
+	return service.opaqueHandler(service.jwtService.GetOptionalHandler(), true)
 }
 
 // impl `PubTokenJwtMiddleware` end
 
-func (service *pubTokenMiddlewareImpl) HasAccess(c *fiber.Ctx) error {
+// legacyToken = token of a jwt pub token, only the tokens created before the opaque ones are still accepted
+func (service *pubTokenMiddlewareImpl) legacyToken(c *fiber.Ctx, pubTokenId uuid.UUID) (*pubtokenmodel.PubTokenModel, error) {
+	pubToken, err := service.pubTokenService.Detail(c.UserContext(), pubTokenId, nil)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// deleted since it was signed
+		service.monitorService.RecordAuthFailure(c.UserContext(), "revoked")
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	if pubToken.TokenHash != nil || !service.settingService.Bool(setting.LegacyPubTokens) {
+		service.monitorService.RecordAuthFailure(c.UserContext(), "legacy")
+		return nil, errLegacyToken
+	}
+
+	return pubToken, nil
+}
+
+// hasAccess = the request has a valid pub token, its use for operation is counted
+func (service *pubTokenMiddlewareImpl) hasAccess(c *fiber.Ctx, operation string) error {
 	err := service.jwtService.CanAccess(c, JwtIssuer)
 
 	if err == nil {
@@ -56,18 +179,39 @@ func (service *pubTokenMiddlewareImpl) HasAccess(c *fiber.Ctx) error {
 			service.monitorService.SetCurrentSpanAttributes(c.UserContext(), map[string]interface{}{"pubtoken_id": pubTokenIdString})
 		}
 
-		var pubTokenId uuid.UUID
-		pubTokenId, err = uuid.Parse(pubTokenIdString)
+		pubToken, ok := c.Locals("pub_token").(*pubtokenmodel.PubTokenModel)
 
-		if err == nil {
-			var pubToken *pubtokenmodel.PubTokenModel
-			pubToken, err = service.pubTokenService.Detail(c.UserContext(), pubTokenId, nil)
+		if err == nil && !ok {
+			var pubTokenId uuid.UUID
+			pubTokenId, err = uuid.Parse(pubTokenIdString)
 
 			if err == nil {
-				c.Locals("write", *pubToken.Write)
-				c.Locals("pub_user_id", *pubToken.UserID)
+				pubToken, err = service.legacyToken(c, pubTokenId)
+			}
+		}
+
+		// the routes of other packages are refused, the package of an upload is checked once its pubspec is read
+		if err == nil && pubToken.Package != nil && c.Params("package") != "" && c.Params("package") != *pubToken.Package {
+			service.monitorService.RecordAuthFailure(c.UserContext(), "package_scope")
+			err = fiber.NewError(403, "Forbidden, the token is limited to package "+*pubToken.Package)
+		}
+
+		if err == nil {
+			c.Locals("write", *pubToken.Write)
+			c.Locals("pub_user_id", pubToken.UserID)
+			c.Locals("pub_package", pubToken.Package)
+			service.pubTokenService.RecordUsage(pubToken.ID, operation, c.IP(), c.Get(fiber.HeaderUserAgent))
+			attributes := map[string]interface{}{"token_id": pubTokenIdString}
+			if pubToken.UserID != nil {
+				attributes["user_id"] = pubToken.UserID.String()
+			}
+			if pubToken.Package != nil {
+				attributes["token_package"] = *pubToken.Package
 			}
+			service.monitorService.AddLogAttributes(c.UserContext(), attributes)
 		}
+	} else {
+		service.monitorService.RecordAuthFailure(c.UserContext(), "wrong_issuer")
 	}
 
 	return err
@@ -75,6 +219,10 @@ func (service *pubTokenMiddlewareImpl) HasAccess(c *fiber.Ctx) error {
 
 // impl `jwt.JwtMiddleware` start
 
+func (service *pubTokenMiddlewareImpl) HasAccess(c *fiber.Ctx) error {
+	return service.hasAccess(c, pubtokenmodel.UsageRead)
+}
+
 func (service *pubTokenMiddlewareImpl) CanAccess(c *fiber.Ctx) error {
 	err := service.HasAccess(c)
 
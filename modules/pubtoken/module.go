@@ -1,14 +1,19 @@
 package pubtoken
 
 import (
+	"context"
 	"private-pub-repo/base"
 	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/config"
 	"private-pub-repo/modules/db"
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/monitor"
 	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/user"
 
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/fx"
 )
@@ -21,39 +26,90 @@ type PubTokenModule struct {
 	jwtService     jwt.JwtService
 	db             db.DbService
 	app            *fiber.App
+	config         config.ConfigService
+	monitorService monitor.MonitorService
+	stopFlush      chan struct{}
+	flushDone      chan struct{}
 }
 
-func NewModule(service PubTokenService, middleware PubTokenJwtMiddleware, controller *pubTokenController, jwtService jwt.JwtService, db db.DbService, userMiddleware user.UserJwtMiddleware, app *fiber.App) *PubTokenModule {
-	return &PubTokenModule{Service: service, Middleware: middleware, userMiddleware: userMiddleware, jwtService: jwtService, controller: controller, db: db, app: app}
+func NewModule(service PubTokenService, middleware PubTokenJwtMiddleware, controller *pubTokenController, jwtService jwt.JwtService, db db.DbService, userMiddleware user.UserJwtMiddleware, app *fiber.App, config config.ConfigService, monitorService monitor.MonitorService) *PubTokenModule {
+	return &PubTokenModule{Service: service, Middleware: middleware, userMiddleware: userMiddleware, jwtService: jwtService, controller: controller, db: db, app: app, config: config, monitorService: monitorService}
 }
 
 func fxRegister(lifeCycle fx.Lifecycle, module *PubTokenModule) {
 	base.FxRegister(module, lifeCycle)
 }
 
-func SetupModule(app *app.AppModule, db *db.DbModule, user *user.UserModule, jwt *jwt.JwtModule, monitor *monitor.MonitorModule) *PubTokenModule {
+func SetupModule(app *app.AppModule, db *db.DbModule, user *user.UserModule, jwt *jwt.JwtModule, monitor *monitor.MonitorModule, setting *setting.SettingModule, config *config.ConfigModule) *PubTokenModule {
 	service := NewPubTokenService(jwt, monitor.Service)
-	middleware := NewPubTokenJwtMiddleware(jwt, service, monitor.Service)
-	controller := newPubTokenController(service, app.ResponseService, app.Validator)
-	return NewModule(service, middleware, controller, jwt, db, user.Middleware, app.App)
+	middleware := NewPubTokenJwtMiddleware(jwt, service, monitor.Service, setting.Service)
+	controller := newPubTokenController(service, app.ResponseService, app.Validator, setting.Service)
+	return NewModule(service, middleware, controller, jwt, db, user.Middleware, app.App, config, monitor.Service)
 }
 
 var FxModule = fx.Module("PubToken", fx.Provide(NewPubTokenService), fx.Provide(NewPubTokenJwtMiddleware), fx.Provide(newPubTokenController), fx.Provide(NewModule), fx.Invoke(fxRegister))
 
+// startFlush writes the token usage counted in memory, and drops the history & package tokens past its retention
+func (module *PubTokenModule) startFlush() {
+	module.stopFlush = make(chan struct{})
+	module.flushDone = make(chan struct{})
+
+	go func(stop chan struct{}, done chan struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(time.Duration(module.config.Config().PubToken.UsageFlush) * time.Second)
+		defer ticker.Stop()
+		var lastCleanup time.Time
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := module.Service.FlushUsage(context.Background()); err != nil {
+					module.monitorService.Logger().Error("token usage flush failed", "error", err)
+				}
+
+				if time.Since(lastCleanup) > time.Hour {
+					lastCleanup = time.Now()
+					retention := module.config.Config().PubToken.UsageRetention
+					if err := module.Service.DeleteUsageBefore(context.Background(), time.Now().AddDate(0, 0, -retention)); err != nil {
+						module.monitorService.Logger().Error("token usage cleanup failed", "error", err)
+					}
+					// the short lived tokens of trusted publishing, kept as long as their usage
+					if err := module.Service.DeletePackageTokensBefore(context.Background(), time.Now().AddDate(0, 0, -retention)); err != nil {
+						module.monitorService.Logger().Error("expired package token cleanup failed", "error", err)
+					}
+				}
+			case <-stop:
+				// the uses of the last seconds
+				if err := module.Service.FlushUsage(context.Background()); err != nil {
+					module.monitorService.Logger().Error("token usage flush failed", "error", err)
+				}
+				return
+			}
+		}
+	}(module.stopFlush, module.flushDone)
+}
+
 // implements `BaseModule` of `base/module.go` start
 
 func (module *PubTokenModule) OnStart() error {
 	if module.db.AutoMigrate() {
-		module.db.Default().AutoMigrate(&pubtokenmodel.PubTokenModel{})
+		module.db.Default().AutoMigrate(&pubtokenmodel.PubTokenModel{}, &pubtokenmodel.PubTokenUsageModel{})
 	}
 
 	//run seeder
 	module.Service.Init(module.db)
 	module.registerRoutes()
+	module.startFlush()
 	return nil
 }
 
 func (module *PubTokenModule) OnStop() error {
+	if module.stopFlush != nil {
+		close(module.stopFlush)
+		<-module.flushDone
+		module.stopFlush = nil
+	}
 	return nil
 }
 
@@ -10,11 +10,15 @@ import (
 type CreateTokenDTO struct {
 	Remarks   string `json:"remarks" validate:"required,min=1"`
 	Write     bool   `json:"write" validate:"boolean"`
-	ExpiredAt string `json:"expired_at" validate:"required,datetime=2006-01-02"`
+	ExpiredAt string `json:"expired_at" validate:"omitempty,datetime=2006-01-02"`
 }
 
-func (dto *CreateTokenDTO) ToModel(userId uuid.UUID, canWrite bool) *pubtokenmodel.PubTokenModel {
-	expiredAt, _ := time.Parse("2006-01-02", dto.ExpiredAt)
+// ToModel expires the token after defaultLifetime when no expiry date is given
+func (dto *CreateTokenDTO) ToModel(userId uuid.UUID, canWrite bool, defaultLifetime time.Duration) *pubtokenmodel.PubTokenModel {
+	expiredAt, err := time.Parse("2006-01-02", dto.ExpiredAt)
+	if err != nil {
+		expiredAt = time.Now().Add(defaultLifetime)
+	}
 	expiredAt = time.Date(expiredAt.Year(), expiredAt.Month(), expiredAt.Day(), 23, 59, 59, 0, expiredAt.Location())
 	write := canWrite && dto.Write
 
@@ -0,0 +1,9 @@
+package pubtokendto
+
+import "private-pub-repo/modules/pubtoken/pubtokenmodel"
+
+// TokenDetailDTO = the token with its usage history
+type TokenDetailDTO struct {
+	*pubtokenmodel.PubTokenModel
+	Usages []pubtokenmodel.PubTokenUsageModel `json:"usages"`
+}
@@ -0,0 +1,61 @@
+package pubtokendto
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ListTokenDTO = filters of the token list
+type ListTokenDTO struct {
+	// nil = the tokens of every user, admin only
+	UserID *uuid.UUID `json:"user_id"`
+	Write  *bool      `json:"write"`
+	// true = only the expired tokens, false = only the usable ones
+	Expired *bool `json:"expired"`
+	// expiring within that many days, the expired ones excluded
+	ExpiresDays int `json:"expires_days" validate:"min=0"`
+	// not used for that many days, the never used tokens count from their creation
+	UnusedDays int `json:"unused_days" validate:"min=0"`
+}
+
+func NewListTokenDTO(userId *uuid.UUID, unusedDays string) *ListTokenDTO {
+	unusedDaysInt, _ := strconv.Atoi(unusedDays)
+
+	return &ListTokenDTO{
+		UserID:     userId,
+		UnusedDays: unusedDaysInt,
+	}
+}
+
+// NewAdminListTokenDTO = filters of the token list across users, the empty ones are not applied
+func NewAdminListTokenDTO(userId string, write string, expired string, expiresDays string, unusedDays string) (*ListTokenDTO, error) {
+	filter := NewListTokenDTO(nil, unusedDays)
+	filter.ExpiresDays, _ = strconv.Atoi(expiresDays)
+
+	if userId != "" {
+		id, err := uuid.Parse(userId)
+		if err != nil {
+			return nil, err
+		}
+		filter.UserID = &id
+	}
+
+	if write != "" {
+		value, err := strconv.ParseBool(write)
+		if err != nil {
+			return nil, err
+		}
+		filter.Write = &value
+	}
+
+	if expired != "" {
+		value, err := strconv.ParseBool(expired)
+		if err != nil {
+			return nil, err
+		}
+		filter.Expired = &value
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,12 @@
+package pubtokendto
+
+import "github.com/google/uuid"
+
+type RevokeTokenDTO struct {
+	IDs []uuid.UUID `json:"ids" validate:"required,min=1,max=1000"`
+}
+
+type RevokeResultDTO struct {
+	// tokens deleted, the unknown & already deleted ones are not counted
+	Revoked int64 `json:"revoked"`
+}
@@ -10,11 +10,22 @@ import (
 
 type PubTokenModel struct {
 	base.BaseModel
-	Remarks   string               `json:"remarks" gorm:"not null;"`
-	Write     *bool                `json:"write" gorm:"not null;default:false"`
-	ExpiredAt *time.Time           `json:"expired_at" gorm:"not null;"`
-	UserID    *uuid.UUID           `json:"user_id" gorm:"type:uuid;nullable;"`
-	User      *usermodel.UserModel `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Remarks   string     `json:"remarks" gorm:"not null;"`
+	Write     *bool      `json:"write" gorm:"not null;default:false"`
+	ExpiredAt *time.Time `json:"expired_at" gorm:"not null;"`
+	UserID    *uuid.UUID `json:"user_id" gorm:"type:uuid;nullable;"`
+	// sha-256 of the opaque token, nil for the jwt tokens created before them
+	TokenHash *string `json:"-" gorm:"size:64;uniqueIndex;"`
+	// end of the opaque token, to recognize it in lists
+	TokenHint *string `json:"token_hint" gorm:"size:16;"`
+	// last use, written every few seconds from the usage counted in memory
+	LastUsedAt        *time.Time `json:"last_used_at" gorm:"index;"`
+	LastUsedIp        *string    `json:"last_used_ip" gorm:"size:64;"`
+	LastUsedUserAgent *string    `json:"last_used_user_agent" gorm:"size:255;"`
+	LastUsedOperation *string    `json:"last_used_operation" gorm:"size:16;"`
+	// only package can be published & read with the token, set on the tokens of trusted publishing which have no user
+	Package *string              `json:"package" gorm:"size:255;"`
+	User    *usermodel.UserModel `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
 
 func (PubTokenModel) TableName() string {
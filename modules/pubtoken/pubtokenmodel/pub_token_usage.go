@@ -0,0 +1,29 @@
+package pubtokenmodel
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UsageRead    = "read"
+	UsagePublish = "publish"
+)
+
+// PubTokenUsageModel = uses of a token in a day, by operation & ip
+type PubTokenUsageModel struct {
+	TokenID   uuid.UUID `json:"-" gorm:"type:uuid;primaryKey;"`
+	Day       string    `json:"day" gorm:"size:10;primaryKey;index;"`
+	Operation string    `json:"operation" gorm:"size:16;primaryKey;"`
+	Ip        string    `json:"ip" gorm:"size:64;primaryKey;"`
+	// last seen of the day
+	UserAgent  string         `json:"user_agent" gorm:"size:255;not null;"`
+	Count      int            `json:"count" gorm:"not null;"`
+	LastUsedAt time.Time      `json:"last_used_at" gorm:"not null;"`
+	Token      *PubTokenModel `json:"-" gorm:"foreignKey:TokenID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (PubTokenUsageModel) TableName() string {
+	return "pub_token_usages"
+}
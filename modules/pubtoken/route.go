@@ -3,6 +3,11 @@ package pubtoken
 const (
 	basePath   = "v1/pubtoken"
 	detailPath = basePath + "/:id"
+
+	adminBasePath   = "v1/admin/pubtoken"
+	adminDetailPath = adminBasePath + "/:id"
+	adminRevokePath = adminBasePath + "/revoke"
+	adminUserPath   = adminBasePath + "/users/:id"
 )
 
 func (module *PubTokenModule) registerRoutes() {
@@ -11,4 +16,10 @@ func (module *PubTokenModule) registerRoutes() {
 	module.app.Get(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.controller.handleDetail)
 	module.app.Put(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.controller.handleUpdate)
 	module.app.Delete(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.controller.handleDelete)
+
+	module.app.Get(adminBasePath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleAdminList)
+	module.app.Post(adminRevokePath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleAdminRevoke)
+	module.app.Delete(adminUserPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleAdminRevokeUser)
+	module.app.Get(adminDetailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleAdminDetail)
+	module.app.Delete(adminDetailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleAdminDelete)
 }
@@ -14,6 +14,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -22,29 +23,48 @@ const (
 
 type PubTokenService interface {
 	Init(db db.DbService)
+	// Insert = the opaque token, only its hash is stored so it can't be shown again
 	Insert(context context.Context, pubtoken *pubtokenmodel.PubTokenModel) (*string, error)
-	List(context context.Context, req *appmodel.GetListRequest, userId *uuid.UUID) (*appmodel.PaginationResponseList, error)
+	// FindByToken = the token of an opaque token, gorm.ErrRecordNotFound when unknown or deleted
+	FindByToken(context context.Context, token string) (*pubtokenmodel.PubTokenModel, error)
+	List(context context.Context, req *appmodel.GetListRequest, filter *pubtokendto.ListTokenDTO) (*appmodel.PaginationResponseList, error)
 	Detail(context context.Context, id uuid.UUID, userId *uuid.UUID) (*pubtokenmodel.PubTokenModel, error)
 	Update(context context.Context, id uuid.UUID, userId *uuid.UUID, updateDTO *pubtokendto.UpdateTokenDTO) (*pubtokenmodel.PubTokenModel, error)
 	Delete(context context.Context, id uuid.UUID, userId *uuid.UUID) error
+	// DeleteMany = revoke the tokens of ids whoever they belong to, the count of deleted tokens
+	DeleteMany(context context.Context, ids []uuid.UUID) (int64, error)
+	// DeleteByUser = revoke every token of the user, the user is kept
+	DeleteByUser(context context.Context, userId uuid.UUID) (int64, error)
+	// RecordUsage = count a use of the token in memory, written by `FlushUsage`
+	RecordUsage(id uuid.UUID, operation string, ip string, userAgent string)
+	FlushUsage(context context.Context) error
+	// UsageHistory = uses of the token by day, operation & ip, most recent first
+	UsageHistory(context context.Context, id uuid.UUID, userId *uuid.UUID) ([]pubtokenmodel.PubTokenUsageModel, error)
+	DeleteUsageBefore(context context.Context, day time.Time) error
+	// DeletePackageTokensBefore = remove the package tokens expired before expiredAt, with their usage
+	DeletePackageTokensBefore(context context.Context, expiredAt time.Time) error
 }
 
 type pubTokenServiceImpl struct {
 	monitorService monitor.MonitorService
 	jwtService     jwt.JwtService
+	dbService      db.DbService
 	db             *gorm.DB
+	usage          *usageBuffer
 }
 
 func NewPubTokenService(jwtService jwt.JwtService, monitorService monitor.MonitorService) PubTokenService {
 	return &pubTokenServiceImpl{
 		jwtService:     jwtService,
 		monitorService: monitorService,
+		usage:          newUsageBuffer(),
 	}
 }
 
 // impl `PubTokenService` start
 
 func (service *pubTokenServiceImpl) Init(db db.DbService) {
+	service.dbService = db
 	service.db = db.Default()
 }
 
@@ -52,24 +72,70 @@ func (service *pubTokenServiceImpl) Insert(context context.Context, pubToken *pu
 	spanContext, span := service.monitorService.StartTraceSpan(context, "PubTokenService.Insert", map[string]interface{}{})
 	defer span.End()
 
+	token, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, hint := hashToken(token), tokenHint(token)
+	pubToken.TokenHash = &hash
+	pubToken.TokenHint = &hint
+
 	result := service.db.WithContext(spanContext).Create(pubToken)
 
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
-	response, err := service.jwtService.GenerateAccessTokenTimed(pubToken.ID, JwtIssuer, time.Now().Unix(), map[string]interface{}{}, pubToken.ExpiredAt)
-	return &response, err
+	return &token, nil
 }
 
-func (service *pubTokenServiceImpl) List(context context.Context, req *appmodel.GetListRequest, userId *uuid.UUID) (*appmodel.PaginationResponseList, error) {
+func (service *pubTokenServiceImpl) FindByToken(context context.Context, token string) (*pubtokenmodel.PubTokenModel, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubTokenService.FindByToken", map[string]interface{}{})
+	defer span.End()
+
+	if err := checkOpaqueToken(token); err != nil {
+		return nil, err
+	}
+
+	var pubToken pubtokenmodel.PubTokenModel
+	hash := hashToken(token)
+	// every pub api request looks up its token
+	err := service.dbService.Read(spanContext, func(tx *gorm.DB) error {
+		return tx.First(&pubToken, pubtokenmodel.PubTokenModel{TokenHash: &hash}).Error
+	})
+
+	return &pubToken, err
+}
+
+func (service *pubTokenServiceImpl) List(context context.Context, req *appmodel.GetListRequest, filter *pubtokendto.ListTokenDTO) (*appmodel.PaginationResponseList, error) {
 	spanContext, span := service.monitorService.StartTraceSpan(context, "PubTokenService.List", utils.StructToMap(req))
 	defer span.End()
 	var count int64
 	pubtokens := []pubtokenmodel.PubTokenModel{}
-	query := service.db.WithContext(spanContext).Model(pubtokens).Where("user_id = ?", userId)
+	query := service.db.WithContext(spanContext).Model(pubtokens)
+	if filter.UserID != nil {
+		query.Where("user_id = ?", filter.UserID)
+	}
 	if req.Search != "" {
-		query.Where("name ILIKE ?", "%"+req.Search+"%")
+		query.Where(utils.InsensitiveLike("remarks"), utils.ContainsPattern(req.Search))
+	}
+	if filter.Write != nil {
+		// write is reserved by mysql, the struct condition quotes it
+		query.Where(&pubtokenmodel.PubTokenModel{Write: filter.Write})
+	}
+	now := time.Now()
+	if filter.Expired != nil && *filter.Expired {
+		query.Where("expired_at < ?", now)
+	} else if filter.Expired != nil {
+		query.Where("expired_at >= ?", now)
+	}
+	if filter.ExpiresDays > 0 {
+		query.Where("expired_at >= ? AND expired_at < ?", now, now.AddDate(0, 0, filter.ExpiresDays))
+	}
+	if filter.UnusedDays > 0 {
+		since := time.Now().AddDate(0, 0, -filter.UnusedDays)
+		query.Where("last_used_at < ? OR (last_used_at IS NULL AND created_at < ?)", since, since)
 	}
 
 	var wg sync.WaitGroup
@@ -119,12 +185,15 @@ func (service *pubTokenServiceImpl) Detail(context context.Context, id uuid.UUID
 	})
 	defer span.End()
 	var pubToken pubtokenmodel.PubTokenModel
-	result := service.db.WithContext(spanContext).First(&pubToken, pubtokendto.QueryTokenDTO{
-		ID:     &id,
-		UserID: userId,
+	// every pub api request looks up its token
+	err := service.dbService.Read(spanContext, func(tx *gorm.DB) error {
+		return tx.First(&pubToken, pubtokendto.QueryTokenDTO{
+			ID:     &id,
+			UserID: userId,
+		}).Error
 	})
 
-	return &pubToken, result.Error
+	return &pubToken, err
 }
 
 func (service *pubTokenServiceImpl) Update(context context.Context, id uuid.UUID, userId *uuid.UUID, updateDTO *pubtokendto.UpdateTokenDTO) (*pubtokenmodel.PubTokenModel, error) {
@@ -164,4 +233,135 @@ func (service *pubTokenServiceImpl) Delete(context context.Context, id uuid.UUID
 	return result.Error
 }
 
+func (service *pubTokenServiceImpl) DeleteMany(context context.Context, ids []uuid.UUID) (int64, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubTokenService.DeleteMany", map[string]interface{}{
+		"count": len(ids),
+	})
+	defer span.End()
+	result := service.db.WithContext(spanContext).Where("id IN ?", ids).Delete(&pubtokenmodel.PubTokenModel{})
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	service.monitorService.Logger().InfoContext(spanContext, "pub tokens revoked", "requested", len(ids), "revoked", result.RowsAffected)
+	return result.RowsAffected, nil
+}
+
+func (service *pubTokenServiceImpl) DeleteByUser(context context.Context, userId uuid.UUID) (int64, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubTokenService.DeleteByUser", map[string]interface{}{
+		"user_id": userId.String(),
+	})
+	defer span.End()
+	result := service.db.WithContext(spanContext).Where("user_id = ?", userId).Delete(&pubtokenmodel.PubTokenModel{})
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	service.monitorService.Logger().InfoContext(spanContext, "pub tokens revoked", "token_user_id", userId.String(), "revoked", result.RowsAffected)
+	return result.RowsAffected, nil
+}
+
+func (service *pubTokenServiceImpl) RecordUsage(id uuid.UUID, operation string, ip string, userAgent string) {
+	service.usage.add(id, operation, ip, userAgent, time.Now())
+}
+
+func (service *pubTokenServiceImpl) FlushUsage(context context.Context) error {
+	counts := service.usage.take()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubTokenService.FlushUsage", map[string]interface{}{
+		"entries": len(counts),
+	})
+	defer span.End()
+
+	usages := make([]pubtokenmodel.PubTokenUsageModel, 0, len(counts))
+	lastUsages := map[uuid.UUID]*pubtokenmodel.PubTokenUsageModel{}
+	for key, count := range counts {
+		usages = append(usages, pubtokenmodel.PubTokenUsageModel{
+			TokenID:    key.tokenId,
+			Day:        key.day,
+			Operation:  key.operation,
+			Ip:         key.ip,
+			UserAgent:  count.userAgent,
+			Count:      count.count,
+			LastUsedAt: count.lastUsedAt,
+		})
+	}
+	for i := range usages {
+		if last, ok := lastUsages[usages[i].TokenID]; !ok || usages[i].LastUsedAt.After(last.LastUsedAt) {
+			lastUsages[usages[i].TokenID] = &usages[i]
+		}
+	}
+
+	err := service.db.WithContext(spanContext).Transaction(func(tx *gorm.DB) error {
+		for id, last := range lastUsages {
+			// another instance may have written a more recent use, the updated_at of the token is left as is
+			err := tx.Model(&pubtokenmodel.PubTokenModel{}).Where("id = ?", id).
+				Where("last_used_at IS NULL OR last_used_at < ?", last.LastUsedAt).
+				UpdateColumns(map[string]interface{}{
+					"last_used_at":         last.LastUsedAt,
+					"last_used_ip":         last.Ip,
+					"last_used_user_agent": last.UserAgent,
+					"last_used_operation":  last.Operation,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range usages {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "token_id"}, {Name: "day"}, {Name: "operation"}, {Name: "ip"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"count":        gorm.Expr("pub_token_usages.count + ?", usages[i].Count),
+					"user_agent":   usages[i].UserAgent,
+					"last_used_at": usages[i].LastUsedAt,
+				}),
+			}).Create(&usages[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		service.usage.restore(counts)
+	}
+
+	return err
+}
+
+func (service *pubTokenServiceImpl) UsageHistory(context context.Context, id uuid.UUID, userId *uuid.UUID) ([]pubtokenmodel.PubTokenUsageModel, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "PubTokenService.UsageHistory", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+
+	if _, err := service.Detail(spanContext, id, userId); err != nil {
+		return nil, err
+	}
+
+	usages := []pubtokenmodel.PubTokenUsageModel{}
+	err := service.db.WithContext(spanContext).Where(&pubtokenmodel.PubTokenUsageModel{TokenID: id}).
+		Order("day desc").Order("last_used_at desc").Find(&usages).Error
+
+	return usages, err
+}
+
+func (service *pubTokenServiceImpl) DeleteUsageBefore(context context.Context, day time.Time) error {
+	return service.db.WithContext(context).Where("day < ?", day.UTC().Format(usageDayLayout)).
+		Delete(&pubtokenmodel.PubTokenUsageModel{}).Error
+}
+
+func (service *pubTokenServiceImpl) DeletePackageTokensBefore(context context.Context, expiredAt time.Time) error {
+	return service.db.WithContext(context).Unscoped().Where("package IS NOT NULL").Where("expired_at < ?", expiredAt).
+		Delete(&pubtokenmodel.PubTokenModel{}).Error
+}
+
 // impl `PubTokenService` end
@@ -0,0 +1,87 @@
+package pubtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"math/big"
+	"strings"
+)
+
+const (
+	// TokenPrefix = start of the opaque pub tokens, recognizable by secret scanners
+	TokenPrefix = "ppr_"
+	// ~178 bits of entropy
+	tokenRandomLength   = 30
+	tokenChecksumLength = 6
+	tokenHintLength     = 4
+	base62Alphabet      = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+var errMalformedToken = errors.New("malformed pub token")
+
+// encodeBase62 = value in base62, left padded with zeros to length
+func encodeBase62(value *big.Int, length int) string {
+	result := make([]byte, length)
+	base := big.NewInt(int64(len(base62Alphabet)))
+	remainder := new(big.Int)
+	value = new(big.Int).Set(value)
+
+	for i := length - 1; i >= 0; i-- {
+		value.DivMod(value, base, remainder)
+		result[i] = base62Alphabet[remainder.Int64()]
+	}
+
+	return string(result)
+}
+
+func tokenChecksum(random string) string {
+	return encodeBase62(big.NewInt(int64(crc32.ChecksumIEEE([]byte(random)))), tokenChecksumLength)
+}
+
+// newOpaqueToken = `ppr_<random><crc32 of random>`, the checksum lets typos & fake tokens be refused without a lookup
+func newOpaqueToken() (string, error) {
+	random := make([]byte, tokenRandomLength)
+	max := big.NewInt(int64(len(base62Alphabet)))
+
+	for i := range random {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		random[i] = base62Alphabet[index.Int64()]
+	}
+
+	return TokenPrefix + string(random) + tokenChecksum(string(random)), nil
+}
+
+// IsOpaqueToken = token has the pub token prefix, it may still be malformed
+func IsOpaqueToken(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+func checkOpaqueToken(token string) error {
+	body, ok := strings.CutPrefix(token, TokenPrefix)
+	if !ok || len(body) != tokenRandomLength+tokenChecksumLength {
+		return errMalformedToken
+	}
+
+	random, checksum := body[:tokenRandomLength], body[tokenRandomLength:]
+	if strings.Trim(random, base62Alphabet) != "" || tokenChecksum(random) != checksum {
+		return errMalformedToken
+	}
+
+	return nil
+}
+
+// hashToken = what is stored instead of the token, the tokens are random enough for a fast hash
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func tokenHint(token string) string {
+	return TokenPrefix + "..." + token[len(token)-tokenHintLength:]
+}
@@ -0,0 +1,96 @@
+package pubtoken
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	usageDayLayout     = "2006-01-02"
+	maxUserAgentLength = 255
+	maxUsageIpLength   = 64
+)
+
+type usageKey struct {
+	tokenId   uuid.UUID
+	day       string
+	operation string
+	ip        string
+}
+
+type usageCount struct {
+	userAgent  string
+	count      int
+	lastUsedAt time.Time
+}
+
+// usageBuffer = token uses counted in memory, written to the database as a whole every few seconds
+type usageBuffer struct {
+	mutex  sync.Mutex
+	counts map[usageKey]*usageCount
+}
+
+func newUsageBuffer() *usageBuffer {
+	return &usageBuffer{counts: map[usageKey]*usageCount{}}
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+func (buffer *usageBuffer) add(tokenId uuid.UUID, operation string, ip string, userAgent string, at time.Time) {
+	// copies, the strings of fiber are only valid during the request
+	ip, userAgent = utils.CopyString(truncate(ip, maxUsageIpLength)), utils.CopyString(truncate(userAgent, maxUserAgentLength))
+	key := usageKey{tokenId: tokenId, day: at.UTC().Format(usageDayLayout), operation: operation, ip: ip}
+
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	count, ok := buffer.counts[key]
+	if !ok {
+		count = &usageCount{}
+		buffer.counts[key] = count
+	}
+
+	count.count++
+	if at.After(count.lastUsedAt) {
+		count.userAgent = userAgent
+		count.lastUsedAt = at
+	}
+}
+
+// take = the counts so far, the buffer starts over
+func (buffer *usageBuffer) take() map[usageKey]*usageCount {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	counts := buffer.counts
+	buffer.counts = map[usageKey]*usageCount{}
+	return counts
+}
+
+// restore = counts that couldn't be written, merged back for the next flush
+func (buffer *usageBuffer) restore(counts map[usageKey]*usageCount) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	for key, restored := range counts {
+		count, ok := buffer.counts[key]
+		if !ok {
+			buffer.counts[key] = restored
+			continue
+		}
+
+		count.count += restored.count
+		if restored.lastUsedAt.After(count.lastUsedAt) {
+			count.userAgent = restored.userAgent
+			count.lastUsedAt = restored.lastUsedAt
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"private-pub-repo/base"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/ratelimit/ratelimitmodel"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// expired counters are deleted this often
+const cleanupInterval = 5 * time.Minute
+
+type RateLimitModule struct {
+	Service        RateLimitService
+	db             db.DbService
+	monitorService monitor.MonitorService
+	stopCleanup    chan struct{}
+}
+
+func NewModule(service RateLimitService, db db.DbService, monitorService monitor.MonitorService) *RateLimitModule {
+	return &RateLimitModule{Service: service, db: db, monitorService: monitorService}
+}
+
+// ProvideStore = the store of the configuration, replace it (e.g. `fx.Decorate`) to plug another one
+func ProvideStore(config config.ConfigService, db db.DbService) Store {
+	if config.Config().RateLimit.Store == StoreMemory {
+		return NewMemoryStore()
+	}
+	return NewDatabaseStore(db)
+}
+
+func fxRegister(lifeCycle fx.Lifecycle, module *RateLimitModule) {
+	base.FxRegister(module, lifeCycle)
+}
+
+func SetupModule(db *db.DbModule, monitor *monitor.MonitorModule, config *config.ConfigModule) *RateLimitModule {
+	service := NewRateLimitService(ProvideStore(config, db), monitor.Service, config)
+	return NewModule(service, db, monitor.Service)
+}
+
+var FxModule = fx.Module("RateLimit", fx.Provide(ProvideStore), fx.Provide(NewRateLimitService), fx.Provide(NewModule), fx.Invoke(fxRegister))
+
+func (module *RateLimitModule) startCleanup() {
+	module.stopCleanup = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := module.Service.DeleteExpired(context.Background()); err != nil {
+					module.monitorService.Logger().Error("rate limit cleanup failed", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(module.stopCleanup)
+}
+
+// implements `BaseModule` of `base/module.go` start
+
+func (module *RateLimitModule) OnStart() error {
+	if module.db.AutoMigrate() {
+		module.db.Default().AutoMigrate(&ratelimitmodel.RateLimitModel{})
+	}
+
+	module.startCleanup()
+	return nil
+}
+
+func (module *RateLimitModule) OnStop() error {
+	if module.stopCleanup != nil {
+		close(module.stopCleanup)
+		module.stopCleanup = nil
+	}
+	return nil
+}
+
+// implements `BaseModule` of `base/module.go` end
@@ -0,0 +1,14 @@
+package ratelimitmodel
+
+import "time"
+
+// RateLimitModel = hits of a key during a window, the key holds the window start
+type RateLimitModel struct {
+	Key       string    `gorm:"primaryKey;size:191" json:"key"`
+	Hits      int       `gorm:"not null" json:"hits"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (RateLimitModel) TableName() string {
+	return "rate_limits"
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/monitor"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// limited requests get the standard `RateLimit-*` headers, `Retry-After` as well once refused
+const (
+	headerLimit     = "RateLimit-Limit"
+	headerRemaining = "RateLimit-Remaining"
+	headerReset     = "RateLimit-Reset"
+	headerPolicy    = "RateLimit-Policy"
+)
+
+type RateLimitService interface {
+	// Pub = limit of the pub api, after the jwt handler so the pub token is known
+	Pub() fiber.Handler
+	// Query = limit of the query api, after the jwt handler so the user is known
+	Query() fiber.Handler
+	// Public = limit of login & forgot password
+	Public() fiber.Handler
+	DeleteExpired(context context.Context) error
+}
+
+type rateLimitServiceImpl struct {
+	store          Store
+	monitorService monitor.MonitorService
+	config         *config.RateLimitConfig
+}
+
+func NewRateLimitService(store Store, monitorService monitor.MonitorService, config config.ConfigService) RateLimitService {
+	return &rateLimitServiceImpl{
+		store:          store,
+		monitorService: monitorService,
+		config:         &config.Config().RateLimit,
+	}
+}
+
+// identity = issuer & subject of a valid token (pub token or user), the ip otherwise
+func identity(c *fiber.Ctx) string {
+	if token, ok := c.Locals("user").(*jwt.Token); ok {
+		issuer, _ := token.Claims.GetIssuer()
+		subject, _ := token.Claims.GetSubject()
+		if issuer != "" && subject != "" {
+			return issuer + ":" + subject
+		}
+	}
+
+	return "ip:" + c.IP()
+}
+
+// handler = fixed window limit, counted per scope & identity
+func (service *rateLimitServiceImpl) handler(scope string, limit int) fiber.Handler {
+	window := time.Duration(service.config.Window) * time.Second
+
+	return func(c *fiber.Ctx) error {
+		if limit <= 0 {
+			return c.Next()
+		}
+
+		now := time.Now()
+		windowStart := now.Truncate(window)
+		resetAt := windowStart.Add(window)
+		key := fmt.Sprintf("%s:%s:%d", scope, identity(c), windowStart.Unix())
+
+		hits, err := service.store.Increment(c.UserContext(), key, resetAt)
+		if err != nil {
+			// an unreachable store shouldn't take the api down with it
+			service.monitorService.Logger().ErrorContext(c.UserContext(), "rate limit store error", "scope", scope, "error", err)
+			return c.Next()
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(resetAt.Sub(now).Seconds())))
+		c.Set(headerLimit, strconv.Itoa(limit))
+		c.Set(headerRemaining, strconv.Itoa(max(limit-hits, 0)))
+		c.Set(headerReset, reset)
+		c.Set(headerPolicy, fmt.Sprintf("%d;w=%d", limit, service.config.Window))
+
+		if hits > limit {
+			c.Set(fiber.HeaderRetryAfter, reset)
+			service.monitorService.Logger().InfoContext(c.UserContext(), "rate limited", "scope", scope, "hits", hits)
+			return fiber.NewError(fiber.StatusTooManyRequests, "Too many requests")
+		}
+
+		return c.Next()
+	}
+}
+
+// impl `RateLimitService` start
+
+func (service *rateLimitServiceImpl) Pub() fiber.Handler {
+	return service.handler("pub", service.config.Pub)
+}
+
+func (service *rateLimitServiceImpl) Query() fiber.Handler {
+	return service.handler("query", service.config.Query)
+}
+
+func (service *rateLimitServiceImpl) Public() fiber.Handler {
+	return service.handler("public", service.config.Public)
+}
+
+func (service *rateLimitServiceImpl) DeleteExpired(context context.Context) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "RateLimitService.DeleteExpired", map[string]interface{}{})
+	defer span.End()
+
+	return service.store.DeleteExpired(spanContext, time.Now())
+}
+
+// impl `RateLimitService` end
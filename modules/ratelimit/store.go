@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/ratelimit/ratelimitmodel"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StoreMemory   = "memory"
+	StoreDatabase = "database"
+)
+
+// Store = counters of the rate limits, the limits hold across the instances sharing the store
+type Store interface {
+	// Increment = count a hit of key, a new counter expires at expiresAt, returns the hits so far
+	Increment(context context.Context, key string, expiresAt time.Time) (int, error)
+	// DeleteExpired = forget the counters expired at now
+	DeleteExpired(context context.Context, now time.Time) error
+}
+
+type memoryCounter struct {
+	hits      int
+	expiresAt time.Time
+}
+
+// memoryStore = counters of this instance only
+type memoryStore struct {
+	mutex    sync.Mutex
+	counters map[string]*memoryCounter
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{counters: map[string]*memoryCounter{}}
+}
+
+func (store *memoryStore) Increment(context context.Context, key string, expiresAt time.Time) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	counter, ok := store.counters[key]
+	if !ok {
+		counter = &memoryCounter{expiresAt: expiresAt}
+		store.counters[key] = counter
+	}
+	counter.hits++
+
+	return counter.hits, nil
+}
+
+func (store *memoryStore) DeleteExpired(context context.Context, now time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for key, counter := range store.counters {
+		if !counter.expiresAt.After(now) {
+			delete(store.counters, key)
+		}
+	}
+
+	return nil
+}
+
+// databaseStore = counters in the `rate_limits` table of the default profile, one upsert per request
+type databaseStore struct {
+	db db.DbService
+}
+
+func NewDatabaseStore(db db.DbService) Store {
+	return &databaseStore{db: db}
+}
+
+func (store *databaseStore) Increment(context context.Context, key string, expiresAt time.Time) (int, error) {
+	var counter ratelimitmodel.RateLimitModel
+
+	err := store.db.Default().WithContext(db.WithoutWriteTracking(context)).Transaction(func(tx *gorm.DB) error {
+		// atomic on every dialect, the row stays locked until the read below.
+		// qualified, postgres finds `hits` ambiguous between the table & `excluded`
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"hits": gorm.Expr("rate_limits.hits + 1")}),
+		}).Create(&ratelimitmodel.RateLimitModel{Key: key, Hits: 1, ExpiresAt: expiresAt}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where(&ratelimitmodel.RateLimitModel{Key: key}).Take(&counter).Error
+	})
+
+	return counter.Hits, err
+}
+
+func (store *databaseStore) DeleteExpired(context context.Context, now time.Time) error {
+	return store.db.Default().WithContext(context).Where("expires_at <= ?", now).Delete(&ratelimitmodel.RateLimitModel{}).Error
+}
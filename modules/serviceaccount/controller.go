@@ -0,0 +1,154 @@
+package serviceaccount
+
+import (
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/pubtoken/pubtokendto"
+	"private-pub-repo/modules/serviceaccount/serviceaccountdto"
+	"private-pub-repo/modules/setting"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	validationError = "Validation Error"
+)
+
+type serviceAccountController struct {
+	service         ServiceAccountService
+	responseService app.ResponseService
+	validator       *validator.Validate
+	settingService  setting.SettingService
+}
+
+func newServiceAccountController(service ServiceAccountService, responseService app.ResponseService, validator *validator.Validate, settingService setting.SettingService) *serviceAccountController {
+	return &serviceAccountController{
+		service:         service,
+		responseService: responseService,
+		validator:       validator,
+		settingService:  settingService,
+	}
+}
+
+// handlers start
+
+func (controller *serviceAccountController) handleCreate(ctx *fiber.Ctx) error {
+	request := serviceaccountdto.CreateServiceAccountDTO{}
+	ctx.BodyParser(&request)
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	serviceAccount, err := controller.service.Insert(ctx.UserContext(), &request)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	return controller.responseService.SendSuccessDetailResponse(ctx, 201, serviceAccount)
+}
+
+func (controller *serviceAccountController) handleList(ctx *fiber.Ctx) error {
+	request := appmodel.NewGetListRequest(ctx.Query("page"), ctx.Query("limit"), ctx.Query("search"))
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	list, err := controller.service.List(ctx.UserContext(), request, ctx.Query("owner"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	return controller.responseService.SendSuccessResponse(ctx, 200, appmodel.PaginationResponse{
+		List: list,
+	})
+}
+
+func (controller *serviceAccountController) handleDetail(ctx *fiber.Ctx) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	serviceAccount, err := controller.service.Detail(ctx.UserContext(), id)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, serviceAccount)
+}
+
+func (controller *serviceAccountController) handleUpdate(ctx *fiber.Ctx) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	request := serviceaccountdto.UpdateServiceAccountDTO{}
+	ctx.BodyParser(&request)
+	err = controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	serviceAccount, err := controller.service.Update(ctx.UserContext(), id, &request)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, serviceAccount)
+}
+
+func (controller *serviceAccountController) handleDelete(ctx *fiber.Ctx) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	err = controller.service.Delete(ctx.UserContext(), id)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, nil)
+}
+
+func (controller *serviceAccountController) handleCreateToken(ctx *fiber.Ctx) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	request := pubtokendto.CreateTokenDTO{}
+	ctx.BodyParser(&request)
+	err = controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	defaultLifetime := time.Duration(controller.settingService.Int(setting.TokenLifetime)) * 24 * time.Hour
+	// write is checked against the service account, not the admin creating the token
+	token, err := controller.service.InsertToken(ctx.UserContext(), id, request.ToModel(id, true, defaultLifetime))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	return controller.responseService.SendSuccessDetailResponse(ctx, 201, token)
+}
+
+// handlers end
@@ -0,0 +1,56 @@
+package serviceaccount
+
+import (
+	"private-pub-repo/base"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/jwt"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/setting"
+	"private-pub-repo/modules/user"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/fx"
+)
+
+// ServiceAccountModule = the users of ci pipelines, managed by the admins
+type ServiceAccountModule struct {
+	Service        ServiceAccountService
+	userMiddleware user.UserJwtMiddleware
+	controller     *serviceAccountController
+	jwtService     jwt.JwtService
+	db             db.DbService
+	app            *fiber.App
+}
+
+func NewModule(service ServiceAccountService, controller *serviceAccountController, jwtService jwt.JwtService, db db.DbService, userMiddleware user.UserJwtMiddleware, app *fiber.App) *ServiceAccountModule {
+	return &ServiceAccountModule{Service: service, userMiddleware: userMiddleware, jwtService: jwtService, controller: controller, db: db, app: app}
+}
+
+func fxRegister(lifeCycle fx.Lifecycle, module *ServiceAccountModule) {
+	base.FxRegister(module, lifeCycle)
+}
+
+func SetupModule(app *app.AppModule, db *db.DbModule, user *user.UserModule, pubToken *pubtoken.PubTokenModule, jwt *jwt.JwtModule, monitor *monitor.MonitorModule, setting *setting.SettingModule) *ServiceAccountModule {
+	service := NewServiceAccountService(user.Service, pubToken.Service, monitor.Service)
+	controller := newServiceAccountController(service, app.ResponseService, app.Validator, setting.Service)
+	return NewModule(service, controller, jwt, db, user.Middleware, app.App)
+}
+
+var FxModule = fx.Module("ServiceAccount", fx.Provide(NewServiceAccountService), fx.Provide(newServiceAccountController), fx.Provide(NewModule), fx.Invoke(fxRegister))
+
+// implements `BaseModule` of `base/module.go` start
+
+func (module *ServiceAccountModule) OnStart() error {
+	// the service accounts are users, migrated by the user module
+	module.Service.Init(module.db)
+	module.registerRoutes()
+	return nil
+}
+
+func (module *ServiceAccountModule) OnStop() error {
+	return nil
+}
+
+// implements `BaseModule` of `base/module.go` end
@@ -0,0 +1,16 @@
+package serviceaccount
+
+const (
+	basePath   = "v1/service-accounts"
+	detailPath = basePath + "/:id"
+	tokenPath  = detailPath + "/pubtoken"
+)
+
+func (module *ServiceAccountModule) registerRoutes() {
+	module.app.Get(basePath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleList)
+	module.app.Post(basePath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleCreate)
+	module.app.Get(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleDetail)
+	module.app.Put(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleUpdate)
+	module.app.Delete(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleDelete)
+	module.app.Post(tokenPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleCreateToken)
+}
@@ -0,0 +1,184 @@
+package serviceaccount
+
+import (
+	"context"
+	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/serviceaccount/serviceaccountdto"
+	"private-pub-repo/modules/user"
+	"private-pub-repo/modules/user/userdto"
+	"private-pub-repo/modules/user/usermodel"
+	"private-pub-repo/utils"
+	"sync"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const serviceAccountWhereQuery = "is_service_account = ?"
+
+type ServiceAccountService interface {
+	Init(db db.DbService)
+	Insert(context context.Context, createDTO *serviceaccountdto.CreateServiceAccountDTO) (*userdto.UserDTO, error)
+	// List = the service accounts, of owner when not empty
+	List(context context.Context, req *appmodel.GetListRequest, owner string) (*appmodel.PaginationResponseList, error)
+	// Detail = the service account, gorm.ErrRecordNotFound for the people
+	Detail(context context.Context, id uuid.UUID) (*userdto.UserDTO, error)
+	Update(context context.Context, id uuid.UUID, updateDTO *serviceaccountdto.UpdateServiceAccountDTO) (*userdto.UserDTO, error)
+	// Delete = the service account & its pub tokens
+	Delete(context context.Context, id uuid.UUID) error
+	// InsertToken = opaque token of a new pub token of the service account, writable only if the service account can write
+	InsertToken(context context.Context, id uuid.UUID, pubToken *pubtokenmodel.PubTokenModel) (*string, error)
+}
+
+type serviceAccountServiceImpl struct {
+	monitorService  monitor.MonitorService
+	userService     user.UserService
+	pubTokenService pubtoken.PubTokenService
+	db              *gorm.DB
+}
+
+func NewServiceAccountService(userService user.UserService, pubTokenService pubtoken.PubTokenService, monitorService monitor.MonitorService) ServiceAccountService {
+	return &serviceAccountServiceImpl{
+		userService:     userService,
+		pubTokenService: pubTokenService,
+		monitorService:  monitorService,
+	}
+}
+
+// impl `ServiceAccountService` start
+
+func (service *serviceAccountServiceImpl) Init(db db.DbService) {
+	service.db = db.Default()
+}
+
+func (service *serviceAccountServiceImpl) Insert(context context.Context, createDTO *serviceaccountdto.CreateServiceAccountDTO) (*userdto.UserDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "ServiceAccountService.Insert", map[string]interface{}{
+		"name": createDTO.Name,
+	})
+	defer span.End()
+
+	return service.userService.Insert(spanContext, createDTO.ToModel())
+}
+
+func (service *serviceAccountServiceImpl) List(context context.Context, req *appmodel.GetListRequest, owner string) (*appmodel.PaginationResponseList, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "ServiceAccountService.List", utils.StructToMap(req))
+	defer span.End()
+	var count int64
+	users := []usermodel.UserModel{}
+	query := service.db.WithContext(spanContext).Model(users).Where(serviceAccountWhereQuery, true)
+	if owner != "" {
+		query.Where("owner = ?", owner)
+	}
+	if req.Search != "" {
+		query.Where(service.db.Where(utils.InsensitiveLike("name"), utils.ContainsPattern(req.Search)).
+			Or(utils.InsensitiveLike("owner"), utils.ContainsPattern(req.Search)))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Perform count and find concurrently using goroutines
+	errChan := make(chan error, 2)
+	go func() {
+		defer wg.Done()
+		errChan <- query.Session(&gorm.Session{}).Count(&count).Error
+	}()
+
+	go func() {
+		defer wg.Done()
+		query = query.Session(&gorm.Session{})
+		errChan <- query.Limit(req.Limit).Offset((req.Page - 1) * req.Limit).Find(&users).Error
+	}()
+
+	wg.Wait()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-errChan:
+			if err != nil {
+				return nil, err
+			}
+		default:
+		}
+	}
+
+	count32 := int(count)
+
+	return &appmodel.PaginationResponseList{
+		Pagination: &appmodel.PaginationResponsePagination{
+			Page:  &req.Page,
+			Size:  &req.Limit,
+			Total: &count32,
+		},
+		Content: users,
+	}, nil
+}
+
+func (service *serviceAccountServiceImpl) Detail(context context.Context, id uuid.UUID) (*userdto.UserDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "ServiceAccountService.Detail", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+	var user usermodel.UserModel
+	result := service.db.WithContext(spanContext).Where(serviceAccountWhereQuery, true).First(&user, id)
+	return userdto.MapUserModelToDTO(&user), result.Error
+}
+
+func (service *serviceAccountServiceImpl) Update(context context.Context, id uuid.UUID, updateDTO *serviceaccountdto.UpdateServiceAccountDTO) (*userdto.UserDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "ServiceAccountService.Update", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+
+	if _, err := service.Detail(spanContext, id); err != nil {
+		return nil, err
+	}
+
+	if updateDTO.Owner != nil {
+		result := service.db.WithContext(spanContext).Model(&usermodel.UserModel{}).Where("id = ?", id).Update("owner", *updateDTO.Owner)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+	}
+
+	// the user service takes the write access of the tokens away with the one of the service account
+	return service.userService.Update(spanContext, id, &userdto.UpdateUserDTO{CanWrite: updateDTO.CanWrite})
+}
+
+func (service *serviceAccountServiceImpl) Delete(context context.Context, id uuid.UUID) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "ServiceAccountService.Delete", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+
+	if _, err := service.Detail(spanContext, id); err != nil {
+		return err
+	}
+
+	return service.userService.Delete(spanContext, id)
+}
+
+func (service *serviceAccountServiceImpl) InsertToken(context context.Context, id uuid.UUID, pubToken *pubtokenmodel.PubTokenModel) (*string, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "ServiceAccountService.InsertToken", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+
+	serviceAccount, err := service.Detail(spanContext, id)
+	if err != nil {
+		return nil, err
+	}
+
+	write := *pubToken.Write && serviceAccount.CanWrite
+	pubToken.Write = &write
+	pubToken.UserID = &serviceAccount.ID
+
+	return service.pubTokenService.Insert(spanContext, pubToken)
+}
+
+// impl `ServiceAccountService` end
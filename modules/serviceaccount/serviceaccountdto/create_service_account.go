@@ -0,0 +1,26 @@
+package serviceaccountdto
+
+import (
+	"private-pub-repo/modules/user/usermodel"
+	"strings"
+)
+
+type CreateServiceAccountDTO struct {
+	// name = login-less email of the service account, unique
+	Name     string `json:"name" validate:"required,max=64,hostname_rfc1123"`
+	Owner    string `json:"owner" validate:"required,max=255"`
+	CanWrite bool   `json:"can_write" validate:"boolean"`
+}
+
+func (dto *CreateServiceAccountDTO) ToModel() *usermodel.UserModel {
+	name := strings.ToLower(dto.Name)
+	owner := dto.Owner
+
+	return &usermodel.UserModel{
+		Name:             name,
+		Email:            name + "@" + usermodel.ServiceAccountEmailDomain,
+		CanWrite:         dto.CanWrite,
+		IsServiceAccount: true,
+		Owner:            &owner,
+	}
+}
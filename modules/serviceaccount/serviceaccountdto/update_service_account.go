@@ -0,0 +1,6 @@
+package serviceaccountdto
+
+type UpdateServiceAccountDTO struct {
+	Owner    *string `json:"owner" validate:"omitempty,min=1,max=255"`
+	CanWrite *bool   `json:"can_write" validate:"omitempty"`
+}
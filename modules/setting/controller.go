@@ -0,0 +1,114 @@
+package setting
+
+import (
+	"errors"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/setting/settingdto"
+	"private-pub-repo/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	validationError = "Validation Error"
+)
+
+type settingController struct {
+	service         SettingService
+	responseService app.ResponseService
+	validator       *validator.Validate
+}
+
+func newSettingController(service SettingService, responseService app.ResponseService, validator *validator.Validate) *settingController {
+	return &settingController{
+		service:         service,
+		responseService: responseService,
+		validator:       validator,
+	}
+}
+
+func (controller *settingController) handleError(err error) error {
+	if errors.Is(err, ErrUnknownSetting) {
+		return fiber.NewError(404, err.Error())
+	}
+	return fiber.NewError(400, err.Error())
+}
+
+// handlers start
+
+func (controller *settingController) handleList(ctx *fiber.Ctx) error {
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, controller.service.List(ctx.UserContext()))
+}
+
+func (controller *settingController) handleDetail(ctx *fiber.Ctx) error {
+	setting, err := controller.service.Detail(ctx.UserContext(), ctx.Params("key"))
+
+	if err != nil {
+		return controller.handleError(err)
+	}
+
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, setting)
+}
+
+func (controller *settingController) handleUpdate(ctx *fiber.Ctx) error {
+	userId, err := utils.GetFiberJwtUserId(ctx)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	request := settingdto.UpdateSettingDTO{}
+	ctx.BodyParser(&request)
+	err = controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	setting, err := controller.service.Update(ctx.UserContext(), ctx.Params("key"), request.Value, userId)
+
+	if err != nil {
+		return controller.handleError(err)
+	}
+
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, setting)
+}
+
+func (controller *settingController) handleReset(ctx *fiber.Ctx) error {
+	userId, err := utils.GetFiberJwtUserId(ctx)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	setting, err := controller.service.Reset(ctx.UserContext(), ctx.Params("key"), userId)
+
+	if err != nil {
+		return controller.handleError(err)
+	}
+
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, setting)
+}
+
+func (controller *settingController) handleHistory(ctx *fiber.Ctx) error {
+	request := appmodel.NewGetListRequest(ctx.Query("page"), ctx.Query("limit"), ctx.Query("search"))
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	list, err := controller.service.History(ctx.UserContext(), request, ctx.Params("key"))
+
+	if err != nil {
+		return controller.handleError(err)
+	}
+
+	return controller.responseService.SendSuccessResponse(ctx, 200, appmodel.PaginationResponse{
+		List: list,
+	})
+}
+
+// handlers end
@@ -0,0 +1,106 @@
+package setting
+
+import (
+	"private-pub-repo/modules/config"
+	"strconv"
+)
+
+// keys of the runtime settings
+const (
+	UpstreamUrl     = "upstream_url"
+	PresignTime     = "presign_time"
+	OtpExpiredTime  = "otp_expired_time"
+	UploadSizeLimit = "upload_size_limit"
+	MaintenanceMode = "maintenance_mode"
+	TokenLifetime   = "token_lifetime"
+	LegacyPubTokens = "legacy_pub_tokens"
+)
+
+const (
+	typeString = "string"
+	typeInt    = "int"
+	typeBool   = "bool"
+)
+
+// definition = a setting that can be changed at runtime, the default comes from the configuration
+type definition struct {
+	key         string
+	valueType   string
+	description string
+	// validator tag of the typed value
+	validate string
+	fallback func(config *config.Config) string
+}
+
+var definitions = []definition{
+	{
+		key:         UpstreamUrl,
+		valueType:   typeString,
+		description: "pub repository that serves the packages this one doesn't have, empty to disable",
+		validate:    "omitempty,url",
+		fallback:    func(config *config.Config) string { return config.Pub.UpstreamUrl },
+	},
+	{
+		key:         PresignTime,
+		valueType:   typeInt,
+		description: "lifetime of presigned download urls, in minutes",
+		validate:    "min=1",
+		fallback:    func(config *config.Config) string { return strconv.Itoa(config.Storage.PresignTime) },
+	},
+	{
+		key:         OtpExpiredTime,
+		valueType:   typeInt,
+		description: "lifetime of forgot password otps, in minutes",
+		validate:    "min=1",
+		fallback:    func(config *config.Config) string { return strconv.Itoa(config.User.OtpExpiredTime) },
+	},
+	{
+		key:         UploadSizeLimit,
+		valueType:   typeInt,
+		description: "max size of uploaded package archives, in megabytes, at most the body limit of the configuration",
+		validate:    "min=1",
+		fallback:    func(config *config.Config) string { return strconv.Itoa(config.App.BodyLimit) },
+	},
+	{
+		key:         MaintenanceMode,
+		valueType:   typeBool,
+		description: "refuse every change (uploads, token & user changes, ...) except the settings, reads keep working",
+		fallback:    func(config *config.Config) string { return "false" },
+	},
+	{
+		key:         TokenLifetime,
+		valueType:   typeInt,
+		description: "lifetime of pub tokens created without an expiry date, in days",
+		validate:    "min=1",
+		fallback:    func(config *config.Config) string { return "90" },
+	},
+	{
+		key:         LegacyPubTokens,
+		valueType:   typeBool,
+		description: "accept the jwt pub tokens created before the opaque `ppr_` ones, turn off once they expired or were recreated",
+		fallback:    func(config *config.Config) string { return "true" },
+	},
+}
+
+func findDefinition(key string) (*definition, bool) {
+	for i := range definitions {
+		if definitions[i].key == key {
+			return &definitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// typed = value converted to the setting type, values are validated before being stored so errors can't happen
+func (definition *definition) typed(value string) interface{} {
+	switch definition.valueType {
+	case typeInt:
+		typed, _ := strconv.Atoi(value)
+		return typed
+	case typeBool:
+		typed, _ := strconv.ParseBool(value)
+		return typed
+	default:
+		return value
+	}
+}
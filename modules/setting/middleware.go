@@ -0,0 +1,40 @@
+package setting
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminMiddleware = `user.UserJwtMiddleware`, declared here since the user module reads the settings
+type AdminMiddleware interface {
+	CanAccess(c *fiber.Ctx) error
+	IsAdmin(c *fiber.Ctx) error
+}
+
+// still reachable in maintenance mode, so an admin can log in and turn it off
+var maintenanceExemptPaths = []string{
+	"/" + basePath,
+	"/v1/users/login",
+	"/v1/users/refresh",
+}
+
+// maintenanceMiddleware refuses every change while the maintenance mode is on, reads keep working
+func (module *SettingModule) maintenanceMiddleware(c *fiber.Ctx) error {
+	if !module.Service.Bool(MaintenanceMode) {
+		return c.Next()
+	}
+
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return c.Next()
+	}
+
+	for _, path := range maintenanceExemptPaths {
+		if strings.HasPrefix(c.Path(), path) {
+			return c.Next()
+		}
+	}
+
+	return fiber.NewError(fiber.StatusServiceUnavailable, "Under maintenance, please try again later")
+}
@@ -0,0 +1,94 @@
+package setting
+
+import (
+	"context"
+	"private-pub-repo/base"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/jwt"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/setting/settingmodel"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/fx"
+)
+
+type SettingModule struct {
+	Service         SettingService
+	adminMiddleware AdminMiddleware
+	controller      *settingController
+	jwtService      jwt.JwtService
+	db              db.DbService
+	app             *fiber.App
+	config          config.ConfigService
+	monitorService  monitor.MonitorService
+	stopReload      chan struct{}
+}
+
+func NewModule(service SettingService, adminMiddleware AdminMiddleware, controller *settingController, jwtService jwt.JwtService, db db.DbService, app *fiber.App, config config.ConfigService, monitorService monitor.MonitorService) *SettingModule {
+	return &SettingModule{Service: service, adminMiddleware: adminMiddleware, controller: controller, jwtService: jwtService, db: db, app: app, config: config, monitorService: monitorService}
+}
+
+func fxRegister(lifeCycle fx.Lifecycle, module *SettingModule) {
+	base.FxRegister(module, lifeCycle)
+}
+
+// SetupModule takes the admin middleware as is, the user module needs the settings to be set up
+func SetupModule(app *app.AppModule, db *db.DbModule, jwt *jwt.JwtModule, monitor *monitor.MonitorModule, config *config.ConfigModule, adminMiddleware AdminMiddleware) *SettingModule {
+	service := NewSettingService(config, monitor.Service, app.Validator)
+	controller := newSettingController(service, app.ResponseService, app.Validator)
+	return NewModule(service, adminMiddleware, controller, jwt, db, app.App, config, monitor.Service)
+}
+
+var FxModule = fx.Module("Setting", fx.Provide(NewSettingService), fx.Provide(newSettingController), fx.Provide(NewModule), fx.Invoke(fxRegister))
+
+// startReload picks up the changes made on other instances
+func (module *SettingModule) startReload() {
+	module.stopReload = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(time.Duration(module.config.Config().Setting.RefreshInterval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := module.Service.Reload(context.Background()); err != nil {
+					module.monitorService.Logger().Error("settings reload failed", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(module.stopReload)
+}
+
+// implements `BaseModule` of `base/module.go` start
+
+func (module *SettingModule) OnStart() error {
+	if module.db.AutoMigrate() {
+		module.db.Default().AutoMigrate(&settingmodel.SettingModel{}, &settingmodel.SettingHistoryModel{})
+	}
+
+	if err := module.Service.Init(module.db); err != nil {
+		return err
+	}
+
+	// before the routes of the other modules, fiber runs handlers in registration order
+	module.app.Use(module.maintenanceMiddleware)
+	module.registerRoutes()
+	module.startReload()
+	return nil
+}
+
+func (module *SettingModule) OnStop() error {
+	if module.stopReload != nil {
+		close(module.stopReload)
+		module.stopReload = nil
+	}
+	return nil
+}
+
+// implements `BaseModule` of `base/module.go` end
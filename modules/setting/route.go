@@ -0,0 +1,15 @@
+package setting
+
+const (
+	basePath    = "v1/settings"
+	detailPath  = basePath + "/:key"
+	historyPath = detailPath + "/history"
+)
+
+func (module *SettingModule) registerRoutes() {
+	module.app.Get(basePath, module.jwtService.GetHandler(), module.adminMiddleware.CanAccess, module.adminMiddleware.IsAdmin, module.controller.handleList)
+	module.app.Get(detailPath, module.jwtService.GetHandler(), module.adminMiddleware.CanAccess, module.adminMiddleware.IsAdmin, module.controller.handleDetail)
+	module.app.Put(detailPath, module.jwtService.GetHandler(), module.adminMiddleware.CanAccess, module.adminMiddleware.IsAdmin, module.controller.handleUpdate)
+	module.app.Delete(detailPath, module.jwtService.GetHandler(), module.adminMiddleware.CanAccess, module.adminMiddleware.IsAdmin, module.controller.handleReset)
+	module.app.Get(historyPath, module.jwtService.GetHandler(), module.adminMiddleware.CanAccess, module.adminMiddleware.IsAdmin, module.controller.handleHistory)
+}
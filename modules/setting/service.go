@@ -0,0 +1,322 @@
+package setting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/setting/settingdto"
+	"private-pub-repo/modules/setting/settingmodel"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnknownSetting = errors.New("unknown setting")
+
+type SettingService interface {
+	Init(db db.DbService) error
+	// Reload = read the settings again, for changes made on other instances
+	Reload(context context.Context) error
+	String(key string) string
+	Int(key string) int
+	Bool(key string) bool
+	List(context context.Context) []settingdto.SettingDTO
+	Detail(context context.Context, key string) (*settingdto.SettingDTO, error)
+	Update(context context.Context, key string, value json.RawMessage, userId uuid.UUID) (*settingdto.SettingDTO, error)
+	// Reset = go back to the default of the configuration
+	Reset(context context.Context, key string, userId uuid.UUID) (*settingdto.SettingDTO, error)
+	History(context context.Context, req *appmodel.GetListRequest, key string) (*appmodel.PaginationResponseList, error)
+}
+
+type settingServiceImpl struct {
+	monitorService monitor.MonitorService
+	config         *config.Config
+	validator      *validator.Validate
+	db             *gorm.DB
+	// changed values by key, replaced as a whole so readers never lock
+	values atomic.Pointer[map[string]settingmodel.SettingModel]
+}
+
+func NewSettingService(config config.ConfigService, monitorService monitor.MonitorService, validator *validator.Validate) SettingService {
+	service := &settingServiceImpl{
+		monitorService: monitorService,
+		config:         config.Config(),
+		validator:      validator,
+	}
+	service.values.Store(&map[string]settingmodel.SettingModel{})
+	return service
+}
+
+func (service *settingServiceImpl) value(key string) string {
+	if setting, ok := (*service.values.Load())[key]; ok {
+		return setting.Value
+	}
+
+	if definition, ok := findDefinition(key); ok {
+		return definition.fallback(service.config)
+	}
+
+	return ""
+}
+
+func (service *settingServiceImpl) toDTO(definition *definition) settingdto.SettingDTO {
+	defaultValue := definition.fallback(service.config)
+	dto := settingdto.SettingDTO{
+		Key:         definition.key,
+		Type:        definition.valueType,
+		Description: definition.description,
+		Value:       definition.typed(defaultValue),
+		Default:     definition.typed(defaultValue),
+	}
+
+	if setting, ok := (*service.values.Load())[definition.key]; ok {
+		dto.Value = definition.typed(setting.Value)
+		dto.Overridden = true
+		dto.UpdatedBy = setting.UpdatedBy
+		dto.UpdatedAt = setting.UpdatedAt
+	}
+
+	return dto
+}
+
+// parse = the json value as stored, refused when it is not of the setting type or invalid
+func (service *settingServiceImpl) parse(definition *definition, value json.RawMessage) (string, error) {
+	var typed interface{}
+	var stored string
+
+	switch definition.valueType {
+	case typeInt:
+		var number int
+		if err := json.Unmarshal(value, &number); err != nil {
+			return "", fmt.Errorf("%s must be a whole number", definition.key)
+		}
+		typed, stored = number, strconv.Itoa(number)
+	case typeBool:
+		var flag bool
+		if err := json.Unmarshal(value, &flag); err != nil {
+			return "", fmt.Errorf("%s must be true or false", definition.key)
+		}
+		typed, stored = flag, strconv.FormatBool(flag)
+	default:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return "", fmt.Errorf("%s must be a string", definition.key)
+		}
+		typed, stored = text, text
+	}
+
+	rules := definition.validate
+	// bigger archives would already be refused by the body limit
+	if definition.key == UploadSizeLimit {
+		rules += ",max=" + strconv.Itoa(service.config.App.BodyLimit)
+	}
+
+	if rules != "" {
+		if err := service.validator.Var(typed, rules); err != nil {
+			return "", fmt.Errorf("%s is invalid, expected %s", definition.key, rules)
+		}
+	}
+
+	return stored, nil
+}
+
+// save = change a setting and record it in the history, nil value goes back to the default
+func (service *settingServiceImpl) save(context context.Context, definition *definition, value *string, userId uuid.UUID) error {
+	err := service.db.WithContext(context).Transaction(func(tx *gorm.DB) error {
+		var current settingmodel.SettingModel
+		var oldValue *string
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&settingmodel.SettingModel{Key: definition.key}).Limit(1).Find(&current)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			oldValue = &current.Value
+		}
+
+		var err error
+
+		if value == nil {
+			err = tx.Delete(&settingmodel.SettingModel{Key: definition.key}).Error
+		} else {
+			now := time.Now()
+			err = tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+			}).Create(&settingmodel.SettingModel{Key: definition.key, Value: *value, UpdatedBy: &userId, UpdatedAt: &now}).Error
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&settingmodel.SettingHistoryModel{
+			Key:       definition.key,
+			OldValue:  oldValue,
+			NewValue:  value,
+			ChangedBy: &userId,
+		}).Error
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return service.Reload(context)
+}
+
+// impl `SettingService` start
+
+func (service *settingServiceImpl) Init(db db.DbService) error {
+	service.db = db.Default()
+	return service.Reload(context.Background())
+}
+
+func (service *settingServiceImpl) Reload(context context.Context) error {
+	settings := []settingmodel.SettingModel{}
+
+	if err := service.db.WithContext(context).Find(&settings).Error; err != nil {
+		return err
+	}
+
+	values := map[string]settingmodel.SettingModel{}
+	for _, setting := range settings {
+		values[setting.Key] = setting
+	}
+	service.values.Store(&values)
+
+	return nil
+}
+
+func (service *settingServiceImpl) String(key string) string {
+	return service.value(key)
+}
+
+func (service *settingServiceImpl) Int(key string) int {
+	value, err := strconv.Atoi(service.value(key))
+	if err != nil {
+		// only possible when the row was edited by hand
+		definition, _ := findDefinition(key)
+		value, _ = strconv.Atoi(definition.fallback(service.config))
+	}
+	return value
+}
+
+func (service *settingServiceImpl) Bool(key string) bool {
+	return service.value(key) == "true"
+}
+
+func (service *settingServiceImpl) List(context context.Context) []settingdto.SettingDTO {
+	_, span := service.monitorService.StartTraceSpan(context, "SettingService.List", map[string]interface{}{})
+	defer span.End()
+
+	settings := []settingdto.SettingDTO{}
+	for i := range definitions {
+		settings = append(settings, service.toDTO(&definitions[i]))
+	}
+	return settings
+}
+
+func (service *settingServiceImpl) Detail(context context.Context, key string) (*settingdto.SettingDTO, error) {
+	_, span := service.monitorService.StartTraceSpan(context, "SettingService.Detail", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	definition, ok := findDefinition(key)
+	if !ok {
+		return nil, ErrUnknownSetting
+	}
+
+	setting := service.toDTO(definition)
+	return &setting, nil
+}
+
+func (service *settingServiceImpl) Update(context context.Context, key string, value json.RawMessage, userId uuid.UUID) (*settingdto.SettingDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "SettingService.Update", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	definition, ok := findDefinition(key)
+	if !ok {
+		return nil, ErrUnknownSetting
+	}
+
+	stored, err := service.parse(definition, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = service.save(spanContext, definition, &stored, userId); err != nil {
+		return nil, err
+	}
+
+	return service.Detail(context, key)
+}
+
+func (service *settingServiceImpl) Reset(context context.Context, key string, userId uuid.UUID) (*settingdto.SettingDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "SettingService.Reset", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	definition, ok := findDefinition(key)
+	if !ok {
+		return nil, ErrUnknownSetting
+	}
+
+	if err := service.save(spanContext, definition, nil, userId); err != nil {
+		return nil, err
+	}
+
+	return service.Detail(context, key)
+}
+
+func (service *settingServiceImpl) History(context context.Context, req *appmodel.GetListRequest, key string) (*appmodel.PaginationResponseList, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "SettingService.History", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	if _, ok := findDefinition(key); !ok {
+		return nil, ErrUnknownSetting
+	}
+
+	var count int64
+	histories := []settingmodel.SettingHistoryModel{}
+	query := service.db.WithContext(spanContext).Model(&histories).Where(&settingmodel.SettingHistoryModel{Key: key})
+
+	if err := query.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.Session(&gorm.Session{}).Order("created_at desc").
+		Limit(req.Limit).Offset((req.Page - 1) * req.Limit).Find(&histories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	count32 := int(count)
+
+	return &appmodel.PaginationResponseList{
+		Pagination: &appmodel.PaginationResponsePagination{
+			Page:  &req.Page,
+			Size:  &req.Limit,
+			Total: &count32,
+		},
+		Content: histories,
+	}, nil
+}
+
+// impl `SettingService` end
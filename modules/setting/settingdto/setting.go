@@ -0,0 +1,25 @@
+package settingdto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SettingDTO struct {
+	Key         string      `json:"key"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Value       interface{} `json:"value"`
+	Default     interface{} `json:"default"`
+	// false when the default is used
+	Overridden bool       `json:"overridden"`
+	UpdatedBy  *uuid.UUID `json:"updated_by"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+type UpdateSettingDTO struct {
+	// json value of the setting type, e.g. `"https://pub.dev"`, `15` or `true`
+	Value json.RawMessage `json:"value" validate:"required"`
+}
@@ -0,0 +1,33 @@
+package settingmodel
+
+import (
+	"private-pub-repo/base"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SettingModel = value of a setting changed at runtime, settings without a row use their default
+type SettingModel struct {
+	Key       string     `json:"key" gorm:"not null;primaryKey;"`
+	Value     string     `json:"value" gorm:"not null;"`
+	UpdatedBy *uuid.UUID `json:"updated_by" gorm:"type:uuid;nullable;"`
+	UpdatedAt *time.Time `json:"updated_at" gorm:"not null;"`
+}
+
+func (SettingModel) TableName() string {
+	return "settings"
+}
+
+// SettingHistoryModel = a single change of a setting, nil values are the default
+type SettingHistoryModel struct {
+	base.BaseModel
+	Key       string     `json:"key" gorm:"not null;index"`
+	OldValue  *string    `json:"old_value" gorm:"nullable;"`
+	NewValue  *string    `json:"new_value" gorm:"nullable;"`
+	ChangedBy *uuid.UUID `json:"changed_by" gorm:"type:uuid;nullable;"`
+}
+
+func (SettingHistoryModel) TableName() string {
+	return "setting_histories"
+}
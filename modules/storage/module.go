@@ -3,7 +3,8 @@ package storage
 import (
 	"private-pub-repo/base"
 	"private-pub-repo/modules/config"
-	"strconv"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/setting"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -14,24 +15,29 @@ import (
 )
 
 type StorageModule struct {
-	s3            *s3.S3
-	s3Public      *s3.S3
-	uploader      *s3manager.Uploader
-	bucket        string
-	enablePresign bool
-	presignTime   int
+	monitorService monitor.MonitorService
+	s3             *s3.S3
+	s3Public       *s3.S3
+	uploader       *s3manager.Uploader
+	bucket         string
+	enablePresign  bool
+	settingService setting.SettingService
 }
 
-func NewModule(config config.ConfigService) *StorageModule {
-	endpoint := aws.String(config.Getenv("S3_ENDPOINT", ""))
-	publicEndpoint := aws.String(config.Getenv("S3_PUBLIC_ENDPOINT", *endpoint))
-	region := aws.String(config.Getenv("S3_REGION", ""))
+func NewModule(config config.ConfigService, monitorService monitor.MonitorService, settingService setting.SettingService) *StorageModule {
+	storageConfig := config.Config().Storage
+	endpoint := aws.String(storageConfig.Endpoint)
+	publicEndpoint := aws.String(storageConfig.PublicEndpoint)
+	if storageConfig.PublicEndpoint == "" {
+		publicEndpoint = endpoint
+	}
+	region := aws.String(storageConfig.Region)
 	credentials := credentials.NewStaticCredentials(
-		config.Getenv("S3_KEY_ID", ""),
-		config.Getenv("S3_ACCESS_KEY", ""),
+		storageConfig.KeyId,
+		storageConfig.AccessKey,
 		"",
 	)
-	usePathStyle := aws.Bool(config.Getenv("S3_USE_PATH_STYLE", "") == "true")
+	usePathStyle := aws.Bool(storageConfig.UsePathStyle)
 
 	s3Session, err := session.NewSession(&aws.Config{
 		Endpoint:         endpoint,
@@ -55,19 +61,14 @@ func NewModule(config config.ConfigService) *StorageModule {
 		panic(err)
 	}
 
-	presignTime, err := strconv.Atoi(config.Getenv("S3_PRESIGN_TIME", "15"))
-
-	if err != nil {
-		presignTime = 15
-	}
-
 	return &StorageModule{
-		s3:            s3.New(s3Session),
-		s3Public:      s3.New(s3PublicSession),
-		uploader:      s3manager.NewUploader(s3Session),
-		bucket:        config.Getenv("S3_BUCKET", ""),
-		enablePresign: config.Getenv("S3_ENABLE_PRESIGN", "false") == "true",
-		presignTime:   presignTime,
+		monitorService: monitorService,
+		s3:             s3.New(s3Session),
+		s3Public:       s3.New(s3PublicSession),
+		uploader:       s3manager.NewUploader(s3Session),
+		bucket:         storageConfig.Bucket,
+		enablePresign:  storageConfig.EnablePresign,
+		settingService: settingService,
 	}
 }
 
@@ -79,8 +80,8 @@ func fxRegister(lifeCycle fx.Lifecycle, module *StorageModule) {
 	base.FxRegister(module, lifeCycle)
 }
 
-func SetupModule(config *config.ConfigModule) *StorageModule {
-	return NewModule(config)
+func SetupModule(config *config.ConfigModule, monitor *monitor.MonitorModule, setting *setting.SettingModule) *StorageModule {
+	return NewModule(config, monitor.Service, setting.Service)
 }
 
 var FxModule = fx.Module("Storage", fx.Provide(NewModule), fx.Provide(ProvideService), fx.Invoke(fxRegister))
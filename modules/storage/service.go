@@ -1,46 +1,212 @@
 package storage
 
 import (
-	"mime/multipart"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"private-pub-repo/modules/setting"
+	"private-pub-repo/modules/storage/storagemodel"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/private/protocol/rest"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
+var ErrNotFound = errors.New("storage object not found")
+
 type StorageService interface {
-	Upload(key string, file *multipart.FileHeader) error
-	GetUrl(key string) string
+	Upload(context context.Context, key string, reader io.Reader) error
+	Download(context context.Context, key string) (io.ReadCloser, error)
+	Stat(context context.Context, key string) (*storagemodel.StorageObject, error)
+	Delete(context context.Context, key string) error
+	Copy(context context.Context, sourceKey string, destinationKey string) error
+	List(context context.Context, prefix string) ([]storagemodel.StorageObject, error)
+	GetUrl(context context.Context, key string) string
+	// Ping = check the bucket can be reached with the configured credentials
+	Ping(context context.Context) error
+}
+
+// convert s3 "not found" errors into `ErrNotFound`, so callers don't need to know about aws error codes
+func mapStorageError(err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+// copy source must be url encoded, but the separator between bucket & key segments must stay as is
+func escapeCopySource(bucket string, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
 }
 
 // impl `StorageService` start
 
-func (storage *StorageModule) Upload(key string, file *multipart.FileHeader) error {
-	reader, err := file.Open()
+func (storage *StorageModule) Upload(context context.Context, key string, reader io.Reader) error {
+	spanContext, span := storage.monitorService.StartTraceSpan(context, "StorageService.Upload", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	start := time.Now()
+	_, err := storage.uploader.UploadWithContext(spanContext, &s3manager.UploadInput{
+		Bucket: &storage.bucket,
+		Key:    &key,
+		Body:   reader,
+	})
+	storage.monitorService.RecordStorageOperation(spanContext, "upload", start, err)
+
+	return err
+}
+
+func (storage *StorageModule) Download(context context.Context, key string) (io.ReadCloser, error) {
+	spanContext, span := storage.monitorService.StartTraceSpan(context, "StorageService.Download", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	start := time.Now()
+	output, err := storage.s3.GetObjectWithContext(spanContext, &s3.GetObjectInput{
+		Bucket: &storage.bucket,
+		Key:    &key,
+	})
+	storage.monitorService.RecordStorageOperation(spanContext, "download", start, err)
 
 	if err != nil {
-		return err
+		return nil, mapStorageError(err)
 	}
-	defer reader.Close()
 
-	_, err = storage.uploader.Upload(&s3manager.UploadInput{
+	return output.Body, nil
+}
+
+func (storage *StorageModule) Stat(context context.Context, key string) (*storagemodel.StorageObject, error) {
+	spanContext, span := storage.monitorService.StartTraceSpan(context, "StorageService.Stat", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	start := time.Now()
+	output, err := storage.s3.HeadObjectWithContext(spanContext, &s3.HeadObjectInput{
 		Bucket: &storage.bucket,
 		Key:    &key,
-		Body:   reader,
 	})
+	storage.monitorService.RecordStorageOperation(spanContext, "stat", start, err)
 
-	return err
+	if err != nil {
+		return nil, mapStorageError(err)
+	}
+
+	object := storagemodel.StorageObject{
+		Key:          key,
+		LastModified: output.LastModified,
+	}
+	if output.ContentLength != nil {
+		object.Size = *output.ContentLength
+	}
+	if output.ETag != nil {
+		object.ETag = *output.ETag
+	}
+
+	return &object, nil
+}
+
+func (storage *StorageModule) Delete(context context.Context, key string) error {
+	spanContext, span := storage.monitorService.StartTraceSpan(context, "StorageService.Delete", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
+	start := time.Now()
+	_, err := storage.s3.DeleteObjectWithContext(spanContext, &s3.DeleteObjectInput{
+		Bucket: &storage.bucket,
+		Key:    &key,
+	})
+	storage.monitorService.RecordStorageOperation(spanContext, "delete", start, err)
+
+	return mapStorageError(err)
 }
 
-func (storage *StorageModule) GetUrl(key string) string {
+func (storage *StorageModule) Copy(context context.Context, sourceKey string, destinationKey string) error {
+	spanContext, span := storage.monitorService.StartTraceSpan(context, "StorageService.Copy", map[string]interface{}{
+		"source_key":      sourceKey,
+		"destination_key": destinationKey,
+	})
+	defer span.End()
+
+	copySource := escapeCopySource(storage.bucket, sourceKey)
+	start := time.Now()
+	_, err := storage.s3.CopyObjectWithContext(spanContext, &s3.CopyObjectInput{
+		Bucket:     &storage.bucket,
+		CopySource: &copySource,
+		Key:        &destinationKey,
+	})
+	storage.monitorService.RecordStorageOperation(spanContext, "copy", start, err)
+
+	return mapStorageError(err)
+}
+
+func (storage *StorageModule) List(context context.Context, prefix string) ([]storagemodel.StorageObject, error) {
+	spanContext, span := storage.monitorService.StartTraceSpan(context, "StorageService.List", map[string]interface{}{
+		"prefix": prefix,
+	})
+	defer span.End()
+
+	objects := []storagemodel.StorageObject{}
+	start := time.Now()
+	err := storage.s3.ListObjectsV2PagesWithContext(spanContext, &s3.ListObjectsV2Input{
+		Bucket: &storage.bucket,
+		Prefix: &prefix,
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, item := range page.Contents {
+			object := storagemodel.StorageObject{
+				LastModified: item.LastModified,
+			}
+			if item.Key != nil {
+				object.Key = *item.Key
+			}
+			if item.Size != nil {
+				object.Size = *item.Size
+			}
+			if item.ETag != nil {
+				object.ETag = *item.ETag
+			}
+			objects = append(objects, object)
+		}
+		return true
+	})
+	storage.monitorService.RecordStorageOperation(spanContext, "list", start, err)
+
+	if err != nil {
+		return nil, mapStorageError(err)
+	}
+
+	return objects, nil
+}
+
+func (storage *StorageModule) GetUrl(context context.Context, key string) string {
+	_, span := storage.monitorService.StartTraceSpan(context, "StorageService.GetUrl", map[string]interface{}{
+		"key": key,
+	})
+	defer span.End()
+
 	req, _ := storage.s3Public.GetObjectRequest(&s3.GetObjectInput{
 		Bucket: &storage.bucket,
 		Key:    &key,
 	})
 
 	if storage.enablePresign {
-		urlStr, err := req.Presign(15 * time.Minute)
+		urlStr, err := req.Presign(time.Duration(storage.settingService.Int(setting.PresignTime)) * time.Minute)
 
 		if err == nil {
 			return urlStr
@@ -52,4 +218,17 @@ func (storage *StorageModule) GetUrl(key string) string {
 	return req.HTTPRequest.URL.String()
 }
 
+func (storage *StorageModule) Ping(context context.Context) error {
+	spanContext, span := storage.monitorService.StartTraceSpan(context, "StorageService.Ping", map[string]interface{}{})
+	defer span.End()
+
+	start := time.Now()
+	_, err := storage.s3.HeadBucketWithContext(spanContext, &s3.HeadBucketInput{
+		Bucket: &storage.bucket,
+	})
+	storage.monitorService.RecordStorageOperation(spanContext, "ping", start, err)
+
+	return err
+}
+
 // impl `StorageService` end
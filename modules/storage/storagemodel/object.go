@@ -0,0 +1,10 @@
+package storagemodel
+
+import "time"
+
+type StorageObject struct {
+	Key          string     `json:"key"`
+	Size         int64      `json:"size"`
+	ETag         string     `json:"etag,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+}
@@ -0,0 +1,141 @@
+package trustedpublisher
+
+import (
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/trustedpublisher/trustedpublisherdto"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	validationError = "Validation Error"
+)
+
+type trustedPublisherController struct {
+	service         TrustedPublisherService
+	responseService app.ResponseService
+	validator       *validator.Validate
+}
+
+func newTrustedPublisherController(service TrustedPublisherService, responseService app.ResponseService, validator *validator.Validate) *trustedPublisherController {
+	return &trustedPublisherController{
+		service:         service,
+		responseService: responseService,
+		validator:       validator,
+	}
+}
+
+// handlers start
+
+func (controller *trustedPublisherController) handleCreate(ctx *fiber.Ctx) error {
+	request := trustedpublisherdto.SaveTrustedPublisherDTO{}
+	ctx.BodyParser(&request)
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	trustedPublisher, err := controller.service.Insert(ctx.UserContext(), request.ToModel())
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	return controller.responseService.SendSuccessDetailResponse(ctx, 201, trustedPublisher)
+}
+
+func (controller *trustedPublisherController) handleList(ctx *fiber.Ctx) error {
+	request := appmodel.NewGetListRequest(ctx.Query("page"), ctx.Query("limit"), ctx.Query("search"))
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	list, err := controller.service.List(ctx.UserContext(), request, ctx.Query("package"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	return controller.responseService.SendSuccessResponse(ctx, 200, appmodel.PaginationResponse{
+		List: list,
+	})
+}
+
+func (controller *trustedPublisherController) handleDetail(ctx *fiber.Ctx) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	trustedPublisher, err := controller.service.Detail(ctx.UserContext(), id)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, trustedPublisher)
+}
+
+func (controller *trustedPublisherController) handleUpdate(ctx *fiber.Ctx) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	request := trustedpublisherdto.SaveTrustedPublisherDTO{}
+	ctx.BodyParser(&request)
+	err = controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	trustedPublisher, err := controller.service.Update(ctx.UserContext(), id, request.ToModel())
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, trustedPublisher)
+}
+
+func (controller *trustedPublisherController) handleDelete(ctx *fiber.Ctx) error {
+	id, err := uuid.Parse(ctx.Params("id"))
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	err = controller.service.Delete(ctx.UserContext(), id)
+
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, nil)
+}
+
+func (controller *trustedPublisherController) handleExchange(ctx *fiber.Ctx) error {
+	request := trustedpublisherdto.ExchangeDTO{}
+	ctx.BodyParser(&request)
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	response, err := controller.service.Exchange(ctx.UserContext(), &request)
+
+	if err != nil {
+		return err
+	}
+
+	return controller.responseService.SendSuccessDetailResponse(ctx, 201, response)
+}
+
+// handlers end
@@ -0,0 +1,62 @@
+package trustedpublisher
+
+import (
+	"private-pub-repo/base"
+	"private-pub-repo/modules/app"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/jwt"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
+	"private-pub-repo/modules/user"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/fx"
+)
+
+// TrustedPublisherModule = publishing from ci without stored tokens, its oidc tokens are exchanged for short lived pub tokens
+type TrustedPublisherModule struct {
+	Service        TrustedPublisherService
+	userMiddleware user.UserJwtMiddleware
+	controller     *trustedPublisherController
+	jwtService     jwt.JwtService
+	db             db.DbService
+	app            *fiber.App
+	rateLimit      ratelimit.RateLimitService
+}
+
+func NewModule(service TrustedPublisherService, controller *trustedPublisherController, jwtService jwt.JwtService, db db.DbService, userMiddleware user.UserJwtMiddleware, app *fiber.App, rateLimit ratelimit.RateLimitService) *TrustedPublisherModule {
+	return &TrustedPublisherModule{Service: service, userMiddleware: userMiddleware, jwtService: jwtService, controller: controller, db: db, app: app, rateLimit: rateLimit}
+}
+
+func fxRegister(lifeCycle fx.Lifecycle, module *TrustedPublisherModule) {
+	base.FxRegister(module, lifeCycle)
+}
+
+func SetupModule(app *app.AppModule, db *db.DbModule, user *user.UserModule, pubToken *pubtoken.PubTokenModule, jwt *jwt.JwtModule, monitor *monitor.MonitorModule, config *config.ConfigModule, rateLimit *ratelimit.RateLimitModule) *TrustedPublisherModule {
+	service := NewTrustedPublisherService(pubToken.Service, monitor.Service, config)
+	controller := newTrustedPublisherController(service, app.ResponseService, app.Validator)
+	return NewModule(service, controller, jwt, db, user.Middleware, app.App, rateLimit.Service)
+}
+
+var FxModule = fx.Module("TrustedPublisher", fx.Provide(NewTrustedPublisherService), fx.Provide(newTrustedPublisherController), fx.Provide(NewModule), fx.Invoke(fxRegister))
+
+// implements `BaseModule` of `base/module.go` start
+
+func (module *TrustedPublisherModule) OnStart() error {
+	if module.db.AutoMigrate() {
+		module.db.Default().AutoMigrate(&trustedpublishermodel.TrustedPublisherModel{}, &trustedpublishermodel.TrustedPublisherExchangeModel{})
+	}
+
+	module.Service.Init(module.db)
+	module.registerRoutes()
+	return nil
+}
+
+func (module *TrustedPublisherModule) OnStop() error {
+	return nil
+}
+
+// implements `BaseModule` of `base/module.go` end
@@ -0,0 +1,32 @@
+package trustedpublisher
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matchPattern = value matches pattern, where `*` matches any text (`/` & `:` included), the rest is literal
+func matchPattern(pattern string, value string) bool {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+
+	matched, err := regexp.MatchString("^"+strings.Join(parts, ".*")+"$", value)
+	return err == nil && matched
+}
+
+// claimString = claim as text for matchPattern, false for objects & lists
+func claimString(claim interface{}) (string, bool) {
+	switch value := claim.(type) {
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+
+	return "", false
+}
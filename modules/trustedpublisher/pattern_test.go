@@ -0,0 +1,60 @@
+package trustedpublisher
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		value   string
+		matched bool
+	}{
+		{"repo:acme/app:ref:refs/heads/main", "repo:acme/app:ref:refs/heads/main", true},
+		{"repo:acme/app:ref:refs/heads/main", "repo:acme/app:ref:refs/heads/main2", false},
+		{"repo:acme/app:ref:refs/heads/main", "xrepo:acme/app:ref:refs/heads/main", false},
+		// `*` matches `/` & `:` too
+		{"repo:acme/app:*", "repo:acme/app:ref:refs/tags/v1.0.0", true},
+		{"repo:acme/app:*", "repo:acme/app-fork:ref:refs/heads/main", false},
+		{"repo:acme/*:environment:release", "repo:acme/app:environment:release", true},
+		{"repo:acme/*:environment:release", "repo:acme/app:environment:staging", false},
+		{"refs/tags/v*", "refs/tags/v2.1.0", true},
+		{"refs/tags/v*", "refs/heads/v2", false},
+		{"*", "anything:at/all", true},
+		{"*", "", true},
+		{"", "", true},
+		{"", "repo:acme/app", false},
+		// the rest is literal, regexp characters included
+		{"acme/app.v1", "acme/appxv1", false},
+		{"acme/app.v1", "acme/app.v1", true},
+		{"acme/(app|other)", "acme/other", false},
+		{"acme/[a-z]+", "acme/app", false},
+		{"acme/app^$", "acme/app^$", true},
+	}
+
+	for _, testCase := range cases {
+		if matched := matchPattern(testCase.pattern, testCase.value); matched != testCase.matched {
+			t.Errorf("matchPattern(%q, %q) = %v, expected %v", testCase.pattern, testCase.value, matched, testCase.matched)
+		}
+	}
+}
+
+func TestClaimString(t *testing.T) {
+	cases := []struct {
+		claim interface{}
+		value string
+		ok    bool
+	}{
+		{"acme/app", "acme/app", true},
+		{float64(1234567890), "1234567890", true},
+		{1.5, "1.5", true},
+		{true, "true", true},
+		{nil, "", false},
+		{[]interface{}{"acme/app"}, "", false},
+		{map[string]interface{}{"name": "acme/app"}, "", false},
+	}
+
+	for _, testCase := range cases {
+		if value, ok := claimString(testCase.claim); value != testCase.value || ok != testCase.ok {
+			t.Errorf("claimString(%v) = %q, %v, expected %q, %v", testCase.claim, value, ok, testCase.value, testCase.ok)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package trustedpublisher
+
+const (
+	basePath     = "v1/trusted-publishers"
+	detailPath   = basePath + "/:id"
+	exchangePath = basePath + "/exchange"
+)
+
+func (module *TrustedPublisherModule) registerRoutes() {
+	module.app.Post(exchangePath, module.rateLimit.Pub(), module.controller.handleExchange)
+
+	module.app.Get(basePath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleList)
+	module.app.Post(basePath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleCreate)
+	module.app.Get(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleDetail)
+	module.app.Put(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleUpdate)
+	module.app.Delete(detailPath, module.jwtService.GetHandler(), module.userMiddleware.CanAccess, module.userMiddleware.IsAdmin, module.controller.handleDelete)
+}
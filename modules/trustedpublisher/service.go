@@ -0,0 +1,312 @@
+package trustedpublisher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"private-pub-repo/modules/app/appmodel"
+	"private-pub-repo/modules/config"
+	"private-pub-repo/modules/db"
+	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/oidc"
+	"private-pub-repo/modules/pubtoken"
+	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/trustedpublisher/trustedpublisherdto"
+	"private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
+	"private-pub-repo/utils"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// longer than the clock leeway of `oidc.Verifier`, an exchanged token is remembered until nobody accepts it anymore
+const exchangeLeeway = time.Minute
+
+// the same for every refusal, the trusted publishers of a package aren't told to whoever asks
+var errNoTrustedPublisher = fiber.NewError(401, "Unauthenticated, the token doesn't match a trusted publisher of the package")
+
+type TrustedPublisherService interface {
+	Init(db db.DbService)
+	Insert(context context.Context, trustedPublisher *trustedpublishermodel.TrustedPublisherModel) (*trustedpublishermodel.TrustedPublisherModel, error)
+	// List = the trusted publishers, of packageName when not empty
+	List(context context.Context, req *appmodel.GetListRequest, packageName string) (*appmodel.PaginationResponseList, error)
+	Detail(context context.Context, id uuid.UUID) (*trustedpublishermodel.TrustedPublisherModel, error)
+	Update(context context.Context, id uuid.UUID, trustedPublisher *trustedpublishermodel.TrustedPublisherModel) (*trustedpublishermodel.TrustedPublisherModel, error)
+	Delete(context context.Context, id uuid.UUID) error
+	// Exchange = pub token limited to the package, for an oidc token matching one of its trusted publishers
+	Exchange(context context.Context, req *trustedpublisherdto.ExchangeDTO) (*trustedpublisherdto.ExchangeResponseDTO, error)
+}
+
+type trustedPublisherServiceImpl struct {
+	monitorService  monitor.MonitorService
+	pubTokenService pubtoken.PubTokenService
+	config          *config.TrustedPublishingConfig
+	verifier        *oidc.Verifier
+	db              *gorm.DB
+}
+
+func NewTrustedPublisherService(pubTokenService pubtoken.PubTokenService, monitorService monitor.MonitorService, config config.ConfigService) TrustedPublisherService {
+	trustedPublishing := &config.Config().TrustedPublishing
+
+	return &trustedPublisherServiceImpl{
+		monitorService:  monitorService,
+		pubTokenService: pubTokenService,
+		config:          trustedPublishing,
+		verifier:        oidc.NewVerifier(time.Duration(trustedPublishing.JwksRefresh)*time.Second, trustedPublishing.AllowHttp),
+	}
+}
+
+// exchangeHash = the oidc token in `trusted_publisher_exchanges`, its issuer & jti, or the whole token without jti
+func exchangeHash(issuer string, token string, claims jwtlib.MapClaims) string {
+	id, _ := claims["jti"].(string)
+	if id == "" {
+		id = token
+	}
+
+	hash := sha256.Sum256([]byte(issuer + "\n" + id))
+	return hex.EncodeToString(hash[:])
+}
+
+// matches = the verified claims are accepted by trustedPublisher
+func matches(trustedPublisher *trustedpublishermodel.TrustedPublisherModel, claims jwtlib.MapClaims) bool {
+	audience, err := claims.GetAudience()
+	if err != nil || !slices.Contains(audience, trustedPublisher.Audience) {
+		return false
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || !matchPattern(trustedPublisher.Subject, subject) {
+		return false
+	}
+
+	for name, pattern := range trustedPublisher.Claims {
+		value, ok := claimString(claims[name])
+		if !ok || !matchPattern(pattern, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// impl `TrustedPublisherService` start
+
+func (service *trustedPublisherServiceImpl) Init(db db.DbService) {
+	service.db = db.Default()
+}
+
+func (service *trustedPublisherServiceImpl) Insert(context context.Context, trustedPublisher *trustedpublishermodel.TrustedPublisherModel) (*trustedpublishermodel.TrustedPublisherModel, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "TrustedPublisherService.Insert", map[string]interface{}{
+		"package": trustedPublisher.Package,
+	})
+	defer span.End()
+
+	if err := service.verifier.CheckUrl(trustedPublisher.Issuer); err != nil {
+		return nil, err
+	}
+
+	result := service.db.WithContext(spanContext).Create(trustedPublisher)
+	return trustedPublisher, result.Error
+}
+
+func (service *trustedPublisherServiceImpl) List(context context.Context, req *appmodel.GetListRequest, packageName string) (*appmodel.PaginationResponseList, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "TrustedPublisherService.List", utils.StructToMap(req))
+	defer span.End()
+	var count int64
+	trustedPublishers := []trustedpublishermodel.TrustedPublisherModel{}
+	query := service.db.WithContext(spanContext).Model(trustedPublishers)
+	if packageName != "" {
+		query.Where("package = ?", packageName)
+	}
+	if req.Search != "" {
+		query.Where(service.db.Where(utils.InsensitiveLike("package"), utils.ContainsPattern(req.Search)).
+			Or(utils.InsensitiveLike("remarks"), utils.ContainsPattern(req.Search)))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Perform count and find concurrently using goroutines
+	errChan := make(chan error, 2)
+	go func() {
+		defer wg.Done()
+		errChan <- query.Session(&gorm.Session{}).Count(&count).Error
+	}()
+
+	go func() {
+		defer wg.Done()
+		query = query.Session(&gorm.Session{})
+		errChan <- query.Limit(req.Limit).Offset((req.Page - 1) * req.Limit).Find(&trustedPublishers).Error
+	}()
+
+	wg.Wait()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-errChan:
+			if err != nil {
+				return nil, err
+			}
+		default:
+		}
+	}
+
+	count32 := int(count)
+
+	return &appmodel.PaginationResponseList{
+		Pagination: &appmodel.PaginationResponsePagination{
+			Page:  &req.Page,
+			Size:  &req.Limit,
+			Total: &count32,
+		},
+		Content: trustedPublishers,
+	}, nil
+}
+
+func (service *trustedPublisherServiceImpl) Detail(context context.Context, id uuid.UUID) (*trustedpublishermodel.TrustedPublisherModel, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "TrustedPublisherService.Detail", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+	var trustedPublisher trustedpublishermodel.TrustedPublisherModel
+	result := service.db.WithContext(spanContext).First(&trustedPublisher, id)
+	return &trustedPublisher, result.Error
+}
+
+func (service *trustedPublisherServiceImpl) Update(context context.Context, id uuid.UUID, trustedPublisher *trustedpublishermodel.TrustedPublisherModel) (*trustedpublishermodel.TrustedPublisherModel, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "TrustedPublisherService.Update", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+
+	if err := service.verifier.CheckUrl(trustedPublisher.Issuer); err != nil {
+		return nil, err
+	}
+
+	// every field is replaced, emptied claims & remarks included
+	result := service.db.WithContext(spanContext).Model(&trustedpublishermodel.TrustedPublisherModel{}).Where("id = ?", id).
+		Select("package", "issuer", "audience", "subject", "claims", "remarks").Updates(trustedPublisher)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return service.Detail(context, id)
+}
+
+func (service *trustedPublisherServiceImpl) Delete(context context.Context, id uuid.UUID) error {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "TrustedPublisherService.Delete", map[string]interface{}{
+		"id": id.String(),
+	})
+	defer span.End()
+	result := service.db.WithContext(spanContext).Delete(&trustedpublishermodel.TrustedPublisherModel{}, id)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (service *trustedPublisherServiceImpl) Exchange(context context.Context, req *trustedpublisherdto.ExchangeDTO) (*trustedpublisherdto.ExchangeResponseDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "TrustedPublisherService.Exchange", map[string]interface{}{
+		"package": req.Package,
+	})
+	defer span.End()
+	logger := service.monitorService.Logger()
+
+	// the issuer picks the trusted publishers & the keys, the token is verified right after
+	unverified := jwtlib.MapClaims{}
+	if _, _, err := jwtlib.NewParser().ParseUnverified(req.Token, unverified); err != nil {
+		logger.InfoContext(spanContext, "trusted publishing refused", "package", req.Package, "reason", "malformed")
+		return nil, errNoTrustedPublisher
+	}
+	issuer, _ := unverified.GetIssuer()
+
+	trustedPublishers := []trustedpublishermodel.TrustedPublisherModel{}
+	err := service.db.WithContext(spanContext).Where("package = ?", req.Package).Where("issuer = ?", issuer).Find(&trustedPublishers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(trustedPublishers) == 0 {
+		logger.InfoContext(spanContext, "trusted publishing refused", "package", req.Package, "issuer", issuer, "reason", "no_trusted_publisher")
+		return nil, errNoTrustedPublisher
+	}
+
+	claims, err := service.verifier.Verify(spanContext, req.Token, issuer)
+	if err != nil {
+		logger.InfoContext(spanContext, "trusted publishing refused", "package", req.Package, "issuer", issuer, "reason", "invalid", "error", err)
+		return nil, errNoTrustedPublisher
+	}
+
+	subject, _ := claims.GetSubject()
+	index := slices.IndexFunc(trustedPublishers, func(trustedPublisher trustedpublishermodel.TrustedPublisherModel) bool {
+		return matches(&trustedPublisher, claims)
+	})
+
+	if index < 0 {
+		logger.InfoContext(spanContext, "trusted publishing refused", "package", req.Package, "issuer", issuer, "subject", subject, "reason", "no_match")
+		return nil, errNoTrustedPublisher
+	}
+
+	// once only, like the sso states. kept past the expiry by the leeway the verifier gives the clocks
+	expiration, err := claims.GetExpirationTime()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	service.db.WithContext(spanContext).Where("expired_at < ?", now).Delete(&trustedpublishermodel.TrustedPublisherExchangeModel{})
+
+	result := service.db.WithContext(spanContext).Clauses(clause.OnConflict{DoNothing: true}).Create(&trustedpublishermodel.TrustedPublisherExchangeModel{
+		TokenHash: exchangeHash(issuer, req.Token, claims),
+		ExpiredAt: expiration.Add(exchangeLeeway),
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		logger.InfoContext(spanContext, "trusted publishing refused", "package", req.Package, "issuer", issuer, "subject", subject, "reason", "replayed")
+		return nil, errNoTrustedPublisher
+	}
+
+	trustedPublisher := trustedPublishers[index]
+	write := true
+	expiredAt := now.Add(time.Duration(service.config.TokenLifetime) * time.Second)
+	token, err := service.pubTokenService.Insert(spanContext, &pubtokenmodel.PubTokenModel{
+		Remarks:   fmt.Sprintf("trusted publisher %s: %s", trustedPublisher.ID, subject),
+		Write:     &write,
+		ExpiredAt: &expiredAt,
+		Package:   &req.Package,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.InfoContext(spanContext, "trusted publishing token issued", "package", req.Package, "trusted_publisher_id", trustedPublisher.ID.String(), "subject", subject)
+
+	return &trustedpublisherdto.ExchangeResponseDTO{
+		Token:     *token,
+		Package:   req.Package,
+		ExpiredAt: expiredAt,
+	}, nil
+}
+
+// impl `TrustedPublisherService` end
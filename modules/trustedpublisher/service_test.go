@@ -0,0 +1,73 @@
+package trustedpublisher
+
+import (
+	"private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
+	"testing"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+func TestMatches(t *testing.T) {
+	trustedPublisher := &trustedpublishermodel.TrustedPublisherModel{
+		Package:  "acme_app",
+		Issuer:   "https://token.actions.githubusercontent.com",
+		Audience: "https://pub.example.com",
+		Subject:  "repo:acme/app:*",
+		Claims:   map[string]string{"repository_owner_id": "42", "ref": "refs/tags/v*"},
+	}
+	claims := func(changes map[string]interface{}) jwtlib.MapClaims {
+		claims := jwtlib.MapClaims{
+			"aud":                 "https://pub.example.com",
+			"sub":                 "repo:acme/app:ref:refs/tags/v1.2.0",
+			"ref":                 "refs/tags/v1.2.0",
+			"repository_owner_id": float64(42),
+		}
+		for name, value := range changes {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+
+	cases := []struct {
+		name    string
+		claims  jwtlib.MapClaims
+		matched bool
+	}{
+		{"matching claims", claims(nil), true},
+		{"audience among others", claims(map[string]interface{}{"aud": []interface{}{"sts.amazonaws.com", "https://pub.example.com"}}), true},
+		{"wrong audience", claims(map[string]interface{}{"aud": "https://other.example.com"}), false},
+		{"missing audience", claims(map[string]interface{}{"aud": nil}), false},
+		{"subject of another repository", claims(map[string]interface{}{"sub": "repo:acme/app-fork:ref:refs/tags/v1.2.0"}), false},
+		{"subject prefix only", claims(map[string]interface{}{"sub": "repo:acme/app"}), false},
+		{"missing subject", claims(map[string]interface{}{"sub": nil}), false},
+		{"claim pattern of another branch", claims(map[string]interface{}{"ref": "refs/heads/main"}), false},
+		{"missing claim", claims(map[string]interface{}{"ref": nil}), false},
+		{"number claim of another owner", claims(map[string]interface{}{"repository_owner_id": float64(43)}), false},
+		{"number claim as text", claims(map[string]interface{}{"repository_owner_id": "42"}), true},
+		{"list claim", claims(map[string]interface{}{"ref": []interface{}{"refs/tags/v1.2.0"}}), false},
+	}
+
+	for _, testCase := range cases {
+		if matched := matches(trustedPublisher, testCase.claims); matched != testCase.matched {
+			t.Errorf("%s: matches = %v, expected %v", testCase.name, matched, testCase.matched)
+		}
+	}
+
+	t.Run("wildcard patterns", func(t *testing.T) {
+		anyRepository := *trustedPublisher
+		anyRepository.Subject = "*"
+		anyRepository.Claims = map[string]string{"ref": "*"}
+
+		if !matches(&anyRepository, claims(map[string]interface{}{"sub": "repo:other/app:ref:refs/heads/main", "ref": "refs/heads/main"})) {
+			t.Fatal("expected `*` to match any subject & claim")
+		}
+		// `*` matches any text, not a missing claim
+		if matches(&anyRepository, claims(map[string]interface{}{"ref": nil})) {
+			t.Fatal("expected a missing claim not to match `*`")
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package trustedpublisherdto
+
+import "time"
+
+type ExchangeDTO struct {
+	// oidc id token of the ci provider
+	Token   string `json:"token" validate:"required"`
+	Package string `json:"package" validate:"required,max=64"`
+}
+
+type ExchangeResponseDTO struct {
+	// pub token limited to the package, shown this one time only
+	Token     string    `json:"token"`
+	Package   string    `json:"package"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
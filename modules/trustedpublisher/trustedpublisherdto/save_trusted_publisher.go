@@ -0,0 +1,24 @@
+package trustedpublisherdto
+
+import "private-pub-repo/modules/trustedpublisher/trustedpublishermodel"
+
+// SaveTrustedPublisherDTO = body of the create & update, the update replaces every field
+type SaveTrustedPublisherDTO struct {
+	Package  string            `json:"package" validate:"required,max=64"`
+	Issuer   string            `json:"issuer" validate:"required,url,max=255"`
+	Audience string            `json:"audience" validate:"required,max=255"`
+	Subject  string            `json:"subject" validate:"required,max=255"`
+	Claims   map[string]string `json:"claims" validate:"omitempty,max=20,dive,keys,required,max=64,endkeys,required,max=255"`
+	Remarks  string            `json:"remarks" validate:"max=255"`
+}
+
+func (dto *SaveTrustedPublisherDTO) ToModel() *trustedpublishermodel.TrustedPublisherModel {
+	return &trustedpublishermodel.TrustedPublisherModel{
+		Package:  dto.Package,
+		Issuer:   dto.Issuer,
+		Audience: dto.Audience,
+		Subject:  dto.Subject,
+		Claims:   dto.Claims,
+		Remarks:  dto.Remarks,
+	}
+}
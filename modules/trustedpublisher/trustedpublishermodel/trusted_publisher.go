@@ -0,0 +1,21 @@
+package trustedpublishermodel
+
+import "private-pub-repo/base"
+
+// TrustedPublisherModel = ci allowed to publish the package with the oidc tokens of its provider
+type TrustedPublisherModel struct {
+	base.BaseModel
+	Package string `json:"package" gorm:"size:255;not null;index"`
+	// iss of the oidc tokens, its keys are found by openid discovery
+	Issuer   string `json:"issuer" gorm:"size:255;not null"`
+	Audience string `json:"audience" gorm:"size:255;not null"`
+	// pattern of the sub claim, `*` matches any text
+	Subject string `json:"subject" gorm:"size:255;not null"`
+	// patterns of other claims, e.g. {"repository": "acme/app", "ref": "refs/tags/v*"}
+	Claims  map[string]string `json:"claims" gorm:"type:text;serializer:json"`
+	Remarks string            `json:"remarks" gorm:"size:255"`
+}
+
+func (TrustedPublisherModel) TableName() string {
+	return "trusted_publishers"
+}
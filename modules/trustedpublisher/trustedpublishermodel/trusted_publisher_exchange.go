@@ -0,0 +1,14 @@
+package trustedpublishermodel
+
+import "time"
+
+// TrustedPublisherExchangeModel = oidc token already exchanged, kept until it expires so it is exchanged once only
+type TrustedPublisherExchangeModel struct {
+	// sha256 of the issuer & jti, of the whole token when it has no jti
+	TokenHash string    `json:"-" gorm:"primaryKey;size:64"`
+	ExpiredAt time.Time `json:"expired_at" gorm:"not null;index"`
+}
+
+func (TrustedPublisherExchangeModel) TableName() string {
+	return "trusted_publisher_exchanges"
+}
@@ -6,6 +6,7 @@ import (
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/user/userdto"
 	"private-pub-repo/utils"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -67,6 +68,47 @@ func (controller *userController) handleLogin(ctx *fiber.Ctx) error {
 	return controller.responseService.SendSuccessDetailResponse(ctx, 200, response)
 }
 
+func (controller *userController) handleSsoAuthorize(ctx *fiber.Ctx) error {
+	authorizeUrl, cookie, err := controller.service.SsoAuthorize(ctx.UserContext())
+
+	if err != nil {
+		return err
+	}
+	ctx.Cookie(cookie)
+	return ctx.Redirect(authorizeUrl, fiber.StatusFound)
+}
+
+// handleSsoCallback = redirect of the provider (GET), or its parameters forwarded by a frontend (POST)
+func (controller *userController) handleSsoCallback(ctx *fiber.Ctx) error {
+	request := userdto.SsoCallbackDTO{}
+	if ctx.Method() == fiber.MethodGet {
+		ctx.QueryParser(&request)
+	} else {
+		ctx.BodyParser(&request)
+	}
+
+	if request.Error != "" {
+		return fiber.NewError(401, strings.TrimSpace("Single sign-on failed: "+request.Error+" "+request.ErrorDescription))
+	}
+
+	err := controller.validator.Struct(request)
+
+	if err != nil {
+		return controller.responseService.SendValidationErrorResponse(ctx, 400, validationError, err.(validator.ValidationErrors))
+	}
+
+	request.StateCookie = ctx.Cookies(ssoStateCookieName)
+	// once only, like the state
+	ctx.Cookie(expiredSsoStateCookie())
+
+	response, err := controller.service.SsoLogin(ctx.UserContext(), &request)
+
+	if err != nil {
+		return err
+	}
+	return controller.responseService.SendSuccessDetailResponse(ctx, 200, response)
+}
+
 func (controller *userController) handleForgotOtp(ctx *fiber.Ctx) error {
 	request := userdto.ForgotOtpDTO{}
 	ctx.BodyParser(&request)
@@ -46,7 +46,10 @@ func (service *userMiddlewareImpl) HasAccess(c *fiber.Ctx) error {
 		var userId string
 		if userId, err = utils.GetFiberJwtUserIdString(c); err == nil {
 			service.monitorService.SetCurrentSpanAttributes(c.UserContext(), map[string]interface{}{"admin_user_id": userId})
+			service.monitorService.AddLogAttributes(c.UserContext(), map[string]interface{}{"user_id": userId})
 		}
+	} else {
+		service.monitorService.RecordAuthFailure(c.UserContext(), "wrong_issuer")
 	}
 
 	return err
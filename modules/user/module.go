@@ -8,6 +8,8 @@ import (
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/mail"
 	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/ratelimit"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/user/usermodel"
 
 	"github.com/gofiber/fiber/v2"
@@ -21,30 +23,36 @@ type UserModule struct {
 	jwtService jwt.JwtService
 	db         db.DbService
 	app        *fiber.App
+	rateLimit  ratelimit.RateLimitService
 }
 
-func NewModule(service UserService, middleware UserJwtMiddleware, controller *userController, jwtService jwt.JwtService, db db.DbService, app *fiber.App) *UserModule {
-	return &UserModule{Service: service, Middleware: middleware, jwtService: jwtService, controller: controller, db: db, app: app}
+func NewModule(service UserService, middleware UserJwtMiddleware, controller *userController, jwtService jwt.JwtService, db db.DbService, app *fiber.App, rateLimit ratelimit.RateLimitService) *UserModule {
+	return &UserModule{Service: service, Middleware: middleware, jwtService: jwtService, controller: controller, db: db, app: app, rateLimit: rateLimit}
 }
 
 func fxRegister(lifeCycle fx.Lifecycle, module *UserModule) {
 	base.FxRegister(module, lifeCycle)
 }
 
-func SetupModule(app *app.AppModule, db *db.DbModule, jwt *jwt.JwtModule, monitor *monitor.MonitorModule, config *config.ConfigModule, mail *mail.MailModule) *UserModule {
-	service := NewUserService(jwt, monitor.Service, config, mail)
+func SetupModule(app *app.AppModule, db *db.DbModule, jwt *jwt.JwtModule, monitor *monitor.MonitorModule, mail *mail.MailModule, setting *setting.SettingModule, rateLimit *ratelimit.RateLimitModule, config *config.ConfigModule) *UserModule {
+	service := NewUserService(jwt, monitor.Service, mail, setting.Service, config)
 	middleware := NewUserJwtMiddleware(jwt, monitor.Service)
 	controller := newUserController(service, app.ResponseService, app.Validator)
-	return NewModule(service, middleware, controller, jwt, db, app.App)
+	return NewModule(service, middleware, controller, jwt, db, app.App, rateLimit.Service)
 }
 
-var FxModule = fx.Module("User", fx.Provide(NewUserService), fx.Provide(NewUserJwtMiddleware), fx.Provide(newUserController), fx.Provide(NewModule), fx.Invoke(fxRegister))
+// ProvideAdminMiddleware = the admin check of the settings module
+func ProvideAdminMiddleware(middleware UserJwtMiddleware) setting.AdminMiddleware {
+	return middleware
+}
+
+var FxModule = fx.Module("User", fx.Provide(NewUserService), fx.Provide(NewUserJwtMiddleware), fx.Provide(ProvideAdminMiddleware), fx.Provide(newUserController), fx.Provide(NewModule), fx.Invoke(fxRegister))
 
 // implements `BaseModule` of `base/module.go` start
 
 func (module *UserModule) OnStart() error {
 	if module.db.AutoMigrate() {
-		module.db.Default().AutoMigrate(&usermodel.UserModel{}, &usermodel.UserOtpModel{})
+		module.db.Default().AutoMigrate(&usermodel.UserModel{}, &usermodel.UserOtpModel{}, &usermodel.UserSsoStateModel{})
 	}
 
 	//run seeder
@@ -1,23 +1,17 @@
 package user
 
-import (
-	"time"
-
-	"github.com/gofiber/fiber/v2/middleware/limiter"
-)
-
 const (
 	basePath   = "v1/users"
 	detailPath = basePath + "/:id"
 )
 
 func (module *UserModule) registerRoutes() {
-	publicRateLimiter := limiter.New(limiter.Config{
-		Expiration:        60 * time.Second,
-		LimiterMiddleware: limiter.SlidingWindow{},
-	})
+	publicRateLimiter := module.rateLimit.Public()
 
 	module.app.Post(basePath+"/login", publicRateLimiter, module.controller.handleLogin)
+	module.app.Get(basePath+"/sso/login", publicRateLimiter, module.controller.handleSsoAuthorize)
+	module.app.Get(basePath+"/sso/callback", publicRateLimiter, module.controller.handleSsoCallback)
+	module.app.Post(basePath+"/sso/callback", publicRateLimiter, module.controller.handleSsoCallback)
 	module.app.Get(basePath+"/profile", module.jwtService.GetHandler(), module.Middleware.CanAccess, module.controller.handleProfile)
 	module.app.Post(basePath+"/refresh", module.jwtService.GetHandler(), module.Middleware.CanRefresh, module.controller.handleRefresh)
 	module.app.Post(basePath+"/forgot-password/otp", publicRateLimiter, module.controller.handleForgotOtp)
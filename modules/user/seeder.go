@@ -1,6 +1,7 @@
 package user
 
 import (
+	"log/slog"
 	"private-pub-repo/base"
 	"private-pub-repo/modules/user/usermodel"
 
@@ -8,7 +9,7 @@ import (
 )
 
 func (module *UserModule) RunSeeder() {
-	println("Insert User seeders...")
+	slog.Info("inserting user seeders")
 	service := module.Service
 	db := module.db.Default()
 
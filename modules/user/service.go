@@ -2,7 +2,9 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"private-pub-repo/base"
 	"private-pub-repo/modules/app/appmodel"
 	"private-pub-repo/modules/config"
@@ -10,12 +12,14 @@ import (
 	"private-pub-repo/modules/jwt"
 	"private-pub-repo/modules/mail"
 	"private-pub-repo/modules/monitor"
+	"private-pub-repo/modules/oidc"
 	"private-pub-repo/modules/pubtoken/pubtokendto"
 	"private-pub-repo/modules/pubtoken/pubtokenmodel"
+	"private-pub-repo/modules/setting"
 	"private-pub-repo/modules/user/userdto"
 	"private-pub-repo/modules/user/usermodel"
 	"private-pub-repo/utils"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,6 +35,10 @@ const (
 	jwtIssuer = "appUser"
 
 	emailWhereQuery = "email = ?"
+	// the case of the email sent by sso providers isn't always the one the user registered with
+	ssoEmailWhereQuery = "LOWER(email) = LOWER(?)"
+	// people only, the service accounts can't login nor reset their password
+	personWhereQuery = "is_service_account = ?"
 )
 
 type UserService interface {
@@ -45,29 +53,38 @@ type UserService interface {
 	ForgotCreatePassword(context context.Context, req *userdto.ForgotCreatePasswordDTO) (bool, error)
 	RefreshToken(context context.Context, claims jwt.JwtClaim, id uuid.UUID) (response *userdto.LoginResponseDTO, err error)
 	GenerateHashPassword(password string) (*string, error)
+	// SsoAuthorize = url of the provider to start the sso login at, and the cookie binding the login to the browser
+	SsoAuthorize(context context.Context) (string, *fiber.Cookie, error)
+	// SsoLogin = tokens of the user the provider signed in, for the code of the callback
+	SsoLogin(context context.Context, req *userdto.SsoCallbackDTO) (*userdto.LoginResponseDTO, error)
 }
 
 type userServiceImpl struct {
 	monitorService monitor.MonitorService
 	jwtService     jwt.JwtService
 	db             *gorm.DB
-	otpExpiredTime time.Duration
 	mail           mail.MailService
+	settingService setting.SettingService
+	sso            *config.SsoConfig
+	// nil when sso is disabled
+	ssoVerifier *oidc.Verifier
 }
 
-func NewUserService(jwtService jwt.JwtService, monitorService monitor.MonitorService, config config.ConfigService, mail mail.MailService) UserService {
-	otpExpiredTime, err := strconv.Atoi(config.Getenv("OTP_EXPIRED_TIME", "5"))
-
-	if err != nil {
-		otpExpiredTime = 5
-	}
-
-	return &userServiceImpl{
+func NewUserService(jwtService jwt.JwtService, monitorService monitor.MonitorService, mail mail.MailService, settingService setting.SettingService, config config.ConfigService) UserService {
+	sso := &config.Config().Sso
+	service := &userServiceImpl{
 		jwtService:     jwtService,
 		monitorService: monitorService,
-		otpExpiredTime: time.Duration(otpExpiredTime) * time.Minute,
 		mail:           mail,
+		settingService: settingService,
+		sso:            sso,
+	}
+
+	if sso.Issuer != "" {
+		service.ssoVerifier = oidc.NewVerifier(ssoKeysRefresh, sso.AllowHttp)
 	}
+
+	return service
 }
 
 func (service *userServiceImpl) validateEmail(context context.Context, email string) error {
@@ -93,13 +110,19 @@ func (service *userServiceImpl) Insert(context context.Context, user *usermodel.
 		return nil, err
 	}
 
-	pwd, err := service.GenerateHashPassword(*user.Password)
+	// the users provisioned by sso have no password either, until they make one by forgot password
+	if user.IsServiceAccount || user.Password == nil {
+		noPassword := usermodel.NoPassword
+		user.Password = &noPassword
+	} else {
+		pwd, err := service.GenerateHashPassword(*user.Password)
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			return nil, err
+		}
 
-	user.Password = pwd
+		user.Password = pwd
+	}
 	result := service.db.WithContext(spanContext).Create(user)
 	dto := userdto.MapUserModelToDTO(user)
 	dto.UpdatedAt = nil
@@ -135,9 +158,10 @@ func (service *userServiceImpl) List(context context.Context, req *appmodel.GetL
 	defer span.End()
 	var count int64
 	users := []usermodel.UserModel{}
-	query := service.db.WithContext(spanContext).Model(users)
+	query := service.db.WithContext(spanContext).Model(users).Where(personWhereQuery, false)
 	if req.Search != "" {
-		query.Where(service.db.Where("name ILIKE ?", "%"+req.Search+"%").Or("email ILIKE ?", "%"+req.Search+"%"))
+		query.Where(service.db.Where(utils.InsensitiveLike("name"), utils.ContainsPattern(req.Search)).
+			Or(utils.InsensitiveLike("email"), utils.ContainsPattern(req.Search)))
 	}
 
 	var wg sync.WaitGroup
@@ -196,19 +220,19 @@ func (service *userServiceImpl) Delete(context context.Context, id uuid.UUID) er
 		"id": id.String(),
 	})
 	defer span.End()
-	var user userdto.UserDTO
+	var user usermodel.UserModel
 	result := service.db.WithContext(spanContext).Delete(&user, id)
 
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if result.Error != nil {
+		return result.Error
 	}
 
-	if result.Error == nil {
-		var token pubtokenmodel.PubTokenModel
-		service.db.WithContext(spanContext).Where("user_id = ?", id).Delete(&token)
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
 
-	return result.Error
+	var token pubtokenmodel.PubTokenModel
+	return service.db.WithContext(spanContext).Where("user_id = ?", id).Delete(&token).Error
 }
 
 func (service *userServiceImpl) Login(context context.Context, req *userdto.LoginDTO) (response *userdto.LoginResponseDTO, err error) {
@@ -216,8 +240,12 @@ func (service *userServiceImpl) Login(context context.Context, req *userdto.Logi
 		"email": req.Email,
 	})
 	defer span.End()
+	if service.sso.DisablePasswordLogin {
+		return nil, errPasswordLoginDisabled
+	}
+
 	var user usermodel.UserModel
-	result := service.db.WithContext(spanContext).Where(emailWhereQuery, req.Email).First(&user)
+	result := service.db.WithContext(spanContext).Where(emailWhereQuery, req.Email).Where(personWhereQuery, false).First(&user)
 	if result.Error != nil {
 		err = fiber.NewError(400, "Email and password doesn't match.")
 		return
@@ -240,8 +268,12 @@ func (service *userServiceImpl) ForgotOtp(context context.Context, req *userdto.
 		"email": req.Email,
 	})
 	defer span.End()
+	if service.sso.DisablePasswordLogin {
+		return nil, errPasswordLoginDisabled
+	}
+
 	var user usermodel.UserModel
-	result := service.db.WithContext(spanContext).Where(emailWhereQuery, req.Email).First(&user)
+	result := service.db.WithContext(spanContext).Where(emailWhereQuery, req.Email).Where(personWhereQuery, false).First(&user)
 	if result.Error != nil {
 		err = result.Error
 		return
@@ -259,7 +291,8 @@ func (service *userServiceImpl) ForgotOtp(context context.Context, req *userdto.
 		return
 	}
 
-	expiredAt := time.Now().Add(service.otpExpiredTime)
+	otpExpiredTime := service.settingService.Int(setting.OtpExpiredTime)
+	expiredAt := time.Now().Add(time.Duration(otpExpiredTime) * time.Minute)
 
 	userOtp := usermodel.UserOtpModel{
 		ID:        user.ID,
@@ -285,7 +318,7 @@ func (service *userServiceImpl) ForgotOtp(context context.Context, req *userdto.
 			`Please use this OTP below to change your password: %s. 
 OTP only valid for %d minute.
 Do not share this to anyone.`,
-			otp, service.otpExpiredTime/time.Minute,
+			otp, otpExpiredTime,
 		),
 	)
 	if err != nil {
@@ -302,12 +335,17 @@ func (service *userServiceImpl) ForgotCreatePassword(context context.Context, re
 		"email": req.Email,
 	})
 	defer span.End()
+	if service.sso.DisablePasswordLogin {
+		return false, errPasswordLoginDisabled
+	}
+
 	var user usermodel.UserModel
 	result := service.db.WithContext(spanContext).Model(user).
-		Select("\"users\".\"id\"", "\"UserOtp\".\"otp\"").
+		Select(service.db.Statement.Quote("users.id"), service.db.Statement.Quote("UserOtp.otp")).
 		InnerJoins("UserOtp", service.db.Where("purpose = ?", usermodel.OtpPurposeForgot)).
 		Where(emailWhereQuery, req.Email).
-		Where("expired_at >= NOW()").
+		Where(personWhereQuery, false).
+		Where("expired_at >= ?", time.Now()).
 		First(&user)
 	if result.Error != nil {
 		err = result.Error
@@ -370,4 +408,149 @@ func (service *userServiceImpl) GenerateHashPassword(password string) (*string,
 	return &pwdString, err
 }
 
+func (service *userServiceImpl) SsoAuthorize(context context.Context) (string, *fiber.Cookie, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "UserService.SsoAuthorize", map[string]interface{}{})
+	defer span.End()
+	if service.ssoVerifier == nil {
+		return "", nil, errSsoDisabled
+	}
+
+	discovery, err := service.ssoVerifier.Discover(spanContext, service.sso.Issuer)
+	if err == nil {
+		err = service.ssoVerifier.CheckUrl(discovery.AuthorizationEndpoint)
+	}
+	if err != nil {
+		service.monitorService.Logger().ErrorContext(spanContext, "sso provider unavailable", "issuer", service.sso.Issuer, "error", err)
+		return "", nil, errSsoUnavailable
+	}
+
+	authorizeUrl, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", nil, err
+	}
+
+	state := usermodel.UserSsoStateModel{ExpiredAt: time.Now().Add(time.Duration(service.sso.LoginTimeout) * time.Second)}
+	for _, value := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		if *value, err = randomString(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// the logins abandoned at the provider
+	service.db.WithContext(spanContext).Where("expired_at < ?", time.Now()).Delete(&usermodel.UserSsoStateModel{})
+
+	if err := service.db.WithContext(spanContext).Create(&state).Error; err != nil {
+		return "", nil, err
+	}
+
+	query := authorizeUrl.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", service.sso.ClientId)
+	query.Set("redirect_uri", service.sso.RedirectUrl)
+	query.Set("scope", strings.Join(append([]string{"openid"}, service.sso.Scopes...), " "))
+	query.Set("state", state.State)
+	query.Set("nonce", state.Nonce)
+	query.Set("code_challenge", codeChallenge(state.CodeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authorizeUrl.RawQuery = query.Encode()
+
+	return authorizeUrl.String(), ssoStateCookie(service.sso, state.State), nil
+}
+
+func (service *userServiceImpl) SsoLogin(context context.Context, req *userdto.SsoCallbackDTO) (*userdto.LoginResponseDTO, error) {
+	spanContext, span := service.monitorService.StartTraceSpan(context, "UserService.SsoLogin", map[string]interface{}{})
+	defer span.End()
+	if service.ssoVerifier == nil {
+		return nil, errSsoDisabled
+	}
+	logger := service.monitorService.Logger()
+
+	if !ssoSameBrowser(req.State, req.StateCookie) {
+		logger.InfoContext(spanContext, "sso login refused", "reason", "other_browser")
+		return nil, errSsoFailed
+	}
+
+	var state usermodel.UserSsoStateModel
+	result := service.db.WithContext(spanContext).Where("state = ?", req.State).Where("expired_at >= ?", time.Now()).First(&state)
+	if result.Error != nil {
+		logger.InfoContext(spanContext, "sso login refused", "reason", "unknown_state")
+		return nil, errSsoFailed
+	}
+
+	// once only, a replayed callback is refused
+	result = service.db.WithContext(spanContext).Where("state = ?", req.State).Delete(&usermodel.UserSsoStateModel{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		logger.InfoContext(spanContext, "sso login refused", "reason", "unknown_state")
+		return nil, errSsoFailed
+	}
+
+	tokens, err := service.ssoVerifier.Exchange(spanContext, service.sso.Issuer, service.sso.ClientId, service.sso.ClientSecret, url.Values{
+		"code":          {req.Code},
+		"redirect_uri":  {service.sso.RedirectUrl},
+		"code_verifier": {state.CodeVerifier},
+	})
+	if err != nil {
+		logger.InfoContext(spanContext, "sso login refused", "reason", "exchange", "error", err)
+		return nil, errSsoFailed
+	}
+
+	claims, err := service.ssoVerifier.Verify(spanContext, tokens.IdToken, service.sso.Issuer)
+	if err != nil {
+		logger.InfoContext(spanContext, "sso login refused", "reason", "invalid", "error", err)
+		return nil, errSsoFailed
+	}
+
+	email, err := ssoEmail(service.sso, claims, state.Nonce)
+	if err != nil {
+		logger.InfoContext(spanContext, "sso login refused", "reason", "claims", "error", err)
+		return nil, errSsoFailed
+	}
+
+	isAdmin, canWrite := ssoCapabilities(service.sso, claims)
+	provisioned := false
+	var user usermodel.UserModel
+	result = service.db.WithContext(spanContext).Where(ssoEmailWhereQuery, email).Where(personWhereQuery, false).Order("created_at").First(&user)
+
+	switch {
+	case errors.Is(result.Error, gorm.ErrRecordNotFound):
+		if !service.sso.JitProvisioning {
+			logger.InfoContext(spanContext, "sso login refused", "email", email, "reason", "no_user")
+			return nil, errSsoNoUser
+		}
+
+		name, _ := claims["name"].(string)
+		if name == "" {
+			name = email
+		}
+		user = usermodel.UserModel{
+			Name:     name,
+			Email:    email,
+			IsAdmin:  isAdmin != nil && *isAdmin,
+			CanWrite: canWrite != nil && *canWrite,
+		}
+		if _, err := service.Insert(spanContext, &user); err != nil {
+			return nil, err
+		}
+		provisioned = true
+	case result.Error != nil:
+		return nil, result.Error
+	case (isAdmin != nil && *isAdmin != user.IsAdmin) || (canWrite != nil && *canWrite != user.CanWrite):
+		// the groups of the provider win over the flags set by the admins
+		updated, err := service.Update(spanContext, user.ID, &userdto.UpdateUserDTO{IsAdmin: isAdmin, CanWrite: canWrite})
+		if err != nil {
+			return nil, err
+		}
+		user.IsAdmin = updated.IsAdmin
+		user.CanWrite = updated.CanWrite
+	}
+
+	logger.InfoContext(spanContext, "sso login", "user_id", user.ID.String(), "email", email, "provisioned", provisioned)
+
+	return service.jwtService.GenerateToken(user.ID, jwtIssuer, map[string]interface {
+	}{
+		"is_admin":  user.IsAdmin,
+		"can_write": user.CanWrite,
+	})
+}
+
 // impl `UserService` end
@@ -0,0 +1,152 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"private-pub-repo/modules/config"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// how long the openid configuration & keys of the provider are cached, unknown key ids refetch them sooner
+	ssoKeysRefresh = time.Hour
+	ssoRandomBytes = 32
+	// binds the login to the browser that started it, holds the hash of the state
+	ssoStateCookieName = "sso_state"
+	// the login & the callback routes
+	ssoStateCookiePath = "/v1/users/sso"
+)
+
+var (
+	errSsoDisabled    = fiber.NewError(404, "Single sign-on is not configured")
+	errSsoUnavailable = fiber.NewError(502, "Single sign-on provider is unavailable")
+	// the same for every refusal of the callback, details are logged
+	errSsoFailed             = fiber.NewError(401, "Single sign-on failed, start the login again")
+	errSsoNoUser             = fiber.NewError(403, "No user has this email, ask an admin to create yours")
+	errPasswordLoginDisabled = fiber.NewError(403, "Password login is disabled, sign in with single sign-on")
+)
+
+// randomString = url safe, for the state, the nonce & the pkce verifier
+func randomString() (string, error) {
+	bytes := make([]byte, ssoRandomBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// codeChallenge = pkce S256 challenge of verifier, RFC 7636
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ssoStateCookie = short lived cookie of the browser starting the login for state, only sent back to the callback.
+// lax, the provider redirects to the callback with a top level navigation
+func ssoStateCookie(sso *config.SsoConfig, state string) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     ssoStateCookieName,
+		Value:    codeChallenge(state),
+		Path:     ssoStateCookiePath,
+		MaxAge:   sso.LoginTimeout,
+		Secure:   strings.HasPrefix(sso.RedirectUrl, "https://"),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+}
+
+// expiredSsoStateCookie = removes the cookie of the login, once the callback used it
+func expiredSsoStateCookie() *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     ssoStateCookieName,
+		Path:     ssoStateCookiePath,
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+}
+
+// ssoSameBrowser = whether cookie is the one set for state, a callback started in another browser is refused (login csrf)
+func ssoSameBrowser(state string, cookie string) bool {
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(codeChallenge(state)), []byte(cookie)) == 1
+}
+
+// claimStrings = claim as a list, some providers send a single group as a string
+func claimStrings(value interface{}) []string {
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}
+	case []interface{}:
+		list := []string{}
+		for _, item := range typed {
+			if text, ok := item.(string); ok {
+				list = append(list, text)
+			}
+		}
+		return list
+	}
+	return []string{}
+}
+
+func memberOf(groups []string, allowed []string) bool {
+	return slices.ContainsFunc(groups, func(group string) bool {
+		return slices.Contains(allowed, group)
+	})
+}
+
+// ssoCapabilities = admin & write flags granted by the groups of the user, nil when their groups aren't configured
+func ssoCapabilities(sso *config.SsoConfig, claims jwtlib.MapClaims) (isAdmin *bool, canWrite *bool) {
+	groups := claimStrings(claims[sso.GroupsClaim])
+
+	if len(sso.AdminGroups) > 0 {
+		admin := memberOf(groups, sso.AdminGroups)
+		isAdmin = &admin
+	}
+
+	if len(sso.WriteGroups) > 0 {
+		write := memberOf(groups, sso.WriteGroups)
+		canWrite = &write
+	}
+
+	return
+}
+
+// ssoEmail = verified email of the id token, if it was issued to the client for the login of nonce
+func ssoEmail(sso *config.SsoConfig, claims jwtlib.MapClaims, nonce string) (string, error) {
+	audience, err := claims.GetAudience()
+	if err != nil || !slices.Contains(audience, sso.ClientId) {
+		return "", errors.New("issued to another client")
+	}
+
+	if party, ok := claims["azp"].(string); ok && party != sso.ClientId {
+		return "", errors.New("authorized party is another client")
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return "", errors.New("nonce doesn't match the login")
+	}
+
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return "", errors.New("no email claim, the email scope is required")
+	}
+
+	// users are matched by email, an unverified one could take over the account of someone else
+	verified, ok := claims["email_verified"]
+	if !ok && !sso.AllowUnverifiedEmail {
+		return "", errors.New("no email_verified claim")
+	}
+	if ok && verified != true {
+		return "", errors.New("email is not verified")
+	}
+
+	return email, nil
+}
@@ -0,0 +1,12 @@
+package userdto
+
+// SsoCallbackDTO = parameters of the provider redirecting to the callback, as query or body
+type SsoCallbackDTO struct {
+	Code  string `json:"code" query:"code" form:"code" validate:"required"`
+	State string `json:"state" query:"state" form:"state" validate:"required"`
+	// set by the provider instead of the code when the login failed
+	Error            string `json:"error" query:"error" form:"error"`
+	ErrorDescription string `json:"error_description" query:"error_description" form:"error_description"`
+	// cookie set when the login started, never read from the request parameters
+	StateCookie string `json:"-" query:"-" form:"-"`
+}
@@ -8,23 +8,27 @@ import (
 )
 
 type UserDTO struct {
-	ID        uuid.UUID  `json:"user_id"`
-	Name      string     `json:"name"`
-	Email     string     `json:"email"`
-	IsAdmin   bool       `json:"is_admin"`
-	CanWrite  bool       `json:"can_write"`
-	CreatedAt *time.Time `json:"created_at,omitempty"`
-	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	ID               uuid.UUID  `json:"user_id"`
+	Name             string     `json:"name"`
+	Email            string     `json:"email"`
+	IsAdmin          bool       `json:"is_admin"`
+	CanWrite         bool       `json:"can_write"`
+	IsServiceAccount bool       `json:"is_service_account"`
+	Owner            *string    `json:"owner,omitempty"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"`
 }
 
 func MapUserModelToDTO(model *usermodel.UserModel) *UserDTO {
 	return &UserDTO{
-		ID:        model.ID,
-		Name:      model.Name,
-		Email:     model.Email,
-		IsAdmin:   model.IsAdmin,
-		CanWrite:  model.CanWrite,
-		CreatedAt: model.CreatedAt,
-		UpdatedAt: model.UpdatedAt,
+		ID:               model.ID,
+		Name:             model.Name,
+		Email:            model.Email,
+		IsAdmin:          model.IsAdmin,
+		CanWrite:         model.CanWrite,
+		IsServiceAccount: model.IsServiceAccount,
+		Owner:            model.Owner,
+		CreatedAt:        model.CreatedAt,
+		UpdatedAt:        model.UpdatedAt,
 	}
 }
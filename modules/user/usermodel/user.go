@@ -2,6 +2,13 @@ package usermodel
 
 import "private-pub-repo/base"
 
+const (
+	// ServiceAccountEmailDomain = domain of the service account emails, reserved so nothing is ever mailed to them
+	ServiceAccountEmailDomain = "service-account.invalid"
+	// NoPassword = password of the service accounts, never matching a bcrypt hash
+	NoPassword = "!"
+)
+
 type UserModel struct {
 	base.BaseModel
 	Name     string       `json:"name" gorm:"not null;"`
@@ -10,6 +17,10 @@ type UserModel struct {
 	IsAdmin  bool         `json:"is_admin" gorm:"not null;default:false"`
 	UserOtp  UserOtpModel `json:"-" gorm:"foreignKey:ID;references:ID"`
 	CanWrite bool         `json:"can_write" gorm:"not null;default:false"`
+	// identity of ci pipelines, can't login and outlives the people who created its tokens
+	IsServiceAccount bool `json:"is_service_account" gorm:"not null;default:false;index"`
+	// group or publisher the service account belongs to, nil for people
+	Owner *string `json:"owner" gorm:"size:255;"`
 }
 
 func (UserModel) TableName() string {
@@ -0,0 +1,18 @@
+package usermodel
+
+import (
+	"time"
+)
+
+// UserSsoStateModel = sso login started at the provider, deleted when it comes back to the callback
+type UserSsoStateModel struct {
+	State string `json:"-" gorm:"primaryKey;size:64"`
+	Nonce string `json:"-" gorm:"not null;size:64"`
+	// pkce, only its challenge was sent to the provider
+	CodeVerifier string    `json:"-" gorm:"not null;size:64"`
+	ExpiredAt    time.Time `json:"expired_at" gorm:"not null;index"`
+}
+
+func (UserSsoStateModel) TableName() string {
+	return "user_sso_states"
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -12,3 +13,13 @@ func GetDBWithContext(db *gorm.DB, contexts []context.Context) *gorm.DB {
 	}
 	return db.WithContext(contexts[0])
 }
+
+// InsensitiveLike is a case-insensitive `LIKE` condition on column, `ILIKE` only exists on postgres
+func InsensitiveLike(column string) string {
+	return "LOWER(" + column + ") LIKE ?"
+}
+
+// ContainsPattern is the `LIKE` argument matching values that contain search, use it with `InsensitiveLike`
+func ContainsPattern(search string) string {
+	return "%" + strings.ToLower(search) + "%"
+}